@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/divilla/ethproxy/config"
+	"github.com/divilla/ethproxy/interfaces"
+	"github.com/divilla/ethproxy/internal/account"
+	"github.com/divilla/ethproxy/internal/admin"
+	"github.com/divilla/ethproxy/internal/application"
+	"github.com/divilla/ethproxy/internal/call"
+	"github.com/divilla/ethproxy/internal/export"
+	"github.com/divilla/ethproxy/internal/fees"
+	"github.com/divilla/ethproxy/internal/healthcheck"
+	"github.com/divilla/ethproxy/internal/logs"
+	"github.com/divilla/ethproxy/internal/receipts"
+	"github.com/divilla/ethproxy/internal/rpc"
+	"github.com/divilla/ethproxy/internal/stats"
+	"github.com/divilla/ethproxy/internal/test"
+	"github.com/divilla/ethproxy/internal/timestamp"
+	"github.com/divilla/ethproxy/internal/transaction"
+	"github.com/divilla/ethproxy/pkg/apikey"
+	"github.com/divilla/ethproxy/pkg/blockcache"
+	"github.com/divilla/ethproxy/pkg/cmiddleware"
+	"github.com/divilla/ethproxy/pkg/ethclient"
+	"github.com/divilla/ethproxy/pkg/jsonclient"
+	"github.com/divilla/ethproxy/pkg/quorum"
+	"github.com/divilla/ethproxy/pkg/rpccache"
+	"github.com/divilla/ethproxy/pkg/shadow"
+	"github.com/divilla/ethproxy/pkg/throttle"
+	"github.com/divilla/ethproxy/pkg/upstream"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"net"
+	"net/http"
+	"strings"
+)
+
+type (
+	// chain serves single network with its own upstream pool, latest block poller, caches & routes
+	chain struct {
+		name   string
+		hosts  []string
+		echo   *echo.Echo
+		client *ethclient.EthereumHttpClient
+		blocks interfaces.BlockSource
+		done   []func()
+	}
+
+	// chainRouter dispatches requests to chain by '/chains/:chain' path prefix or by host,
+	// other requests are served by default chain
+	chainRouter struct {
+		names map[string]*chain
+		hosts map[string]*chain
+		def   *chain
+	}
+)
+
+// newChain verifies that reachable chain upstreams serve configured chain id and builds chain services & routes,
+// unreachable upstreams are verified by pool health check. Client ip extractor, API keys, rate limits & upstream
+// concurrency limiter are shared by all chains
+func newChain(cfg config.Chain, logger echo.Logger, ip echo.IPExtractor, keys *apikey.Store, limits *cmiddleware.RateLimitConfig, limiter *throttle.Limiter) (*chain, error) {
+	if len(cfg.Urls) == 0 {
+		return nil, errors.Errorf("chain '%s' has no upstream urls", cfg.Name)
+	}
+	profile, ok := config.Profiles[cfg.Profile]
+	if !ok {
+		return nil, errors.Errorf("chain '%s' has unknown profile '%s'", cfg.Name, cfg.Profile)
+	}
+
+	upstreams := make([]upstream.Upstream, len(cfg.Urls))
+	for i, url := range cfg.Urls {
+		uClient := jsonclient.New(logger)
+		if err := uClient.Url(url); err != nil {
+			return nil, err
+		}
+
+		id, err := ethclient.ChainID(context.Background(), uClient)
+		if err != nil {
+			logger.Warnf("chain '%s' upstream '%s' failed to return chain id, it's kept out of pool until it does: %v", cfg.Name, url, err)
+		} else if id != cfg.ChainID {
+			return nil, errors.Errorf("chain '%s' upstream '%s' serves chain id '%d' instead of '%d'", cfg.Name, url, id, cfg.ChainID)
+		}
+
+		upstreams[i] = upstream.Upstream{Url: url, Client: uClient}
+	}
+
+	c := &chain{
+		name:  cfg.Name,
+		hosts: cfg.Hosts,
+	}
+
+	pool := upstream.New(logger, config.UpstreamHealthCheck, cfg.ChainID, upstreams...)
+	c.done = append(c.done, pool.Done)
+
+	// reads & latest block poller are served by the first healthy upstream with verified chain id
+	var hClient interfaces.HttpClient = pool
+	var qClient *quorum.Client
+	if len(config.Quorum) > 0 {
+		qClient = quorum.New(hClient, pool.Healthy, config.Quorum, logger)
+		hClient = qClient
+	}
+	var sClient *shadow.Client
+	if cfg.ShadowUrl != "" {
+		candidate := jsonclient.New(logger)
+		if err := candidate.Url(cfg.ShadowUrl); err != nil {
+			return nil, err
+		}
+		sClient = shadow.New(hClient, candidate, config.ShadowPercent, config.ShadowConcurrency, config.ShadowReport, cfg.Name, logger)
+		hClient = sClient
+	}
+
+	client := ethclient.New(hClient, logger, config.LatestBlockRefresh, profile)
+	// all upstream clients share one concurrency limiter, so quorum votes, verification fallbacks
+	// & broadcasts are bounded together with reads. Clients are wrapped once chain head is known,
+	// so only the first head & finality requests of ethclient.New aren't limited.
+	if limiter != nil {
+		pool.Wrap(func(uClient interfaces.HttpClient) interfaces.HttpClient {
+			return throttle.NewClient(uClient, limiter, throttle.ByHead(client.LatestBlockNumber, config.UpstreamHeadDistance))
+		})
+	}
+	if config.VerifyBlocks {
+		client.Verify(func() []interfaces.HttpClient {
+			// the first healthy upstream served the block
+			var fallbacks []interfaces.HttpClient
+			healthy := pool.Healthy()
+			for i := 1; i < len(healthy); i++ {
+				fallbacks = append(fallbacks, healthy[i].Client)
+			}
+			return fallbacks
+		})
+	}
+
+	cache := blockcache.New(logger, config.CacheCapacity, config.CacheRemoveExpired)
+	headers := blockcache.New(logger, config.CacheCapacity, config.CacheRemoveExpired)
+	receiptsCache := blockcache.New(logger, config.CacheCapacity, config.CacheRemoveExpired)
+	responses := rpccache.New(logger, config.ResponseCacheCapacity, config.CacheRemoveExpired)
+	c.done = append(c.done, client.Done, cache.Done, headers.Done, receiptsCache.Done, responses.Done)
+
+	blocks := application.Service(client, cache, headers, logger)
+	c.client = client
+	c.blocks = blocks
+
+	e := echo.New()
+	e.Logger = logger
+	e.IPExtractor = ip
+	e.HTTPErrorHandler = cmiddleware.HTTPErrorHandler
+	e.Use(cmiddleware.TraceRoute(cfg.Name))
+	e.Use(cmiddleware.APIKeyWithConfig(cmiddleware.APIKeyConfig{
+		Store:  keys,
+		Public: []string{"/healthcheck"},
+		RPC:    []string{"/rpc"},
+	}))
+
+	server := rpc.New()
+	server.Guard(cmiddleware.APIKeyGuard(keys))
+	if limits != nil {
+		e.Use(cmiddleware.RateLimitWithConfig(*limits))
+		server.Guard(cmiddleware.RateLimitGuard(*limits))
+	}
+	exports := export.Service(blocks, client, logger)
+	c.done = append(c.done, exports.Done)
+
+	application.Controller(e, client, cache, headers)
+	export.Controller(e, exports)
+	account.Controller(e, client, responses)
+	logs.Controller(e, server, client, responses, profile.MaxLogRange)
+	call.Controller(e, server, client, responses)
+	transaction.Controller(e, server, pool, client, responses)
+	fees.Controller(e, blocks, client)
+	timestamp.Controller(e, blocks, client, responses)
+	stats.Controller(e, blocks, client, responses)
+	receipts.Controller(e, blocks, client, receiptsCache)
+	rpc.Controller(e, server)
+	admin.Controller(e, keys, limiter, qClient, sClient)
+	healthcheck.Controller(e)
+	test.Controller(e)
+	c.echo = e
+
+	return c, nil
+}
+
+// Done stops chain services in reverse order of their creation
+func (c *chain) Done() {
+	for i := len(c.done) - 1; i >= 0; i-- {
+		c.done[i]()
+	}
+}
+
+// newChainRouter creates router, the first chain is default
+func newChainRouter(chains []*chain) *chainRouter {
+	r := &chainRouter{
+		names: make(map[string]*chain, len(chains)),
+		hosts: make(map[string]*chain),
+		def:   chains[0],
+	}
+
+	for _, c := range chains {
+		r.names[c.name] = c
+		for _, h := range c.hosts {
+			r.hosts[h] = c
+		}
+	}
+
+	return r
+}
+
+func (r *chainRouter) register(e *echo.Echo) {
+	e.Any("/chains/:chain", r.byName)
+	e.Any("/chains/:chain/*", r.byName)
+	e.Any("/*", r.byHost)
+}
+
+// byName serves request by chain in path, chain prefix is removed from request path
+func (r *chainRouter) byName(ctx echo.Context) error {
+	c, ok := r.names[ctx.Param("chain")]
+	if !ok {
+		return echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("chain '%s' is not configured", ctx.Param("chain")))
+	}
+
+	req := ctx.Request()
+	prefix := "/chains/" + c.name
+	req.URL.Path = "/" + strings.TrimPrefix(strings.TrimPrefix(req.URL.Path, prefix), "/")
+	if req.URL.RawPath != "" {
+		req.URL.RawPath = "/" + strings.TrimPrefix(strings.TrimPrefix(req.URL.RawPath, prefix), "/")
+	}
+	c.echo.ServeHTTP(ctx.Response(), req)
+
+	return nil
+}
+
+// byHost serves request by chain of request host, or by default chain
+func (r *chainRouter) byHost(ctx echo.Context) error {
+	host := ctx.Request().Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	c, ok := r.hosts[host]
+	if !ok {
+		c = r.def
+	}
+	c.echo.ServeHTTP(ctx.Response(), ctx.Request())
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/divilla/ethproxy/config"
+	"github.com/divilla/ethproxy/interfaces"
+	"github.com/divilla/ethproxy/pkg/blockexport"
+	"github.com/pkg/errors"
+	"os"
+	"os/signal"
+	"path/filepath"
+)
+
+// runExport exports block range to block & transaction tables, interrupted export is resumed by running it again:
+//
+//	server export -from 13000000 -to 13000999 -format csv -dir ./export
+func runExport(args []string, source interfaces.BlockSource, tracker interfaces.FinalityTracker, logger interfaces.Logger) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	from := fs.Uint64("from", 0, "first block number")
+	to := fs.Uint64("to", 0, "last block number")
+	format := fs.String("format", blockexport.FormatCSV, "output format: csv, columnar")
+	dir := fs.String("dir", config.ExportDir, "output directory, job is stored in '<from>-<to>-<format>' subdirectory")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if latest := tracker.LatestBlockNumber(); *to > latest {
+		return errors.Errorf("export range end '%d' is after latest block '%d'", *to, latest)
+	}
+
+	id := fmt.Sprintf("%d-%d-%s", *from, *to, *format)
+	job, err := blockexport.NewJob(filepath.Join(*dir, id), *from, *to, *format, config.ExportChunkSize, source, logger)
+	if err != nil {
+		return err
+	}
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt)
+	go func() {
+		<-quit
+		logger.Info("stopping export after current chunk")
+		job.Stop()
+	}()
+
+	if err = job.Run(); err != nil {
+		return err
+	}
+
+	p := job.Progress()
+	logger.Infof("export '%s' stopped at block %d of %d-%d, done: %t", id, p.Next, p.From, p.To, p.Done)
+
+	return nil
+}
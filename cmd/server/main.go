@@ -3,19 +3,20 @@ package main
 import (
 	"context"
 	"github.com/divilla/ethproxy/config"
-	"github.com/divilla/ethproxy/internal/application"
-	"github.com/divilla/ethproxy/internal/healthcheck"
-	"github.com/divilla/ethproxy/internal/test"
-	"github.com/divilla/ethproxy/pkg/blockcache"
+	"github.com/divilla/ethproxy/pkg/apikey"
 	"github.com/divilla/ethproxy/pkg/cmiddleware"
-	"github.com/divilla/ethproxy/pkg/ethclient"
-	"github.com/divilla/ethproxy/pkg/jsonclient"
+	"github.com/divilla/ethproxy/pkg/logging"
+	"github.com/divilla/ethproxy/pkg/ratelimit"
+	"github.com/divilla/ethproxy/pkg/throttle"
+	"github.com/divilla/ethproxy/pkg/tracing"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"github.com/labstack/gommon/log"
+	"github.com/pkg/errors"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"time"
 )
 
@@ -23,10 +24,48 @@ func main() {
 	// CPUProfile enables cpu profiling. Note: Default is CPU
 	//defer profile.Start(profile.MemProfileHeap, profile.ProfilePath("/home/vito/go/projects/ethproxy/cmd/profile/")).Stop()
 
+	level, err := logging.ParseLevel(config.LogLevel)
+	if err != nil {
+		panic(err)
+	}
+	logger := logging.New(os.Stdout, level)
+
+	shutdown, err := tracing.Setup(tracing.Config{
+		Exporter:    config.TraceExporter,
+		Endpoint:    config.TraceEndpoint,
+		Insecure:    config.TraceInsecure,
+		File:        config.TraceFile,
+		SampleRatio: config.TraceSampleRatio,
+		Service:     config.TraceService,
+	})
+	if err != nil {
+		panic(err)
+	}
+	defer func() {
+		if err := shutdown(context.Background()); err != nil {
+			logger.Errorf("failed to flush traces, with error: %v", err)
+		}
+	}()
+
+	proxies, err := cmiddleware.ParseProxies(config.TrustedProxies)
+	if err != nil {
+		panic(err)
+	}
+	if strings.HasPrefix(config.RateLimitKey, "header:") && len(proxies) == 0 {
+		panic(errors.Errorf("rate limit key '%s' requires trusted proxies", config.RateLimitKey))
+	}
+
 	e := echo.New()
+	e.HideBanner = true
+	e.HidePort = true
+	e.Logger = logger
+	e.IPExtractor = cmiddleware.IPExtractor(proxies)
 	e.HTTPErrorHandler = cmiddleware.HTTPErrorHandler
-	//e.Use(middleware.Logger())
-	e.Logger.SetLevel(log.INFO)
+	e.Use(cmiddleware.Tracing())
+	e.Use(cmiddleware.RequestLoggerWithConfig(cmiddleware.RequestLoggerConfig{
+		Logger:    logger,
+		AccessLog: config.AccessLog,
+	}))
 	e.Use(middleware.RecoverWithConfig(middleware.RecoverConfig{
 		StackSize: 1 << 10, // 1 KB
 		LogLevel:  log.ERROR,
@@ -37,23 +76,52 @@ func main() {
 	//	Timeout:      3*time.Second,
 	//}))
 
-	jClient := jsonclient.New(e.Logger)
-	err := jClient.Url(config.EthereumJsonRPCUrl)
+	fileKeys, err := apikey.Load(config.APIKeysFile)
 	if err != nil {
 		panic(err)
 	}
+	keys := apikey.New(append(config.APIKeys, fileKeys...))
 
-	client := ethclient.New(jClient, e.Logger, config.LatestBlockRefresh)
-	cache := blockcache.New(e.Logger, config.CacheCapacity, config.CacheRemoveExpired)
-	defer func() {
-		client.Done()
-		cache.Done()
-	}()
+	var limits *cmiddleware.RateLimitConfig
+	if config.RateLimitRate > 0 {
+		limiter := ratelimit.New(config.RateLimitRate, config.RateLimitBurst, config.RateLimitIdle)
+		defer limiter.Done()
+
+		limits = &cmiddleware.RateLimitConfig{
+			Limiter:     limiter,
+			Costs:       config.RateLimitCosts,
+			DefaultCost: config.RateLimitDefaultCost,
+			Key:         config.RateLimitKey,
+			Proxies:     proxies,
+		}
+	}
+
+	// upstream requests of all chains share one concurrency limiter
+	var upstreams *throttle.Limiter
+	if config.UpstreamConcurrency > 0 {
+		upstreams = throttle.New(config.UpstreamConcurrency, config.UpstreamQueueSize, config.UpstreamQueueTimeout)
+	}
+
+	chains := make([]*chain, len(config.Chains))
+	for i, cfg := range config.Chains {
+		c, err := newChain(cfg, e.Logger, e.IPExtractor, keys, limits, upstreams)
+		if err != nil {
+			e.Logger.Fatal(err)
+		}
+		defer c.Done()
+		chains[i] = c
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		if err = runExport(os.Args[2:], chains[0].blocks, chains[0].client, e.Logger); err != nil {
+			e.Logger.Fatal(err)
+		}
+		return
+	}
 
-	application.Controller(e, client, cache)
-	healthcheck.Controller(e)
-	test.Controller(e)
+	newChainRouter(chains).register(e)
 
+	logger.Infof("http server starting on '%s'", config.ServerAddress)
 	go func() {
 		if err := e.Start(config.ServerAddress); err != nil && err != http.ErrServerClosed {
 			e.Logger.Fatal("shutting down the server")
@@ -3,11 +3,199 @@ package config
 import "time"
 
 const (
-	ServerAddress      = ":8080"
-	EthereumJsonRPCUrl = "https://cloudflare-eth.com"
-	CacheCapacity      = 5000
-	CacheDefaultTTL    = 5 * time.Second
-	CacheRemoveExpired = 3 * time.Second
-	LatestBlockRefresh = 1 * time.Second
-	FetchRetries       = 3
+	ServerAddress         = ":8080"
+	EthereumJsonRPCUrl    = "https://cloudflare-eth.com"
+	CacheCapacity         = 5000
+	ResponseCacheCapacity = 50000
+	CacheDefaultTTL       = 5 * time.Second
+	CacheSafeTTL          = 1 * time.Minute
+	CacheRemoveExpired    = 3 * time.Second
+	LatestBlockRefresh    = 1 * time.Second
+	MinBlockRefresh       = 250 * time.Millisecond
+	FetchRetries          = 3
+
+	// LogLevel is initial level of json logs: debug, info, warn, error or off, it can be changed at /admin/log-level.
+	// AccessLog writes line with status, latency & cache status of every request at info level.
+	LogLevel  = "info"
+	AccessLog = true
+
+	// TraceExporter sends OpenTelemetry spans to 'otlp' http collector at TraceEndpoint, or writes them as json
+	// to 'stdout' or appends them to TraceFile with 'file' exporter, empty exporter disables tracing.
+	// TraceSampleRatio of new traces is sampled, traces continued from clients' traceparent follow their sampling.
+	TraceExporter    = ""
+	TraceEndpoint    = "localhost:4318"
+	TraceInsecure    = true
+	TraceFile        = "./traces.jsonl"
+	TraceSampleRatio = 1.0
+	TraceService     = "ethproxy"
+
+	TransactionsPageLimit = 100
+	TransactionsMaxLimit  = 1000
+
+	BlocksMaxRange    = 10000
+	BlocksBatchSize   = 20
+	BlocksParallelism = 4
+
+	ExportDir       = "./export"
+	ExportChunkSize = 100
+
+	LogsMaxRange  = 100000
+	LogsChunkSize = 2000
+
+	// CallLatestTTL caches eth_call at 'latest' for duration of one block, cache key changes with new block anyway
+	CallLatestTTL = 12 * time.Second
+
+	// FinalityTags enables tracking of 'safe' & 'finalized' block tags, chains without them fall back to distances
+	FinalityTags          = true
+	FallbackSafeDistance  = 20
+	FallbackFinalDistance = 1000
+
+	// FeesBlocks is number of recent blocks fee suggestions are computed from, FeesHistory blends in eth_feeHistory
+	FeesBlocks             = 20
+	FeesHistory            = true
+	FeesSlowPercentile     = 20
+	FeesStandardPercentile = 50
+	FeesFastPercentile     = 80
+
+	// ReceiptsParallelism limits per transaction receipt fetches when upstream doesn't support eth_getBlockReceipts,
+	// support is probed again after ReceiptsReprobe
+	ReceiptsParallelism = 8
+	ReceiptsReprobe     = 10 * time.Minute
+
+	// APIKeysFile holds json array of API keys added to APIKeys, authentication is disabled when there are no keys
+	APIKeysFile  = "./api_keys.json"
+	APIKeyHeader = "X-API-Key"
+	APIKeyParam  = "api_key"
+
+	// RPCMaxBatch limits number of requests in json RPC batch, every request is charged to API key quota
+	RPCMaxBatch = 100
+
+	// RateLimitRate is token bucket refill per second & RateLimitBurst its size, zero rate disables rate limiting.
+	// RateLimitKey identifies client by 'ip', 'api_key' (falls back to ip) or 'header:<name>' (falls back to ip,
+	// requires TrustedProxies).
+	RateLimitRate        = 50
+	RateLimitBurst       = 200
+	RateLimitKey         = "api_key"
+	RateLimitIdle        = 10 * time.Minute
+	RateLimitDefaultCost = 1
+
+	// UpstreamHealthCheck is interval of polling broadcast upstreams for their latest block number
+	UpstreamHealthCheck = 5 * time.Second
+	// UpstreamConcurrency bounds concurrent upstream requests, 0 disables limiter. Requests above it wait in queue of
+	// UpstreamQueueSize for at most UpstreamQueueTimeout, requests for blocks within UpstreamHeadDistance are prioritized
+	UpstreamConcurrency  = 32
+	UpstreamQueueSize    = 512
+	UpstreamQueueTimeout = 2 * time.Second
+	UpstreamHeadDistance = 64
+	// VerifyBlocks recomputes hash & transactions root of fetched blocks, blocks failing it are fetched from other upstreams
+	VerifyBlocks = false
+
+	// ShadowUrl is candidate upstream receiving ShadowPercent of upstream requests after primary responds,
+	// at most ShadowConcurrency at once. Mismatching responses are appended to ShadowReport, empty url disables mirroring
+	ShadowUrl         = ""
+	ShadowPercent     = 10
+	ShadowConcurrency = 16
+	ShadowReport      = "./shadow_report.jsonl"
+	// SubmittedTTL is how long submitted transaction hashes are remembered for status polling
+	SubmittedTTL = 24 * time.Hour
 )
+
+// APIKey is client key with its quotas, zero quota is unlimited. Methods allow route paths like '/block/:bnr'
+// and json RPC methods like 'eth_call', empty list allows all. Admin keys can read usage of all keys.
+type APIKey struct {
+	Key       string   `json:"key"`
+	Name      string   `json:"name"`
+	PerMinute int      `json:"per_minute"`
+	PerDay    int      `json:"per_day"`
+	Methods   []string `json:"methods"`
+	Admin     bool     `json:"admin"`
+}
+
+// APIKeys are API keys defined in configuration
+var APIKeys []APIKey
+
+// Chain is network served by proxy, Urls[0] serves reads, all Urls are used for broadcasts, verification fallbacks
+// & quorum. Urls reporting other ChainID stop startup, unreachable Urls join pool once they report it. Chain is served
+// at /chains/<Name>/ paths and at root paths of its Hosts, the first chain is served at root paths of all other hosts.
+// Profile is name of one of Profiles.
+type Chain struct {
+	Name      string
+	ChainID   uint64
+	Urls      []string
+	Hosts     []string
+	Profile   string
+	ShadowUrl string
+}
+
+// Profile tunes chain polling & caching. Latest block is polled 4 times per BlockTime, but not more often than
+// MinBlockRefresh, and blocks near head are cached for at most one BlockTime. Blocks within ReorgDepth of the
+// latest block can be reorganized and blocks beyond FinalDepth are final when chain doesn't support FinalityTags.
+// Adaptive profile starts with BlockTime and learns block time observed by the poller.
+type Profile struct {
+	BlockTime    time.Duration
+	ReorgDepth   uint64
+	FinalDepth   uint64
+	FinalityTags bool
+	MaxLogRange  uint64
+	Adaptive     bool
+}
+
+// Profiles are named chain profiles
+var Profiles = map[string]Profile{
+	"ethereum": {BlockTime: 12 * time.Second, ReorgDepth: FallbackSafeDistance, FinalDepth: FallbackFinalDistance, FinalityTags: FinalityTags, MaxLogRange: LogsMaxRange},
+	"optimism": {BlockTime: 2 * time.Second, ReorgDepth: 60, FinalDepth: 3600, FinalityTags: true, MaxLogRange: 10000},
+	"arbitrum": {BlockTime: 250 * time.Millisecond, ReorgDepth: 240, FinalDepth: 14400, FinalityTags: true, MaxLogRange: 10000},
+	"polygon":  {BlockTime: 2 * time.Second, ReorgDepth: 128, FinalDepth: 256, FinalityTags: true, MaxLogRange: 3500},
+	"adaptive": {BlockTime: 12 * time.Second, ReorgDepth: FallbackSafeDistance, FinalDepth: FallbackFinalDistance, MaxLogRange: 10000, Adaptive: true},
+}
+
+// QuorumRule requires Agree of the first Upstreams healthy upstreams to return equal normalized result
+type QuorumRule struct {
+	Upstreams int
+	Agree     int
+}
+
+// Quorum rules by upstream json RPC method like 'eth_getBalance', they apply to all routes & proxied methods using it
+var Quorum = map[string]QuorumRule{}
+
+// RateLimitCosts are token costs of route paths & json RPC methods, others cost RateLimitDefaultCost.
+// Json RPC route is free, its methods are charged one by one.
+var RateLimitCosts = map[string]float64{
+	"/rpc":                         0,
+	"/healthcheck":                 0,
+	"/blocks":                      20,
+	"/stats/blocks":                20,
+	"/export":                      50,
+	"/logs":                        25,
+	"eth_getLogs":                  25,
+	"/block/at/:timestamp":         5,
+	"/block/:bnr/receipts":         5,
+	"/call":                        2,
+	"eth_call":                     2,
+	"/transaction":                 2,
+	"eth_sendRawTransaction":       2,
+	"/address/:addr/storage/:slot": 2,
+}
+
+// TrustedProxies are CIDR ranges of reverse proxies, like "10.0.0.0/8". Client ip is taken from X-Forwarded-For header
+// only on requests they forward, otherwise it's connection peer address. RateLimitKey 'header:<name>' requires them
+// and header is used only on requests they forward.
+var TrustedProxies []string
+
+// EthereumJsonRPCUrls are upstreams of default chain, the first healthy one serves reads. Raw transactions are broadcast
+// to all of them and they serve as verification fallbacks & quorum voters, so additional upstreams have to be added
+// explicitly by operator, i.e. "https://ethereum.publicnode.com"
+var EthereumJsonRPCUrls = []string{
+	EthereumJsonRPCUrl,
+}
+
+// Chains are networks served by proxy, the first chain is default
+var Chains = []Chain{
+	{
+		Name:      "mainnet",
+		ChainID:   1,
+		Urls:      EthereumJsonRPCUrls,
+		Profile:   "ethereum",
+		ShadowUrl: ShadowUrl,
+	},
+}
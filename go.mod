@@ -3,20 +3,22 @@ module github.com/divilla/ethproxy
 go 1.16
 
 require (
-	github.com/asaskevich/govalidator v0.0.0-20210307081110-f21760c49a8d // indirect
-	github.com/google/pprof v0.0.0-20210804190019-f964ff605595 // indirect
-	github.com/google/uuid v1.3.0 // indirect
-	github.com/ianlancetaylor/demangle v0.0.0-20210724235854-665d3a6fe486 // indirect
-	github.com/labstack/echo/v4 v4.5.0 // indirect
-	github.com/labstack/gommon v0.3.0 // indirect
+	github.com/asaskevich/govalidator v0.0.0-20210307081110-f21760c49a8d
+	github.com/google/uuid v1.3.0
+	github.com/labstack/echo/v4 v4.5.0
+	github.com/labstack/gommon v0.3.0
 	github.com/mattn/go-isatty v0.0.13 // indirect
-	github.com/pkg/errors v0.9.1 // indirect
-	github.com/pkg/profile v1.6.0 // indirect
-	github.com/stretchr/testify v1.7.0 // indirect
-	github.com/tidwall/gjson v1.8.1 // indirect
+	github.com/pkg/errors v0.9.1
+	github.com/stretchr/testify v1.7.1
+	github.com/tidwall/gjson v1.8.1
 	github.com/tidwall/pretty v1.2.0 // indirect
-	github.com/tidwall/sjson v1.1.7 // indirect
-	golang.org/x/crypto v0.0.0-20210711020723-a769d52b0f97 // indirect
+	github.com/tidwall/sjson v1.1.7
+	go.opentelemetry.io/otel v1.6.3
+	go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp v1.6.3
+	go.opentelemetry.io/otel/exporters/stdout/stdouttrace v1.6.3
+	go.opentelemetry.io/otel/sdk v1.6.3
+	go.opentelemetry.io/otel/trace v1.6.3
+	golang.org/x/crypto v0.0.0-20210711020723-a769d52b0f97
 	golang.org/x/net v0.0.0-20210805182204-aaa1db679c0d // indirect
 	golang.org/x/sys v0.0.0-20210806184541-e5e7981a1069 // indirect
 )
@@ -1,10 +1,13 @@
 package interfaces
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 type BlockCacher interface {
-	Get(nr uint64) ([]byte, error)
-	Put(nr uint64, json []byte, ttl time.Duration) error
+	Get(ctx context.Context, nr uint64) ([]byte, error)
+	Put(ctx context.Context, nr uint64, json []byte, ttl time.Duration) error
 	Remove(nr uint64) error
 	FreeSpace() int
 }
@@ -0,0 +1,7 @@
+package interfaces
+
+import "context"
+
+type BlockSource interface {
+	GetBlocks(ctx context.Context, from, to uint64, full bool) ([][]byte, error)
+}
@@ -7,6 +7,8 @@ type Logger interface {
 	Errorf(format string, args ...interface{})
 	Info(i ...interface{})
 	Infof(format string, args ...interface{})
+	Debug(i ...interface{})
+	Debugf(format string, args ...interface{})
 	//Infoj(j log.JSON)
 	//Warn(i ...interface{})
 	//Warnf(format string, args ...interface{})
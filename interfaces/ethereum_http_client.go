@@ -1,7 +1,23 @@
 package interfaces
 
+import "context"
+
 type EthereumHttpClient interface {
-	LatestBlockNumber() uint64
-	GetLatestBlock() ([]byte, error)
-	GetBlockByNumber(nr uint64) ([]byte, error)
+	FinalityTracker
+	GetLatestBlock(ctx context.Context) ([]byte, error)
+	GetBlockByNumber(ctx context.Context, nr uint64) ([]byte, error)
+	GetBlockByTag(ctx context.Context, tag string) ([]byte, error)
+	GetBlockHeaderByNumber(ctx context.Context, nr uint64) ([]byte, error)
+	GetBlockHeaderByTag(ctx context.Context, tag string) ([]byte, error)
+	GetBlocksByNumber(ctx context.Context, nrs []uint64, full bool) ([][]byte, error)
+	GetBalance(ctx context.Context, address string, block string) ([]byte, error)
+	GetTransactionCount(ctx context.Context, address string, block string) ([]byte, error)
+	GetCode(ctx context.Context, address string, block string) ([]byte, error)
+	GetStorageAt(ctx context.Context, address string, slot string, block string) ([]byte, error)
+	GetLogs(ctx context.Context, addresses []string, topics [][]string, from, to uint64) ([]byte, error)
+	CallContract(ctx context.Context, msg map[string]string, block string) ([]byte, error)
+	GetTransactionByHash(ctx context.Context, hash string) ([]byte, error)
+	GetTransactionReceipt(ctx context.Context, hash string) ([]byte, error)
+	GetBlockReceipts(ctx context.Context, block string) ([]byte, error)
+	FeeHistory(ctx context.Context, blocks uint64, newest string, percentiles []float64) ([]byte, error)
 }
@@ -0,0 +1,16 @@
+package interfaces
+
+import "time"
+
+type FinalityTracker interface {
+	LatestBlockNumber() uint64
+	SafeBlockNumber() uint64
+	FinalizedBlockNumber() uint64
+}
+
+// FinalityProfile is implemented by trackers with chain specific block time & distances from the latest block,
+// distances are used instead of finality tags when chain doesn't support them
+type FinalityProfile interface {
+	FinalityDistances() (safe uint64, final uint64)
+	BlockTime() time.Duration
+}
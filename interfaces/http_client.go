@@ -1,6 +1,8 @@
 package interfaces
 
+import "context"
+
 type HttpClient interface {
 	Url(url string) error
-	Post(body string) ([]byte, error)
+	Post(ctx context.Context, body string) ([]byte, error)
 }
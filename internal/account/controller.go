@@ -0,0 +1,47 @@
+package account
+
+import (
+	"github.com/divilla/ethproxy/interfaces"
+	"github.com/divilla/ethproxy/pkg/cmiddleware"
+	"github.com/labstack/echo/v4"
+)
+
+type (
+	controller struct {
+		service *service
+		logger  interfaces.Logger
+	}
+)
+
+func Controller(e *echo.Echo, client interfaces.EthereumHttpClient, cache interfaces.ResponseCacher) {
+	c := &controller{
+		service: Service(client, cache, e.Logger),
+		logger:  e.Logger,
+	}
+
+	g := e.Group("/address/:addr")
+	g.GET("/balance", c.getBalance)
+	g.GET("/nonce", c.getNonce)
+	g.GET("/code", c.getCode)
+	g.GET("/storage/:slot", c.getStorageAt)
+}
+
+func (c *controller) getBalance(ctx echo.Context) error {
+	json, err := c.service.getBalance(ctx.Request().Context(), ctx.Param("addr"), ctx.QueryParam("block"))
+	return cmiddleware.WriteJSON(ctx, json, err)
+}
+
+func (c *controller) getNonce(ctx echo.Context) error {
+	json, err := c.service.getNonce(ctx.Request().Context(), ctx.Param("addr"), ctx.QueryParam("block"))
+	return cmiddleware.WriteJSON(ctx, json, err)
+}
+
+func (c *controller) getCode(ctx echo.Context) error {
+	json, err := c.service.getCode(ctx.Request().Context(), ctx.Param("addr"), ctx.QueryParam("block"))
+	return cmiddleware.WriteJSON(ctx, json, err)
+}
+
+func (c *controller) getStorageAt(ctx echo.Context) error {
+	json, err := c.service.getStorageAt(ctx.Request().Context(), ctx.Param("addr"), ctx.Param("slot"), ctx.QueryParam("block"))
+	return cmiddleware.WriteJSON(ctx, json, err)
+}
@@ -0,0 +1,107 @@
+package account
+
+import (
+	"context"
+	"fmt"
+	"github.com/divilla/ethproxy/config"
+	"github.com/divilla/ethproxy/interfaces"
+	"github.com/divilla/ethproxy/pkg/blockcache"
+	"github.com/divilla/ethproxy/pkg/ethclient"
+	"github.com/divilla/ethproxy/pkg/logging"
+	"github.com/labstack/echo/v4"
+	"github.com/tidwall/sjson"
+	"math/big"
+	"net/http"
+)
+
+type (
+	service struct {
+		client interfaces.EthereumHttpClient
+		cache  interfaces.ResponseCacher
+		logger interfaces.Logger
+	}
+)
+
+func Service(client interfaces.EthereumHttpClient, cache interfaces.ResponseCacher, logger interfaces.Logger) *service {
+	return &service{
+		client: client,
+		cache:  cache,
+		logger: logger,
+	}
+}
+
+func (s *service) getBalance(ctx context.Context, addr, block string) ([]byte, error) {
+	return s.get(ctx, "balance", "", addr, block, func(address, block string) ([]byte, error) {
+		return s.client.GetBalance(ctx, address, block)
+	})
+}
+
+func (s *service) getNonce(ctx context.Context, addr, block string) ([]byte, error) {
+	return s.get(ctx, "nonce", "", addr, block, func(address, block string) ([]byte, error) {
+		return s.client.GetTransactionCount(ctx, address, block)
+	})
+}
+
+func (s *service) getCode(ctx context.Context, addr, block string) ([]byte, error) {
+	return s.get(ctx, "code", "", addr, block, func(address, block string) ([]byte, error) {
+		return s.client.GetCode(ctx, address, block)
+	})
+}
+
+func (s *service) getStorageAt(ctx context.Context, addr, slot, block string) ([]byte, error) {
+	v, ok := new(big.Int).SetString(slot, 0)
+	if !ok || v.Sign() < 0 || v.BitLen() > 256 {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("storage slot '%s' is not valid 32 byte decimal or hex integer", slot))
+	}
+	slot = fmt.Sprintf("0x%064x", v)
+
+	return s.get(ctx, "storage", slot, addr, block, func(address, block string) ([]byte, error) {
+		return s.client.GetStorageAt(ctx, address, slot, block)
+	})
+}
+
+// get validates address & block, serves response from cache, fetched response is cached permanently
+// for finalized block numbers, using block TTL for other block numbers and short TTL for 'latest'
+func (s *service) get(ctx context.Context, name, slot, addr, blocks string, fetch func(address, block string) ([]byte, error)) ([]byte, error) {
+	address, err := ethclient.ParseAddress(addr)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	block, ttl, err := blockcache.ResolveBlock(ctx, blocks, s.client, config.CacheDefaultTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	key := fmt.Sprintf("account/%s/%s/%s/%s", address, name, slot, block)
+	if json, err := s.cache.Get(ctx, key); err == nil {
+		return json, nil
+	}
+
+	value, err := fetch(address, block)
+	if err != nil {
+		logging.FromContext(ctx, s.logger).Error(err)
+		return nil, echo.NewHTTPError(http.StatusBadGateway, fmt.Sprintf("unable to fetch %s of address '%s' at block '%s'", name, addr, block))
+	}
+
+	fields := [][2]string{{"address", ethclient.ChecksumAddress(address)}, {"block", block}}
+	if slot != "" {
+		fields = append(fields, [2]string{"slot", slot})
+	}
+	fields = append(fields, [2]string{name, string(value)})
+
+	json := []byte(`{}`)
+	for _, kv := range fields {
+		if json, err = sjson.SetBytes(json, kv[0], kv[1]); err != nil {
+			return nil, err
+		}
+	}
+
+	if ttl > 0 {
+		if err = s.cache.Put(ctx, key, json, ttl); err != nil {
+			logging.FromContext(ctx, s.logger).Error(err)
+		}
+	}
+
+	return json, nil
+}
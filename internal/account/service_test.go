@@ -0,0 +1,116 @@
+package account
+
+import (
+	"context"
+	"github.com/divilla/ethproxy/config"
+	"github.com/divilla/ethproxy/internal/testutil"
+	"github.com/divilla/ethproxy/pkg/blockcache"
+	"github.com/divilla/ethproxy/pkg/ethclient"
+	"github.com/divilla/ethproxy/pkg/rpccache"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"time"
+)
+
+const (
+	lower    = "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed"
+	checksum = "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"
+)
+
+// fakeClient returns block of request as balance
+type fakeClient struct {
+	testutil.Client
+	calls int
+}
+
+func (c *fakeClient) GetBalance(_ context.Context, _ string, block string) ([]byte, error) {
+	c.calls++
+	return []byte(block), nil
+}
+
+// ttlCache records TTL of cached responses
+type ttlCache struct {
+	*rpccache.ResponseCache
+	ttls map[string]time.Duration
+}
+
+func (c *ttlCache) Put(ctx context.Context, key string, json []byte, ttl time.Duration) error {
+	c.ttls[key] = ttl
+	return c.ResponseCache.Put(ctx, key, json, ttl)
+}
+
+func newService(t *testing.T, client *fakeClient) (*service, *ttlCache) {
+	logger := testutil.Logger()
+	cache := &ttlCache{ResponseCache: testutil.ResponseCache(t, logger), ttls: make(map[string]time.Duration)}
+	client.Client = testutil.Client{Latest: 100, Safe: 90, Finalized: 80}
+
+	return Service(client, cache, logger), cache
+}
+
+func TestService_GetBalance_Address(t *testing.T) {
+	client := &fakeClient{}
+	s, _ := newService(t, client)
+
+	// address is returned checksummed and all cases share cache entry
+	for _, addr := range []string{lower, checksum, "0x5AAEB6053F3E94C9B9A09F33669435E7EF1BEAED"} {
+		json, err := s.getBalance(context.Background(), addr, "70")
+		if assert.NoError(t, err, addr) {
+			assert.JSONEq(t, `{"address":"`+checksum+`","block":"0x46","balance":"0x46"}`, string(json))
+		}
+	}
+	assert.Equal(t, 1, client.calls)
+
+	for _, addr := range []string{"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAeD", lower[:41], lower[2:]} {
+		_, err := s.getBalance(context.Background(), addr, "")
+		if assert.IsType(t, &echo.HTTPError{}, err, addr) {
+			assert.Equal(t, http.StatusBadRequest, err.(*echo.HTTPError).Code, addr)
+		}
+	}
+	assert.Equal(t, 1, client.calls)
+}
+
+func TestService_GetBalance_Block(t *testing.T) {
+	tests := []struct {
+		block    string
+		resolved string
+		ttl      time.Duration
+	}{
+		{"", "0x64", config.CacheDefaultTTL},
+		{"latest", "0x64", config.CacheDefaultTTL},
+		{"latest-1", "0x63", blockcache.DefaultExpiryPolicy.ShortTTL},
+		{"safe", "0x5a", config.CacheSafeTTL},
+		{"85", "0x55", config.CacheSafeTTL},
+		{"finalized", "0x50", blockcache.Permanent},
+		{"latest-30", "0x46", blockcache.Permanent},
+	}
+	for _, tt := range tests {
+		s, cache := newService(t, &fakeClient{})
+		json, err := s.getBalance(context.Background(), lower, tt.block)
+		if assert.NoError(t, err, tt.block) {
+			assert.JSONEq(t, `{"address":"`+checksum+`","block":"`+tt.resolved+`","balance":"`+tt.resolved+`"}`, string(json))
+			assert.Equal(t, map[string]time.Duration{"account/" + lower + "/balance//" + tt.resolved: tt.ttl}, cache.ttls, tt.block)
+		}
+	}
+
+	for _, block := range []string{"101", "abc", "latest-200", "pending-1"} {
+		s, _ := newService(t, &fakeClient{})
+		_, err := s.getBalance(context.Background(), lower, block)
+		assert.IsType(t, &ethclient.BlockError{}, err, block)
+	}
+}
+
+func TestService_GetBalance_Pending(t *testing.T) {
+	client := &fakeClient{}
+	s, cache := newService(t, client)
+
+	for i := 1; i <= 2; i++ {
+		json, err := s.getBalance(context.Background(), lower, "pending")
+		if assert.NoError(t, err) {
+			assert.JSONEq(t, `{"address":"`+checksum+`","block":"pending","balance":"pending"}`, string(json))
+		}
+		assert.Equal(t, i, client.calls)
+	}
+	assert.Empty(t, cache.ttls)
+}
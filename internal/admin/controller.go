@@ -0,0 +1,112 @@
+package admin
+
+import (
+	"github.com/divilla/ethproxy/pkg/apikey"
+	"github.com/divilla/ethproxy/pkg/cmiddleware"
+	"github.com/divilla/ethproxy/pkg/logging"
+	"github.com/divilla/ethproxy/pkg/quorum"
+	"github.com/divilla/ethproxy/pkg/shadow"
+	"github.com/divilla/ethproxy/pkg/throttle"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+type (
+	controller struct {
+		keys    *apikey.Store
+		limiter *throttle.Limiter
+		quorum  *quorum.Client
+		shadow  *shadow.Client
+		logger  echo.Logger
+	}
+
+	logLevel struct {
+		Level string `json:"level"`
+	}
+)
+
+// Controller registers admin routes, limiter is nil when upstream concurrency isn't limited,
+// quorum is nil when there are no quorum rules and shadow is nil when mirroring is disabled
+func Controller(e *echo.Echo, keys *apikey.Store, limiter *throttle.Limiter, quorum *quorum.Client, shadow *shadow.Client) {
+	c := &controller{
+		keys:    keys,
+		limiter: limiter,
+		quorum:  quorum,
+		shadow:  shadow,
+		logger:  e.Logger,
+	}
+
+	g := e.Group("/admin", c.admin)
+	g.GET("/usage", c.getUsage)
+	g.GET("/upstream", c.getUpstream)
+	g.GET("/quorum", c.getQuorum)
+	g.GET("/shadow", c.getShadow)
+	g.GET("/log-level", c.getLogLevel)
+	g.PUT("/log-level", c.setLogLevel)
+}
+
+// admin allows only requests authenticated with admin API key
+func (c *controller) admin(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		key, _ := ctx.Get(cmiddleware.ContextAPIKey).(string)
+		if k, ok := c.keys.Key(key); !ok || !k.Admin {
+			return echo.NewHTTPError(http.StatusForbidden, "admin API key is required")
+		}
+
+		return next(ctx)
+	}
+}
+
+// getUsage returns request counters & quotas of all API keys
+func (c *controller) getUsage(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, c.keys.Usage())
+}
+
+// getUpstream returns upstream concurrency limiter state, queue lengths & shed requests by priority
+func (c *controller) getUpstream(ctx echo.Context) error {
+	if c.limiter == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "upstream concurrency limiter is disabled")
+	}
+
+	return ctx.JSON(http.StatusOK, c.limiter.Metrics())
+}
+
+// getQuorum returns quorum requests, disagreements & failures by json RPC method
+func (c *controller) getQuorum(ctx echo.Context) error {
+	if c.quorum == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "quorum reads are disabled")
+	}
+
+	return ctx.JSON(http.StatusOK, c.quorum.Metrics())
+}
+
+// getShadow returns counters of requests mirrored to candidate upstream
+func (c *controller) getShadow(ctx echo.Context) error {
+	if c.shadow == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "shadow traffic mirroring is disabled")
+	}
+
+	return ctx.JSON(http.StatusOK, c.shadow.Metrics())
+}
+
+// getLogLevel returns current log level
+func (c *controller) getLogLevel(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, logLevel{Level: logging.LevelName(c.logger.Level())})
+}
+
+// setLogLevel changes log level of all chains at runtime, level is given as {"level":"debug"}
+func (c *controller) setLogLevel(ctx echo.Context) error {
+	var l logLevel
+	if err := ctx.Bind(&l); err != nil {
+		return err
+	}
+
+	level, err := logging.ParseLevel(l.Level)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	c.logger.SetLevel(level)
+	logging.FromContext(ctx.Request().Context(), c.logger).Infof("log level changed to '%s'", logging.LevelName(level))
+
+	return ctx.JSON(http.StatusOK, logLevel{Level: logging.LevelName(level)})
+}
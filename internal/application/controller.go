@@ -2,7 +2,9 @@ package application
 
 import (
 	"github.com/divilla/ethproxy/interfaces"
+	"github.com/divilla/ethproxy/pkg/logging"
 	"github.com/labstack/echo/v4"
+	"github.com/tidwall/sjson"
 	"net/http"
 )
 
@@ -13,15 +15,17 @@ type (
 	}
 )
 
-func Controller(e *echo.Echo, client interfaces.EthereumHttpClient, cache interfaces.BlockCacher) {
+func Controller(e *echo.Echo, client interfaces.EthereumHttpClient, cache interfaces.BlockCacher, headers interfaces.BlockCacher) {
 	c := &controller{
-		service: Service(client, cache, e.Logger),
+		service: Service(client, cache, headers, e.Logger),
 	}
 
 	e.GET("/cache-free-space", c.cacheFreeSpace)
 	e.GET("/latest-block-number", c.latestBlockNumber)
 	e.GET("/block/:bnr", c.getBlockByNumber)
 	e.GET("/block/:bnr/transaction/:tid", c.getTransactionByBlockNumberAndIndex)
+	e.GET("/block/:bnr/transactions", c.getTransactions)
+	e.GET("/blocks", c.getBlocks)
 }
 
 func (c *controller) cacheFreeSpace(ctx echo.Context) error {
@@ -35,7 +39,12 @@ func (c *controller) latestBlockNumber(ctx echo.Context) error {
 }
 
 func (c *controller) getBlockByNumber(ctx echo.Context) error {
-	json, err := c.service.getBlockByNumber(ctx.Param("bnr"))
+	q, err := parseBlockQuery(ctx)
+	if err != nil {
+		return err
+	}
+
+	json, err := c.service.getBlockByNumber(ctx.Request().Context(), ctx.Param("bnr"), q)
 	if err != nil {
 		return err
 	}
@@ -47,7 +56,12 @@ func (c *controller) getBlockByNumber(ctx echo.Context) error {
 }
 
 func (c *controller) getTransactionByBlockNumberAndIndex(ctx echo.Context) error {
-	json, err := c.service.getTransactionByBlockNumberAndIndex(ctx.Param("bnr"), ctx.Param("tid"))
+	q, err := parseBlockQuery(ctx)
+	if err != nil {
+		return err
+	}
+
+	json, err := c.service.getTransactionByBlockNumberAndIndex(ctx.Request().Context(), ctx.Param("bnr"), ctx.Param("tid"), q)
 	if err != nil {
 		return err
 	}
@@ -57,3 +71,51 @@ func (c *controller) getTransactionByBlockNumberAndIndex(ctx echo.Context) error
 
 	return err
 }
+
+func (c *controller) getTransactions(ctx echo.Context) error {
+	q, err := parseBlockQuery(ctx)
+	if err != nil {
+		return err
+	}
+
+	f, err := parseTransactionFilter(ctx)
+	if err != nil {
+		return err
+	}
+
+	json, err := c.service.getTransactions(ctx.Request().Context(), ctx.Param("bnr"), f, q)
+	if err != nil {
+		return err
+	}
+
+	ctx.Response().Header().Set("Content-Type", "application/json")
+	_, err = ctx.Response().Write(json)
+
+	return err
+}
+
+func (c *controller) getBlocks(ctx echo.Context) error {
+	q, err := parseBlockQuery(ctx)
+	if err != nil {
+		return err
+	}
+
+	res := ctx.Response()
+	res.Header().Set("Content-Type", "application/x-ndjson")
+	err = c.service.streamBlocks(ctx.Request().Context(), ctx.QueryParam("from"), ctx.QueryParam("to"), q, func(json []byte) error {
+		if _, err := res.Write(json); err != nil {
+			return err
+		}
+		_, err := res.Write([]byte("\n"))
+		return err
+	}, res.Flush)
+
+	// once streaming started error can only be reported as the last line
+	if err != nil && res.Committed {
+		logging.FromContext(ctx.Request().Context(), c.service.logger).Error(err)
+		line, _ := sjson.Set(`{}`, "error", err.Error())
+		_, err = res.Write([]byte(line + "\n"))
+	}
+
+	return err
+}
@@ -23,6 +23,7 @@ var (
 	jClient *jsonclient.JsonHttpClient
 	client  *ethclient.EthereumHttpClient
 	cache   *blockcache.EthereumBlockCache
+	headers *blockcache.EthereumBlockCache
 )
 
 func init() {
@@ -33,8 +34,9 @@ func init() {
 		panic(err)
 	}
 
-	client = ethclient.New(jClient, e.Logger, config.LatestBlockRefresh)
+	client = ethclient.New(jClient, e.Logger, config.LatestBlockRefresh, config.Profiles["ethereum"])
 	cache = blockcache.New(e.Logger, config.CacheCapacity, config.CacheRemoveExpired)
+	headers = blockcache.New(e.Logger, config.CacheCapacity, config.CacheRemoveExpired)
 }
 
 func TestController_GetLatestBlock(t *testing.T) {
@@ -45,7 +47,7 @@ func TestController_GetLatestBlock(t *testing.T) {
 	ctx.SetParamNames("bnr")
 	ctx.SetParamValues("latest")
 	c := &controller{
-		service: Service(client, cache, e.Logger),
+		service: Service(client, cache, headers, e.Logger),
 	}
 
 	if assert.NoError(t, c.getBlockByNumber(ctx)) {
@@ -66,7 +68,7 @@ func TestController_GetBlockByNumber(t *testing.T) {
 	ctx.SetParamNames("bnr")
 	ctx.SetParamValues(strconv.Itoa(BlockNumber))
 	c := &controller{
-		service: Service(client, cache, e.Logger),
+		service: Service(client, cache, headers, e.Logger),
 	}
 
 	if assert.NoError(t, c.getBlockByNumber(ctx)) {
@@ -85,7 +87,7 @@ func TestController_LatestBlockNumber(t *testing.T) {
 	rec := httptest.NewRecorder()
 	ctx := e.NewContext(req, rec)
 	c := &controller{
-		service: Service(client, cache, e.Logger),
+		service: Service(client, cache, headers, e.Logger),
 	}
 
 	if assert.NoError(t, c.latestBlockNumber(ctx)) {
@@ -102,7 +104,7 @@ func TestController_CacheFreeSpace(t *testing.T) {
 	rec := httptest.NewRecorder()
 	ctx := e.NewContext(req, rec)
 	c := &controller{
-		service: Service(client, cache, e.Logger),
+		service: Service(client, cache, headers, e.Logger),
 	}
 
 	if assert.NoError(t, c.cacheFreeSpace(ctx)) {
@@ -141,7 +143,7 @@ func TestController_CacheFreeSpace(t *testing.T) {
 //		ctx.SetParamNames("bnr")
 //		ctx.SetParamValues(strconv.Itoa(blockNumber))
 //		c := &controller{
-//			service: Service(client, cache, e.Logger),
+//			service: Service(client, cache, headers, e.Logger),
 //		}
 //
 //		if assert.NoError(t, c.getBlockByNumber(ctx)) {
@@ -0,0 +1,134 @@
+package application
+
+import (
+	"fmt"
+	"github.com/divilla/ethproxy/config"
+	"github.com/divilla/ethproxy/pkg/ethclient"
+	"github.com/labstack/echo/v4"
+	"github.com/tidwall/gjson"
+	"math/big"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	addressRegexp  = regexp.MustCompile(`^0x[0-9a-fA-F]{40}$`)
+	selectorRegexp = regexp.MustCompile(`^0x[0-9a-fA-F]{8}$`)
+)
+
+type (
+	// transactionFilter filters & paginates transactions of a single block
+	transactionFilter struct {
+		offset   int
+		limit    int
+		from     string
+		to       string
+		creation *bool
+		minValue *big.Int
+		method   string
+		count    bool
+	}
+)
+
+// parseTransactionFilter parses 'offset', 'limit', 'from', 'to', 'creation', 'min_value', 'method' & 'count' query parameters
+func parseTransactionFilter(ctx echo.Context) (transactionFilter, error) {
+	var err error
+	f := transactionFilter{
+		limit: config.TransactionsPageLimit,
+	}
+
+	if v := ctx.QueryParam("offset"); v != "" {
+		if f.offset, err = strconv.Atoi(v); err != nil || f.offset < 0 {
+			return f, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("offset '%s' is not valid positive integer", v))
+		}
+	}
+
+	if v := ctx.QueryParam("limit"); v != "" {
+		if f.limit, err = strconv.Atoi(v); err != nil || f.limit < 1 || f.limit > config.TransactionsMaxLimit {
+			return f, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("limit '%s' is not valid integer between 1 and %d", v, config.TransactionsMaxLimit))
+		}
+	}
+
+	if f.from, err = parseAddress(ctx, "from"); err != nil {
+		return f, err
+	}
+
+	if f.to, err = parseAddress(ctx, "to"); err != nil {
+		return f, err
+	}
+
+	if v := ctx.QueryParam("creation"); v != "" {
+		creation, err := strconv.ParseBool(v)
+		if err != nil {
+			return f, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("creation '%s' is not valid boolean", v))
+		}
+		f.creation = &creation
+	}
+
+	if v := ctx.QueryParam("min_value"); v != "" {
+		minValue, ok := new(big.Int).SetString(v, 0)
+		if !ok || minValue.Sign() < 0 {
+			return f, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("min_value '%s' is not valid positive decimal or hex integer", v))
+		}
+		f.minValue = minValue
+	}
+
+	if v := ctx.QueryParam("method"); v != "" {
+		if !selectorRegexp.MatchString(v) {
+			return f, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("method '%s' is not valid 4 byte hex selector", v))
+		}
+		f.method = strings.ToLower(v)
+	}
+
+	if v := ctx.QueryParam("count"); v != "" {
+		if f.count, err = strconv.ParseBool(v); err != nil {
+			return f, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("count '%s' is not valid boolean", v))
+		}
+	}
+
+	return f, nil
+}
+
+func parseAddress(ctx echo.Context, name string) (string, error) {
+	v := ctx.QueryParam(name)
+	if v == "" {
+		return "", nil
+	}
+
+	if !addressRegexp.MatchString(v) {
+		return "", echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("%s '%s' is not valid address", name, v))
+	}
+
+	return strings.ToLower(v), nil
+}
+
+// match returns true if transaction satisfies all filters
+func (f transactionFilter) match(tx gjson.Result) bool {
+	if f.from != "" && strings.ToLower(tx.Get("from").String()) != f.from {
+		return false
+	}
+
+	to := tx.Get("to")
+	if f.to != "" && strings.ToLower(to.String()) != f.to {
+		return false
+	}
+
+	if f.creation != nil && *f.creation != (to.Type == gjson.Null || to.String() == "") {
+		return false
+	}
+
+	if f.minValue != nil {
+		value, err := ethclient.HexToBigInt(tx.Get("value").String())
+		if err != nil || value.Cmp(f.minValue) < 0 {
+			return false
+		}
+	}
+
+	if f.method != "" && !strings.HasPrefix(strings.ToLower(tx.Get("input").String()), f.method) {
+		return false
+	}
+
+	return true
+}
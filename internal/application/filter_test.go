@@ -0,0 +1,99 @@
+package application
+
+import (
+	"fmt"
+	"github.com/divilla/ethproxy/config"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const (
+	alice = "0x00000000000000000000000000000000000000aa"
+	bob   = "0x00000000000000000000000000000000000000bb"
+)
+
+func filter(query string) (transactionFilter, error) {
+	ctx := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/block/1/transactions?"+query, nil), httptest.NewRecorder())
+	return parseTransactionFilter(ctx)
+}
+
+func TestParseTransactionFilter(t *testing.T) {
+	f, err := filter("")
+	if assert.NoError(t, err) {
+		assert.Equal(t, transactionFilter{limit: config.TransactionsPageLimit}, f)
+	}
+
+	f, err = filter(fmt.Sprintf("offset=5&limit=%d&from=%s&to=%s&creation=false&min_value=0x10&method=0xA9059CBB&count=true",
+		config.TransactionsMaxLimit, "0x"+strings.ToUpper(alice[2:]), bob))
+	if assert.NoError(t, err) {
+		assert.Equal(t, 5, f.offset)
+		assert.Equal(t, config.TransactionsMaxLimit, f.limit)
+		assert.Equal(t, alice, f.from)
+		assert.Equal(t, bob, f.to)
+		assert.False(t, *f.creation)
+		assert.Equal(t, big.NewInt(16), f.minValue)
+		assert.Equal(t, "0xa9059cbb", f.method)
+		assert.True(t, f.count)
+	}
+
+	f, err = filter("min_value=1000")
+	if assert.NoError(t, err) {
+		assert.Equal(t, big.NewInt(1000), f.minValue)
+	}
+
+	for _, query := range []string{
+		"offset=-1",
+		"offset=x",
+		"limit=0",
+		fmt.Sprintf("limit=%d", config.TransactionsMaxLimit+1),
+		"from=0x00aa",
+		"to=" + alice[2:],
+		"to=" + alice[:41] + "g",
+		"creation=maybe",
+		"min_value=-1",
+		"min_value=0xzz",
+		"method=0xa9059c",
+		"method=transfer",
+		"count=2",
+	} {
+		_, err = filter(query)
+		if assert.IsType(t, &echo.HTTPError{}, err, query) {
+			assert.Equal(t, http.StatusBadRequest, err.(*echo.HTTPError).Code, query)
+		}
+	}
+}
+
+func TestTransactionFilter_Match(t *testing.T) {
+	yes, no := true, false
+	transfer := gjson.Parse(`{"from":"0x00000000000000000000000000000000000000AA","to":"` + bob + `","value":"0x10","input":"0xA9059CBB0000"}`)
+	creation := gjson.Parse(`{"from":"` + bob + `","to":null,"value":"0x0","input":"0x6080"}`)
+
+	tests := []struct {
+		filter   transactionFilter
+		transfer bool
+		creation bool
+	}{
+		{transactionFilter{}, true, true},
+		{transactionFilter{from: alice}, true, false},
+		{transactionFilter{to: bob}, true, false},
+		{transactionFilter{to: alice}, false, false},
+		{transactionFilter{creation: &yes}, false, true},
+		{transactionFilter{creation: &no}, true, false},
+		{transactionFilter{minValue: big.NewInt(16)}, true, false},
+		{transactionFilter{minValue: big.NewInt(17)}, false, false},
+		{transactionFilter{minValue: big.NewInt(0)}, true, true},
+		{transactionFilter{method: "0xa9059cbb"}, true, false},
+		{transactionFilter{from: bob, creation: &yes}, false, true},
+		{transactionFilter{from: bob, method: "0xa9059cbb"}, false, false},
+	}
+	for i, test := range tests {
+		assert.Equal(t, test.transfer, test.filter.match(transfer), i)
+		assert.Equal(t, test.creation, test.filter.match(creation), i)
+	}
+}
@@ -0,0 +1,147 @@
+package application
+
+import (
+	"fmt"
+	"github.com/divilla/ethproxy/pkg/ethclient"
+	"github.com/labstack/echo/v4"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+const (
+	TransactionsFull   = "full"
+	TransactionsHashes = "hashes"
+	TransactionsNone   = "none"
+
+	FormatRaw     = "raw"
+	FormatDecoded = "decoded"
+)
+
+var fieldRegexp = regexp.MustCompile(`^[a-zA-Z0-9]+$`)
+
+type (
+	// blockQuery shapes block & transaction responses
+	blockQuery struct {
+		transactions string
+		fields       []string
+		format       string
+	}
+)
+
+// parseBlockQuery parses 'transactions', 'fields' & 'format' query parameters
+func parseBlockQuery(ctx echo.Context) (blockQuery, error) {
+	q := blockQuery{
+		transactions: ctx.QueryParam("transactions"),
+		format:       ctx.QueryParam("format"),
+	}
+
+	switch q.format {
+	case "":
+		q.format = FormatRaw
+	case FormatRaw, FormatDecoded:
+	default:
+		return q, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("format '%s' is not one of: raw, decoded", q.format))
+	}
+
+	switch q.transactions {
+	case "":
+		q.transactions = TransactionsFull
+	case TransactionsFull, TransactionsHashes, TransactionsNone:
+	default:
+		return q, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("transactions '%s' is not one of: full, hashes, none", q.transactions))
+	}
+
+	fields := ctx.QueryParam("fields")
+	if fields == "" {
+		return q, nil
+	}
+
+	for _, f := range strings.Split(fields, ",") {
+		f = strings.TrimSpace(f)
+		if !fieldRegexp.MatchString(f) {
+			return q, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("field '%s' is not valid field name", f))
+		}
+		q.fields = append(q.fields, f)
+	}
+
+	return q, nil
+}
+
+// full returns true if transaction objects are needed to build the response
+func (q blockQuery) full() bool {
+	if q.transactions != TransactionsFull {
+		return false
+	}
+	if len(q.fields) == 0 {
+		return true
+	}
+
+	for _, f := range q.fields {
+		if f == "transactions" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// block shapes full or header only block json, cached json is never modified
+func (q blockQuery) block(json []byte) ([]byte, error) {
+	var err error
+
+	switch q.transactions {
+	case TransactionsHashes:
+		txs := gjson.GetBytes(json, "transactions")
+		if txs.Get("0").IsObject() {
+			json, err = sjson.SetRawBytes(json, "transactions", []byte(txs.Get("#.hash").Raw))
+		}
+	case TransactionsNone:
+		json, err = sjson.DeleteBytes(json, "transactions")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	json, err = q.project(json)
+	if err != nil || q.format != FormatDecoded {
+		return json, err
+	}
+
+	return ethclient.DecodeBlock(json)
+}
+
+// transaction shapes transaction json, cached json is never modified
+func (q blockQuery) transaction(json []byte) ([]byte, error) {
+	json, err := q.project(json)
+	if err != nil || q.format != FormatDecoded {
+		return json, err
+	}
+
+	return ethclient.DecodeTransaction(json)
+}
+
+// project returns json object containing only requested fields
+func (q blockQuery) project(json []byte) ([]byte, error) {
+	if len(q.fields) == 0 {
+		return json, nil
+	}
+
+	var err error
+	res := []byte(`{}`)
+	for _, f := range q.fields {
+		value := gjson.GetBytes(json, f)
+		if !value.Exists() {
+			continue
+		}
+
+		res, err = sjson.SetRawBytes(res, f, []byte(value.Raw))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
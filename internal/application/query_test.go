@@ -0,0 +1,93 @@
+package application
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func query(query string) (blockQuery, error) {
+	ctx := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/block/1?"+query, nil), httptest.NewRecorder())
+	return parseBlockQuery(ctx)
+}
+
+func TestParseBlockQuery(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected blockQuery
+		full     bool
+	}{
+		{"", blockQuery{transactions: TransactionsFull, format: FormatRaw}, true},
+		{"transactions=full&format=raw", blockQuery{transactions: TransactionsFull, format: FormatRaw}, true},
+		{"transactions=hashes&format=decoded", blockQuery{transactions: TransactionsHashes, format: FormatDecoded}, false},
+		{"transactions=none", blockQuery{transactions: TransactionsNone, format: FormatRaw}, false},
+		{"fields=number,%20hash", blockQuery{transactions: TransactionsFull, format: FormatRaw, fields: []string{"number", "hash"}}, false},
+		{"fields=number,transactions", blockQuery{transactions: TransactionsFull, format: FormatRaw, fields: []string{"number", "transactions"}}, true},
+		{"fields=transactions&transactions=hashes", blockQuery{transactions: TransactionsHashes, format: FormatRaw, fields: []string{"transactions"}}, false},
+	}
+	for _, test := range tests {
+		q, err := query(test.query)
+		if assert.NoError(t, err, test.query) {
+			assert.Equal(t, test.expected, q, test.query)
+			assert.Equal(t, test.full, q.full(), test.query)
+		}
+	}
+
+	for _, invalid := range []string{
+		"transactions=all",
+		"transactions=FULL",
+		"format=json",
+		"fields=number,",
+		"fields=transactions.0",
+		"fields=hash,gas-used",
+		"fields=*",
+	} {
+		_, err := query(invalid)
+		if assert.IsType(t, &echo.HTTPError{}, err, invalid) {
+			assert.Equal(t, http.StatusBadRequest, err.(*echo.HTTPError).Code, invalid)
+		}
+	}
+}
+
+func TestBlockQuery_Block(t *testing.T) {
+	full := `{"number":"0xa","hash":"0xb1","gasUsed":"0x5208","transactions":[{"hash":"0x01","nonce":"0x2"},{"hash":"0x02","nonce":"0x3"}]}`
+	hashes := `{"number":"0xa","hash":"0xb1","gasUsed":"0x5208","transactions":["0x01","0x02"]}`
+
+	tests := []struct {
+		name     string
+		query    blockQuery
+		block    string
+		expected string
+	}{
+		{"full", blockQuery{transactions: TransactionsFull, format: FormatRaw}, full, full},
+		{"hashes of full block", blockQuery{transactions: TransactionsHashes, format: FormatRaw}, full, hashes},
+		{"hashes of header", blockQuery{transactions: TransactionsHashes, format: FormatRaw}, hashes, hashes},
+		{"no transactions", blockQuery{transactions: TransactionsNone, format: FormatRaw}, full,
+			`{"number":"0xa","hash":"0xb1","gasUsed":"0x5208"}`},
+		{"projection", blockQuery{transactions: TransactionsFull, format: FormatRaw, fields: []string{"hash", "number", "missing"}}, full,
+			`{"hash":"0xb1","number":"0xa"}`},
+		{"projection of hashes", blockQuery{transactions: TransactionsHashes, format: FormatRaw, fields: []string{"transactions"}}, full,
+			`{"transactions":["0x01","0x02"]}`},
+		{"projection without transactions", blockQuery{transactions: TransactionsNone, format: FormatRaw, fields: []string{"number", "transactions"}}, full,
+			`{"number":"0xa"}`},
+		{"decoded", blockQuery{transactions: TransactionsFull, format: FormatDecoded, fields: []string{"number", "gasUsed", "transactions"}}, full,
+			`{"number":"10","gasUsed":"21000","transactions":[{"hash":"0x01","nonce":"2"},{"hash":"0x02","nonce":"3"}]}`},
+	}
+	for _, test := range tests {
+		json, err := test.query.block([]byte(test.block))
+		if assert.NoError(t, err, test.name) {
+			assert.JSONEq(t, test.expected, string(json), test.name)
+		}
+	}
+
+	// cached json isn't modified
+	cached := []byte(full)
+	_, err := blockQuery{transactions: TransactionsNone, format: FormatRaw}.block(cached)
+	assert.NoError(t, err)
+	assert.Equal(t, full, string(cached))
+
+	_, err = blockQuery{transactions: TransactionsFull, format: FormatDecoded}.block([]byte(`{"number":"0xzz"}`))
+	assert.Error(t, err)
+}
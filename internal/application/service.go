@@ -1,30 +1,36 @@
 package application
 
 import (
+	"context"
 	"fmt"
+	"github.com/divilla/ethproxy/config"
 	"github.com/divilla/ethproxy/interfaces"
 	"github.com/divilla/ethproxy/pkg/blockcache"
 	"github.com/divilla/ethproxy/pkg/ethclient"
+	"github.com/divilla/ethproxy/pkg/logging"
 	"github.com/labstack/echo/v4"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 	"net/http"
 	"strconv"
+	"sync"
 )
 
 type (
 	service struct {
-		client interfaces.EthereumHttpClient
-		cache  interfaces.BlockCacher
-		logger interfaces.Logger
+		client  interfaces.EthereumHttpClient
+		cache   interfaces.BlockCacher
+		headers interfaces.BlockCacher
+		logger  interfaces.Logger
 	}
 )
 
-func Service(client interfaces.EthereumHttpClient, cache interfaces.BlockCacher, logger interfaces.Logger) *service {
+func Service(client interfaces.EthereumHttpClient, cache interfaces.BlockCacher, headers interfaces.BlockCacher, logger interfaces.Logger) *service {
 	return &service{
-		client: client,
-		cache:  cache,
-		logger: logger,
+		client:  client,
+		cache:   cache,
+		headers: headers,
+		logger:  logger,
 	}
 }
 
@@ -43,56 +49,135 @@ func (s *service) latestBlockNumber() string {
 		panic(err)
 	}
 
+	json, err = sjson.Set(json, "safe_block_number", s.client.SafeBlockNumber())
+	if err != nil {
+		panic(err)
+	}
+
+	json, err = sjson.Set(json, "finalized_block_number", s.client.FinalizedBlockNumber())
+	if err != nil {
+		panic(err)
+	}
+
 	return json
 }
 
-func (s *service) getBlockByNumber(nrs string) ([]byte, error) {
-	if nrs == "latest" {
-		json, err := s.client.GetLatestBlock()
-		if err != nil {
-			return nil, err
-		}
+func (s *service) getBlockByNumber(ctx context.Context, nrs string, q blockQuery) ([]byte, error) {
+	json, err := s.getRawBlockByNumber(ctx, nrs, q.full())
+	if err != nil {
+		return nil, err
+	}
 
-		result := gjson.GetBytes(json, "number")
-		if result.Exists() && result.String() != "" {
-			nri, err := ethclient.HexToUInt(result.String())
-			if err != nil {
-				return nil, err
-			}
+	return q.block(json)
+}
 
-			_ = s.cache.Put(nri, json, blockcache.BlockExpires(nri, s.client.LatestBlockNumber()))
-		}
+// getRawBlockByNumber returns cached block json, header only block has transaction hashes instead of objects
+func (s *service) getRawBlockByNumber(ctx context.Context, nrs string, full bool) ([]byte, error) {
+	bp, err := ethclient.ParseBlockParam(nrs)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
 
-		return json, nil
+	if !bp.IsTag() {
+		return s.getBlock(ctx, bp.Number, full)
+	}
+
+	// pending block changes with every new transaction, so it's never cached
+	if bp.Tag == ethclient.TagPending {
+		json, _, err := s.getBlockByTag(ctx, bp.Tag, full, false)
+		return json, err
+	}
+
+	if bp.Offset == 0 {
+		json, _, err := s.getBlockByTag(ctx, bp.Tag, full, true)
+		return json, err
 	}
 
-	nri, err := strconv.ParseUint(nrs, 10, 64)
+	nri, err := bp.ResolveNumber(ctx, s.client, s.tagNumber)
 	if err != nil {
-		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("block number '%s' is not valid integer", nrs))
+		return nil, err
 	}
 
-	json, err := s.cache.Get(nri)
+	return s.getBlock(ctx, nri, full)
+}
+
+// tagNumber resolves tag that isn't tracked by poller from upstream
+func (s *service) tagNumber(ctx context.Context, tag string) (uint64, error) {
+	_, nr, err := s.getBlockByTag(ctx, tag, false, true)
+	return nr, err
+}
+
+// getBlock serves header only requests from cached full block when present
+func (s *service) getBlock(ctx context.Context, nri uint64, full bool) ([]byte, error) {
+	json, err := s.cachedBlock(ctx, nri, full)
 	if err == nil {
 		return json, nil
 	}
 
-	json, err = s.client.GetBlockByNumber(nri)
+	cache, fetch := s.cache, s.client.GetBlockByNumber
+	if !full {
+		cache, fetch = s.headers, s.client.GetBlockHeaderByNumber
+	}
+
+	json, err = fetch(ctx, nri)
 	if err != nil {
-		s.logger.Error(err)
+		logging.FromContext(ctx, s.logger).Error(err)
 	}
-	if json == nil {
-		return nil, echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("block with number '%s' not found", nrs))
+	if len(json) == 0 {
+		return nil, echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("block with number '%d' not found", nri))
 	}
 
-	if err = s.cache.Put(nri, json, blockcache.BlockExpires(nri, s.client.LatestBlockNumber())); err != nil {
-		s.logger.Error(err)
+	if err = cache.Put(ctx, nri, json, blockcache.BlockExpires(nri, s.client)); err != nil {
+		logging.FromContext(ctx, s.logger).Error(err)
 	}
 
 	return json, err
 }
 
-func (s *service) getTransactionByBlockNumberAndIndex(nrs string, trs string) ([]byte, error) {
-	json, err := s.getBlockByNumber(nrs)
+// cachedBlock returns cached full block, header only block is returned only if full block isn't requested
+func (s *service) cachedBlock(ctx context.Context, nri uint64, full bool) ([]byte, error) {
+	json, err := s.cache.Get(ctx, nri)
+	if err == nil || full {
+		return json, err
+	}
+
+	return s.headers.Get(ctx, nri)
+}
+
+// getBlockByTag fetches block by tag and, if requested, caches it under its resolved number
+func (s *service) getBlockByTag(ctx context.Context, tag string, full bool, cache bool) ([]byte, uint64, error) {
+	fetch, cacher := s.client.GetBlockByTag, s.cache
+	if !full {
+		fetch, cacher = s.client.GetBlockHeaderByTag, s.headers
+	}
+
+	json, err := fetch(ctx, tag)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(json) == 0 {
+		return nil, 0, echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("block with tag '%s' not found", tag))
+	}
+
+	result := gjson.GetBytes(json, "number")
+	if !result.Exists() || result.String() == "" {
+		return json, 0, nil
+	}
+
+	nri, err := ethclient.HexToUInt(result.String())
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if cache {
+		_ = cacher.Put(ctx, nri, json, blockcache.BlockExpires(nri, s.client))
+	}
+
+	return json, nri, nil
+}
+
+func (s *service) getTransactionByBlockNumberAndIndex(ctx context.Context, nrs string, trs string, q blockQuery) ([]byte, error) {
+	json, err := s.getRawBlockByNumber(ctx, nrs, true)
 	if err != nil {
 		return nil, err
 	}
@@ -116,5 +201,168 @@ func (s *service) getTransactionByBlockNumberAndIndex(nrs string, trs string) ([
 		return nil, echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("transaction with index '%x' not found in block number '%s'", trs, nrs))
 	}
 
-	return transaction, nil
+	return q.transaction(transaction)
+}
+
+func (s *service) getTransactions(ctx context.Context, nrs string, f transactionFilter, q blockQuery) ([]byte, error) {
+	json, err := s.getRawBlockByNumber(ctx, nrs, true)
+	if err != nil {
+		return nil, err
+	}
+
+	total := 0
+	transactions := []byte(`[]`)
+	gjson.GetBytes(json, "transactions").ForEach(func(key, value gjson.Result) bool {
+		if !f.match(value) {
+			return true
+		}
+
+		total++
+		if f.count || total <= f.offset || total > f.offset+f.limit {
+			return true
+		}
+
+		var transaction []byte
+		if transaction, err = q.transaction([]byte(value.Raw)); err != nil {
+			return false
+		}
+
+		transactions, err = sjson.SetRawBytes(transactions, "-1", transaction)
+		return err == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := sjson.SetBytes([]byte(`{}`), "total", total)
+	if err != nil || f.count {
+		return res, err
+	}
+
+	if res, err = sjson.SetBytes(res, "offset", f.offset); err != nil {
+		return nil, err
+	}
+
+	if res, err = sjson.SetBytes(res, "limit", f.limit); err != nil {
+		return nil, err
+	}
+
+	return sjson.SetRawBytes(res, "transactions", transactions)
+}
+
+// streamBlocks writes blocks in range in order, cache misses are fetched in parallel batches,
+// only a window of blocks is held in memory
+func (s *service) streamBlocks(ctx context.Context, froms, tos string, q blockQuery, write func(json []byte) error, flush func()) error {
+	from, err := ethclient.ResolveBlockNumber(ctx, froms, s.client, s.tagNumber)
+	if err != nil {
+		return err
+	}
+
+	to, err := ethclient.ResolveBlockNumber(ctx, tos, s.client, s.tagNumber)
+	if err != nil {
+		return err
+	}
+
+	if err = ethclient.ValidateBlockRange(from, to, config.BlocksMaxRange, s.client.LatestBlockNumber()); err != nil {
+		return err
+	}
+
+	window := uint64(config.BlocksBatchSize * config.BlocksParallelism)
+	for start := from; start <= to; start += window {
+		end := start + window - 1
+		if end > to {
+			end = to
+		}
+
+		jsons, err := s.getBlocks(ctx, start, end, q.full())
+		if err != nil {
+			return err
+		}
+
+		for _, json := range jsons {
+			if json, err = q.block(json); err != nil {
+				return err
+			}
+			if err = write(json); err != nil {
+				return err
+			}
+		}
+		flush()
+	}
+
+	return nil
+}
+
+// getBlocks returns blocks in range from cache, misses are fetched in parallel batch requests
+func (s *service) getBlocks(ctx context.Context, from, to uint64, full bool) ([][]byte, error) {
+	var misses []int
+	jsons := make([][]byte, to-from+1)
+	for i := range jsons {
+		json, err := s.cachedBlock(ctx, from+uint64(i), full)
+		if err != nil {
+			misses = append(misses, i)
+			continue
+		}
+		jsons[i] = json
+	}
+
+	cache := s.cache
+	if !full {
+		cache = s.headers
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(misses)/config.BlocksBatchSize+1)
+	sem := make(chan struct{}, config.BlocksParallelism)
+	for b := 0; b < len(misses); b += config.BlocksBatchSize {
+		end := b + config.BlocksBatchSize
+		if end > len(misses) {
+			end = len(misses)
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(batch []int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			nrs := make([]uint64, len(batch))
+			for i, idx := range batch {
+				nrs[i] = from + uint64(idx)
+			}
+
+			res, err := s.client.GetBlocksByNumber(ctx, nrs, full)
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			for i, idx := range batch {
+				if len(res[i]) == 0 {
+					errs <- echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("block with number '%d' not found", nrs[i]))
+					return
+				}
+
+				jsons[idx] = res[i]
+				if err = cache.Put(ctx, nrs[i], res[i], blockcache.BlockExpires(nrs[i], s.client)); err != nil {
+					logging.FromContext(ctx, s.logger).Error(err)
+				}
+			}
+		}(misses[b:end])
+	}
+	wg.Wait()
+	close(errs)
+
+	if err := <-errs; err != nil {
+		return nil, err
+	}
+
+	return jsons, nil
+}
+
+// GetBlocks returns full or header only blocks in range, using cache and upstream client
+func (s *service) GetBlocks(ctx context.Context, from, to uint64, full bool) ([][]byte, error) {
+	return s.getBlocks(ctx, from, to, full)
 }
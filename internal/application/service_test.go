@@ -0,0 +1,229 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"github.com/divilla/ethproxy/config"
+	"github.com/divilla/ethproxy/internal/testutil"
+	"github.com/divilla/ethproxy/pkg/ethclient"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClient answers batch block requests with blocks up to latest, blocks in missing are returned empty
+type fakeClient struct {
+	testutil.Client
+	missing map[uint64]bool
+	batches [][]uint64
+	mx      sync.Mutex
+}
+
+func (c *fakeClient) GetBlocksByNumber(_ context.Context, nrs []uint64, full bool) ([][]byte, error) {
+	c.mx.Lock()
+	c.batches = append(c.batches, nrs)
+	c.mx.Unlock()
+
+	res := make([][]byte, len(nrs))
+	for i, nr := range nrs {
+		if nr > c.Latest || c.missing[nr] {
+			continue
+		}
+		tx := `"0x01"`
+		if full {
+			tx = `{"hash":"0x01"}`
+		}
+		res[i] = []byte(fmt.Sprintf(`{"number":"%s","transactions":[%s]}`, ethclient.UIntToHex(nr), tx))
+	}
+
+	return res, nil
+}
+
+// reset returns sizes of batches requested since the last reset
+func (c *fakeClient) reset() []int {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	var sizes []int
+	for _, b := range c.batches {
+		sizes = append(sizes, len(b))
+	}
+	c.batches = nil
+	return sizes
+}
+
+// newClient returns client of chain with all blocks up to latest finalized
+func newClient(latest uint64, missing ...uint64) *fakeClient {
+	fc := &fakeClient{Client: testutil.Client{Latest: latest, Safe: latest, Finalized: latest}, missing: make(map[uint64]bool)}
+	for _, nr := range missing {
+		fc.missing[nr] = true
+	}
+	return fc
+}
+
+func newService(t *testing.T, fc *fakeClient) *service {
+	logger := testutil.Logger()
+	return Service(fc, testutil.BlockCache(t, logger, 1000), testutil.BlockCache(t, logger, 1000), logger)
+}
+
+func TestService_GetTransactions(t *testing.T) {
+	s := newService(t, newClient(1000))
+	txs := make([]string, 5)
+	for i := range txs {
+		from := alice
+		if i%2 == 1 {
+			from = bob
+		}
+		txs[i] = fmt.Sprintf(`{"hash":"0x%d","from":"%s","to":"%s","value":"0x0","input":"0x"}`, i, from, bob)
+	}
+	block := `{"number":"0xa","transactions":[` + strings.Join(txs, ",") + `]}`
+	assert.NoError(t, s.cache.Put(context.Background(), 10, []byte(block), time.Minute))
+	q := blockQuery{transactions: TransactionsFull, format: FormatRaw, fields: []string{"hash"}}
+
+	tests := []struct {
+		filter   transactionFilter
+		expected string
+	}{
+		{transactionFilter{limit: 2}, `{"total":5,"offset":0,"limit":2,"transactions":[{"hash":"0x0"},{"hash":"0x1"}]}`},
+		{transactionFilter{offset: 3, limit: 10}, `{"total":5,"offset":3,"limit":10,"transactions":[{"hash":"0x3"},{"hash":"0x4"}]}`},
+		{transactionFilter{offset: 5, limit: 10}, `{"total":5,"offset":5,"limit":10,"transactions":[]}`},
+		{transactionFilter{offset: 50, limit: 10}, `{"total":5,"offset":50,"limit":10,"transactions":[]}`},
+		{transactionFilter{offset: 1, limit: 1, from: alice}, `{"total":3,"offset":1,"limit":1,"transactions":[{"hash":"0x2"}]}`},
+		{transactionFilter{offset: 1, limit: 1, from: alice, count: true}, `{"total":3}`},
+		{transactionFilter{limit: 10, to: alice}, `{"total":0,"offset":0,"limit":10,"transactions":[]}`},
+	}
+	for _, test := range tests {
+		json, err := s.getTransactions(context.Background(), "10", test.filter, q)
+		if assert.NoError(t, err, test.expected) {
+			assert.JSONEq(t, test.expected, string(json))
+		}
+	}
+}
+
+func streamed(t *testing.T, s *service, from, to string, q blockQuery) ([]uint64, int, error) {
+	var nrs []uint64
+	var flushes int
+	err := s.streamBlocks(context.Background(), from, to, q, func(json []byte) error {
+		nr, err := ethclient.HexToUInt(gjson.GetBytes(json, "number").String())
+		assert.NoError(t, err)
+		nrs = append(nrs, nr)
+		return nil
+	}, func() {
+		flushes++
+	})
+
+	return nrs, flushes, err
+}
+
+func TestService_StreamBlocks(t *testing.T) {
+	fc := newClient(1000)
+	s := newService(t, fc)
+	q := blockQuery{transactions: TransactionsFull, format: FormatRaw}
+
+	// 100 blocks are streamed in order in two windows, misses are split into batches
+	nrs, flushes, err := streamed(t, s, "100", "199", q)
+	assert.NoError(t, err)
+	assert.Len(t, nrs, 100)
+	for i, nr := range nrs {
+		assert.Equal(t, uint64(100+i), nr)
+	}
+	window := config.BlocksBatchSize * config.BlocksParallelism
+	assert.Equal(t, (100+window-1)/window, flushes)
+	sizes := fc.reset()
+	assert.Len(t, sizes, (100+config.BlocksBatchSize-1)/config.BlocksBatchSize)
+	total := 0
+	for _, size := range sizes {
+		assert.LessOrEqual(t, size, config.BlocksBatchSize)
+		total += size
+	}
+	assert.Equal(t, 100, total)
+
+	// fetched blocks are cached, only misses are requested
+	nrs, _, err = streamed(t, s, "150", "209", q)
+	assert.NoError(t, err)
+	assert.Len(t, nrs, 60)
+	assert.Equal(t, uint64(150), nrs[0])
+	assert.Equal(t, uint64(209), nrs[59])
+	assert.Equal(t, []int{10}, fc.reset())
+
+	// header only requests are served from cached full blocks
+	hashes := blockQuery{transactions: TransactionsHashes, format: FormatRaw}
+	nrs, _, err = streamed(t, s, "100", "109", hashes)
+	assert.NoError(t, err)
+	assert.Len(t, nrs, 10)
+	assert.Empty(t, fc.reset())
+
+	// full requests aren't served from cached headers
+	_, _, err = streamed(t, s, "300", "304", hashes)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{5}, fc.reset())
+	_, _, err = streamed(t, s, "300", "304", q)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{5}, fc.reset())
+}
+
+func TestService_StreamBlocks_Errors(t *testing.T) {
+	fc := newClient(20000, 150)
+	s := newService(t, fc)
+	q := blockQuery{transactions: TransactionsFull, format: FormatRaw}
+
+	for _, rng := range [][2]string{
+		{"200", "100"},
+		{"0", fmt.Sprint(config.BlocksMaxRange)},
+		{"19990", "20010"},
+		{"pending", "100"},
+		{"x", "100"},
+	} {
+		_, _, err := streamed(t, s, rng[0], rng[1], q)
+		assert.IsType(t, &ethclient.BlockError{}, err, rng)
+	}
+	assert.Empty(t, fc.reset())
+
+	// blocks of windows before the missing block are written
+	nrs, flushes, err := streamed(t, s, "0", "199", q)
+	if assert.IsType(t, &echo.HTTPError{}, err) {
+		assert.Equal(t, http.StatusNotFound, err.(*echo.HTTPError).Code)
+		assert.Contains(t, err.Error(), "'150'")
+	}
+	window := config.BlocksBatchSize * config.BlocksParallelism
+	assert.Len(t, nrs, 150/window*window)
+	assert.Equal(t, 150/window, flushes)
+}
+
+func TestController_GetBlocks(t *testing.T) {
+	fc := newClient(1000, 150)
+	c := &controller{service: newService(t, fc)}
+
+	rec := httptest.NewRecorder()
+	ctx := e.NewContext(httptest.NewRequest(http.MethodGet, "/blocks?from=10&to=12&fields=number", nil), rec)
+	if assert.NoError(t, c.getBlocks(ctx)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/x-ndjson", rec.Header().Get("Content-Type"))
+		assert.Equal(t, "{\"number\":\"0xa\"}\n{\"number\":\"0xb\"}\n{\"number\":\"0xc\"}\n", rec.Body.String())
+	}
+
+	// error before the first write is returned as response
+	rec = httptest.NewRecorder()
+	ctx = e.NewContext(httptest.NewRequest(http.MethodGet, "/blocks?from=12&to=10", nil), rec)
+	err := c.getBlocks(ctx)
+	assert.IsType(t, &ethclient.BlockError{}, err)
+	assert.False(t, ctx.Response().Committed)
+
+	// once streaming started, error is the last line
+	rec = httptest.NewRecorder()
+	ctx = e.NewContext(httptest.NewRequest(http.MethodGet, "/blocks?from=0&to=199&fields=number", nil), rec)
+	assert.NoError(t, c.getBlocks(ctx))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	lines := strings.Split(strings.TrimSuffix(rec.Body.String(), "\n"), "\n")
+	window := config.BlocksBatchSize * config.BlocksParallelism
+	if assert.Len(t, lines, 150/window*window+1) {
+		assert.Equal(t, `{"number":"0x0"}`, lines[0])
+		assert.Contains(t, gjson.Get(lines[len(lines)-1], "error").String(), "block with number '150' not found")
+	}
+}
@@ -0,0 +1,86 @@
+package call
+
+import (
+	"github.com/divilla/ethproxy/interfaces"
+	"github.com/divilla/ethproxy/internal/rpc"
+	"github.com/labstack/echo/v4"
+	"github.com/tidwall/gjson"
+	"io/ioutil"
+	"net/http"
+)
+
+const (
+	HeaderCache      = "X-Cache"
+	HeaderCacheKey   = "X-Cache-Key"
+	HeaderCacheBlock = "X-Cache-Block"
+)
+
+type (
+	controller struct {
+		service *service
+		logger  interfaces.Logger
+	}
+)
+
+func Controller(e *echo.Echo, server *rpc.Server, client interfaces.EthereumHttpClient, cache interfaces.ResponseCacher) {
+	c := &controller{
+		service: Service(client, cache, e.Logger),
+		logger:  e.Logger,
+	}
+
+	e.POST("/call", c.call)
+	server.Register("eth_call", c.rpcCall)
+}
+
+// call executes call object from request body, block is given in 'block' field or query parameter
+func (c *controller) call(ctx echo.Context) error {
+	body, err := ioutil.ReadAll(ctx.Request().Body)
+	if err != nil {
+		return err
+	}
+
+	obj := gjson.ParseBytes(body)
+	block := obj.Get("block").String()
+	if block == "" {
+		block = ctx.QueryParam("block")
+	}
+
+	m, err := parseMessage(obj, block)
+	if err != nil {
+		return err
+	}
+
+	res, err := c.service.call(ctx.Request().Context(), m)
+	if res != nil {
+		c.headers(ctx, res)
+	}
+	if err != nil {
+		return err
+	}
+
+	return ctx.Blob(http.StatusOK, echo.MIMEApplicationJSON, []byte(`{"result":`+string(res.json)+`}`))
+}
+
+func (c *controller) rpcCall(ctx echo.Context, params gjson.Result) ([]byte, error) {
+	m, err := parseMessage(params.Get("0"), params.Get("1").String())
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.service.call(ctx.Request().Context(), m)
+	if res != nil {
+		c.headers(ctx, res)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return res.json, nil
+}
+
+func (c *controller) headers(ctx echo.Context, res *result) {
+	h := ctx.Response().Header()
+	h.Add(HeaderCache, res.status)
+	h.Add(HeaderCacheKey, res.key)
+	h.Add(HeaderCacheBlock, res.block)
+}
@@ -0,0 +1,88 @@
+package call
+
+import (
+	"encoding/hex"
+	"fmt"
+	"github.com/divilla/ethproxy/pkg/ethclient"
+	"github.com/labstack/echo/v4"
+	"github.com/tidwall/gjson"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+var (
+	dataRegexp     = regexp.MustCompile(`^0x([0-9a-fA-F]{2})*$`)
+	quantityRegexp = regexp.MustCompile(`^0x(0|[1-9a-fA-F][0-9a-fA-F]*)$`)
+)
+
+type (
+	// message is eth_call message, block is number or tag
+	message struct {
+		to    string
+		data  string
+		from  string
+		gas   string
+		value string
+		block string
+	}
+)
+
+// parseMessage parses call object, 'input' is accepted as alias of 'data'
+func parseMessage(obj gjson.Result, block string) (message, error) {
+	if !obj.IsObject() {
+		return message{}, echo.NewHTTPError(http.StatusBadRequest, "call object is missing")
+	}
+
+	m := message{
+		data:  obj.Get("data").String(),
+		gas:   obj.Get("gas").String(),
+		value: obj.Get("value").String(),
+		block: block,
+	}
+	if m.data == "" {
+		m.data = obj.Get("input").String()
+	}
+
+	var err error
+	if m.to, err = ethclient.ParseAddress(obj.Get("to").String()); err != nil {
+		return m, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if from := obj.Get("from").String(); from != "" {
+		if m.from, err = ethclient.ParseAddress(from); err != nil {
+			return m, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+	}
+
+	if m.data != "" && !dataRegexp.MatchString(m.data) {
+		return m, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("data '%s' is not valid hex data", m.data))
+	}
+	m.data = strings.ToLower(m.data)
+
+	for name, q := range map[string]*string{"gas": &m.gas, "value": &m.value} {
+		if *q != "" && !quantityRegexp.MatchString(*q) {
+			return m, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("%s '%s' is not valid hex quantity", name, *q))
+		}
+		*q = strings.ToLower(*q)
+	}
+
+	return m, nil
+}
+
+// params returns call object for json RPC request, empty fields are omitted
+func (m message) params() map[string]string {
+	params := map[string]string{"to": m.to}
+	for name, v := range map[string]string{"data": m.data, "from": m.from, "gas": m.gas, "value": m.value} {
+		if v != "" {
+			params[name] = v
+		}
+	}
+
+	return params
+}
+
+// key returns hash of call at resolved block
+func (m message) key(block string) string {
+	return "call/" + hex.EncodeToString(ethclient.Keccak256([]byte(strings.Join([]string{m.to, m.data, block, m.from, m.gas, m.value}, "|"))))
+}
@@ -0,0 +1,61 @@
+package call
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+	"net/http"
+	"testing"
+)
+
+const token = "0x6b175474e89094c44da98b954eedeac495271d0f"
+
+func TestParseMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		obj  string
+		want message
+		err  string
+	}{
+		{
+			name: "full",
+			obj:  `{"to":"` + token + `","data":"0x70A08231","from":"` + token + `","gas":"0x5208","value":"0xA"}`,
+			want: message{to: token, data: "0x70a08231", from: token, gas: "0x5208", value: "0xa", block: "latest"},
+		},
+		{
+			name: "input alias",
+			obj:  `{"to":"` + token + `","input":"0x01"}`,
+			want: message{to: token, data: "0x01", block: "latest"},
+		},
+		{name: "missing object", obj: `"0x01"`, err: "call object is missing"},
+		{name: "missing to", obj: `{"data":"0x01"}`, err: "address"},
+		{name: "invalid from", obj: `{"to":"` + token + `","from":"0x01"}`, err: "address"},
+		{name: "odd data", obj: `{"to":"` + token + `","data":"0x012"}`, err: "data '0x012' is not valid hex data"},
+		{name: "leading zero gas", obj: `{"to":"` + token + `","gas":"0x01"}`, err: "gas '0x01' is not valid hex quantity"},
+		{name: "decimal value", obj: `{"to":"` + token + `","value":"10"}`, err: "value '10' is not valid hex quantity"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := parseMessage(gjson.Parse(tt.obj), "latest")
+			if tt.err != "" {
+				if assert.IsType(t, &echo.HTTPError{}, err) {
+					assert.Equal(t, http.StatusBadRequest, err.(*echo.HTTPError).Code)
+					assert.Contains(t, err.(*echo.HTTPError).Message, tt.err)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, m)
+		})
+	}
+}
+
+func TestMessage_ParamsAndKey(t *testing.T) {
+	m := message{to: token, data: "0x01"}
+	assert.Equal(t, map[string]string{"to": token, "data": "0x01"}, m.params())
+
+	assert.Equal(t, m.key("0x1"), m.key("0x1"))
+	assert.NotEqual(t, m.key("0x1"), m.key("0x2"))
+	assert.NotEqual(t, m.key("0x1"), message{to: token, data: "0x01", from: token}.key("0x1"))
+}
@@ -0,0 +1,135 @@
+package call
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/divilla/ethproxy/config"
+	"github.com/divilla/ethproxy/interfaces"
+	"github.com/divilla/ethproxy/pkg/blockcache"
+	"github.com/divilla/ethproxy/pkg/ethclient"
+	"github.com/divilla/ethproxy/pkg/logging"
+	"github.com/divilla/ethproxy/pkg/tracing"
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel/attribute"
+	"net/http"
+	"sync"
+)
+
+const (
+	CacheHit       = "HIT"
+	CacheMiss      = "MISS"
+	CacheCoalesced = "COALESCED"
+	CacheBypass    = "BYPASS"
+)
+
+type (
+	service struct {
+		client   interfaces.EthereumHttpClient
+		cache    interfaces.ResponseCacher
+		logger   interfaces.Logger
+		inflight map[string]*inflight
+		mx       sync.Mutex
+	}
+
+	// inflight is upstream call awaited by concurrent requests with the same key
+	inflight struct {
+		done chan struct{}
+		json []byte
+		err  error
+	}
+
+	result struct {
+		json   []byte
+		status string
+		block  string
+		key    string
+	}
+)
+
+func Service(client interfaces.EthereumHttpClient, cache interfaces.ResponseCacher, logger interfaces.Logger) *service {
+	return &service{
+		client:   client,
+		cache:    cache,
+		logger:   logger,
+		inflight: make(map[string]*inflight),
+	}
+}
+
+// call executes eth_call pinned to concrete block number. Calls at finalized blocks are cached permanently,
+// calls at 'latest' for duration of one block, concurrent calls with the same key are coalesced.
+func (s *service) call(ctx context.Context, m message) (*result, error) {
+	block, ttl, err := blockcache.ResolveBlock(ctx, m.block, s.client, config.CallLatestTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &result{
+		block: block,
+		key:   m.key(block),
+	}
+
+	if ttl == 0 {
+		res.status = CacheBypass
+		res.json, err = s.fetch(ctx, m, block)
+		return res, err
+	}
+
+	if json, err := s.cache.Get(ctx, res.key); err == nil {
+		res.status = CacheHit
+		res.json = json
+		return res, nil
+	}
+
+	s.mx.Lock()
+	if f, ok := s.inflight[res.key]; ok {
+		s.mx.Unlock()
+		_, span := tracing.Start(ctx, "call.inflight", attribute.String("call.key", res.key))
+		<-f.done
+		tracing.End(span, f.err)
+
+		res.status = CacheCoalesced
+		res.json = f.json
+		return res, f.err
+	}
+
+	f := &inflight{done: make(chan struct{})}
+	s.inflight[res.key] = f
+	s.mx.Unlock()
+
+	f.json, f.err = s.fetch(ctx, m, block)
+	if f.err == nil {
+		if err = s.cache.Put(ctx, res.key, f.json, ttl); err != nil {
+			logging.FromContext(ctx, s.logger).Error(err)
+		}
+	}
+
+	s.mx.Lock()
+	delete(s.inflight, res.key)
+	s.mx.Unlock()
+	close(f.done)
+
+	res.status = CacheMiss
+	res.json = f.json
+	return res, f.err
+}
+
+// fetch returns call result as json string, reverted call is returned as bad request with revert data.
+// Upstream error is kept as internal error, so json RPC clients receive it as returned by upstream.
+func (s *service) fetch(ctx context.Context, m message, block string) ([]byte, error) {
+	value, err := s.client.CallContract(ctx, m.params(), block)
+	if err == nil {
+		return []byte(fmt.Sprintf(`"%s"`, value)), nil
+	}
+
+	var rpcErr *ethclient.RPCError
+	if errors.As(err, &rpcErr) {
+		if data := rpcErr.Data(); data != "" {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, echo.Map{"message": rpcErr.Message(), "data": data}).SetInternal(rpcErr)
+		}
+		return nil, echo.NewHTTPError(http.StatusBadRequest, rpcErr.Message()).SetInternal(rpcErr)
+	}
+
+	logging.FromContext(ctx, s.logger).Error(err)
+	return nil, echo.NewHTTPError(http.StatusBadGateway, fmt.Sprintf("unable to execute call to '%s' at block '%s'", m.to, block))
+}
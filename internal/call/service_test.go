@@ -0,0 +1,160 @@
+package call
+
+import (
+	"context"
+	"fmt"
+	"github.com/divilla/ethproxy/internal/rpc"
+	"github.com/divilla/ethproxy/internal/testutil"
+	"github.com/divilla/ethproxy/pkg/cmiddleware"
+	"github.com/divilla/ethproxy/pkg/ethclient"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const revertData = "0x08c379a00000000000000000000000000000000000000000000000000000000000000020"
+
+// fakeClient returns block of call as its result, calls with data '0xdead' revert.
+// When gate is set, calls wait for it to be closed.
+type fakeClient struct {
+	testutil.Client
+	calls   int32
+	started chan struct{}
+	gate    chan struct{}
+}
+
+func (c *fakeClient) CallContract(_ context.Context, msg map[string]string, block string) ([]byte, error) {
+	atomic.AddInt32(&c.calls, 1)
+	if c.gate != nil {
+		c.started <- struct{}{}
+		<-c.gate
+	}
+	if msg["data"] == "0xdead" {
+		return nil, fmt.Errorf("json RPC response error: %w", &ethclient.RPCError{Raw: `{"code":3,"message":"execution reverted","data":"` + revertData + `"}`})
+	}
+
+	return []byte(block), nil
+}
+
+func newClient() *fakeClient {
+	return &fakeClient{Client: testutil.Client{Latest: 100, Safe: 90, Finalized: 80}}
+}
+
+func newService(t *testing.T, client *fakeClient) *service {
+	logger := testutil.Logger()
+	return Service(client, testutil.ResponseCache(t, logger), logger)
+}
+
+func TestService_Call(t *testing.T) {
+	client := newClient()
+	s := newService(t, client)
+
+	tests := []struct {
+		name   string
+		block  string
+		status string
+		json   string
+		calls  int32
+	}{
+		{"latest miss", "", CacheMiss, `"0x64"`, 1},
+		{"latest hit", "latest", CacheHit, `"0x64"`, 0},
+		{"finalized miss", "70", CacheMiss, `"0x46"`, 1},
+		{"finalized hit", "0x46", CacheHit, `"0x46"`, 0},
+		{"relative tag", "latest-30", CacheHit, `"0x46"`, 0},
+		{"pending bypass", "pending", CacheBypass, `"pending"`, 1},
+		{"pending is never cached", "pending", CacheBypass, `"pending"`, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atomic.StoreInt32(&client.calls, 0)
+			res, err := s.call(context.Background(), message{to: token, data: "0x01", block: tt.block})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.status, res.status)
+			assert.Equal(t, tt.json, string(res.json))
+			assert.Equal(t, tt.calls, atomic.LoadInt32(&client.calls))
+		})
+	}
+
+	for _, block := range []string{"101", "abc", "latest-200"} {
+		_, err := s.call(context.Background(), message{to: token, block: block})
+		assert.IsType(t, &ethclient.BlockError{}, err, block)
+	}
+}
+
+func TestService_CallCoalesced(t *testing.T) {
+	client := newClient()
+	client.started, client.gate = make(chan struct{}, 1), make(chan struct{})
+	s := newService(t, client)
+	m := message{to: token, data: "0x01", block: "50"}
+
+	results := make([]*result, 2)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		results[0], _ = s.call(context.Background(), m)
+	}()
+	<-client.started
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		results[1], _ = s.call(context.Background(), m)
+	}()
+	assert.Eventually(t, func() bool {
+		s.mx.Lock()
+		defer s.mx.Unlock()
+		return s.inflight[m.key("0x32")] != nil
+	}, time.Second, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	close(client.gate)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&client.calls))
+	assert.Equal(t, CacheMiss, results[0].status)
+	assert.Equal(t, CacheCoalesced, results[1].status)
+	assert.Equal(t, `"0x32"`, string(results[1].json))
+}
+
+func TestController_Call(t *testing.T) {
+	cache := testutil.ResponseCache(t, testutil.Logger())
+
+	e := echo.New()
+	e.HTTPErrorHandler = cmiddleware.HTTPErrorHandler
+	server := rpc.New()
+	Controller(e, server, newClient(), cache)
+	rpc.Controller(e, server)
+
+	post := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := post("/call", `{"to":"`+token+`","data":"0x01","block":"60"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"result":"0x3c"}`, rec.Body.String())
+	assert.Equal(t, CacheMiss, rec.Header().Get(HeaderCache))
+	assert.Equal(t, "0x3c", rec.Header().Get(HeaderCacheBlock))
+
+	rec = post("/call?block=60", `{"to":"`+token+`","data":"0x01"}`)
+	assert.Equal(t, CacheHit, rec.Header().Get(HeaderCache))
+
+	// revert data is returned to client
+	rec = post("/call", `{"to":"`+token+`","data":"0xdead"}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.JSONEq(t, `{"message":"execution reverted","data":"`+revertData+`"}`, rec.Body.String())
+
+	rec = post("/rpc", `{"jsonrpc":"2.0","id":7,"method":"eth_call","params":[{"to":"`+token+`","data":"0xdead"},"latest"]}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":7,"error":{"code":3,"message":"execution reverted","data":"`+revertData+`"}}`, rec.Body.String())
+	assert.Equal(t, CacheMiss, rec.Header().Get(HeaderCache))
+}
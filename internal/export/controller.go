@@ -0,0 +1,43 @@
+package export
+
+import (
+	"github.com/divilla/ethproxy/interfaces"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+type (
+	controller struct {
+		service *service
+		logger  interfaces.Logger
+	}
+)
+
+func Controller(e *echo.Echo, service *service) {
+	c := &controller{
+		service: service,
+		logger:  e.Logger,
+	}
+
+	e.POST("/export", c.start)
+	e.GET("/export/:id", c.get)
+}
+
+// start starts or resumes export of block range to block & transaction tables
+func (c *controller) start(ctx echo.Context) error {
+	p, err := c.service.start(ctx.QueryParam("from"), ctx.QueryParam("to"), ctx.QueryParam("format"))
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(http.StatusAccepted, p)
+}
+
+func (c *controller) get(ctx echo.Context) error {
+	p, err := c.service.get(ctx.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(http.StatusOK, p)
+}
@@ -0,0 +1,133 @@
+package export
+
+import (
+	"fmt"
+	"github.com/divilla/ethproxy/config"
+	"github.com/divilla/ethproxy/interfaces"
+	"github.com/divilla/ethproxy/pkg/blockexport"
+	"github.com/divilla/ethproxy/pkg/ethclient"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"path/filepath"
+	"sync"
+)
+
+type (
+	service struct {
+		source  interfaces.BlockSource
+		tracker interfaces.FinalityTracker
+		logger  interfaces.Logger
+		jobs    map[string]*job
+		mx      sync.Mutex
+	}
+
+	job struct {
+		*blockexport.Job
+		running bool
+	}
+
+	progress struct {
+		ID      string `json:"id"`
+		Running bool   `json:"running"`
+		blockexport.Progress
+	}
+)
+
+func Service(source interfaces.BlockSource, tracker interfaces.FinalityTracker, logger interfaces.Logger) *service {
+	return &service{
+		source:  source,
+		tracker: tracker,
+		logger:  logger,
+		jobs:    make(map[string]*job),
+	}
+}
+
+// start starts new export job or resumes interrupted job with the same range & format
+func (s *service) start(froms, tos, format string) (*progress, error) {
+	from, err := parseBlockNumber(froms)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := parseBlockNumber(tos)
+	if err != nil {
+		return nil, err
+	}
+
+	if format == "" {
+		format = blockexport.FormatCSV
+	}
+	if latest := s.tracker.LatestBlockNumber(); to > latest {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("export range end '%d' is after latest block '%d'", to, latest))
+	}
+
+	id := fmt.Sprintf("%d-%d-%s", from, to, format)
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	j, ok := s.jobs[id]
+	if ok && j.running {
+		return s.progress(id, j), nil
+	}
+
+	bj, err := blockexport.NewJob(filepath.Join(config.ExportDir, id), from, to, format, config.ExportChunkSize, s.source, s.logger)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	j = &job{Job: bj, running: true}
+	s.jobs[id] = j
+
+	go func() {
+		_ = j.Run()
+
+		s.mx.Lock()
+		j.running = false
+		s.mx.Unlock()
+	}()
+
+	return s.progress(id, j), nil
+}
+
+func (s *service) get(id string) (*progress, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	j, ok := s.jobs[id]
+	if !ok {
+		return nil, echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("export job '%s' not found", id))
+	}
+
+	return s.progress(id, j), nil
+}
+
+func (s *service) progress(id string, j *job) *progress {
+	return &progress{
+		ID:       id,
+		Running:  j.running,
+		Progress: j.Progress(),
+	}
+}
+
+// Done stops all running jobs, they are resumed when started again
+func (s *service) Done() {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	for _, j := range s.jobs {
+		j.Stop()
+	}
+}
+
+func parseBlockNumber(nrs string) (uint64, error) {
+	bp, err := ethclient.ParseBlockParam(nrs)
+	if err != nil {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if bp.IsTag() {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("export block number '%s' must be decimal or hex number", nrs))
+	}
+
+	return bp.Number, nil
+}
@@ -0,0 +1,32 @@
+package fees
+
+import (
+	"github.com/divilla/ethproxy/interfaces"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+type (
+	controller struct {
+		service *service
+		logger  interfaces.Logger
+	}
+)
+
+func Controller(e *echo.Echo, blocks interfaces.BlockSource, client interfaces.EthereumHttpClient) {
+	c := &controller{
+		service: Service(blocks, client, e.Logger),
+		logger:  e.Logger,
+	}
+
+	e.GET("/fees", c.getFees)
+}
+
+func (c *controller) getFees(ctx echo.Context) error {
+	json, err := c.service.fees(ctx.Request().Context())
+	if err != nil {
+		return err
+	}
+
+	return ctx.Blob(http.StatusOK, echo.MIMEApplicationJSON, json)
+}
@@ -0,0 +1,275 @@
+package fees
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/divilla/ethproxy/config"
+	"github.com/divilla/ethproxy/interfaces"
+	"github.com/divilla/ethproxy/pkg/ethclient"
+	"github.com/divilla/ethproxy/pkg/logging"
+	"github.com/labstack/echo/v4"
+	"github.com/tidwall/gjson"
+	"math/big"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+var percentiles = []int{config.FeesSlowPercentile, config.FeesStandardPercentile, config.FeesFastPercentile}
+
+type (
+	service struct {
+		blocks interfaces.BlockSource
+		client interfaces.EthereumHttpClient
+		logger interfaces.Logger
+		head   uint64
+		json   []byte
+		mx     sync.Mutex
+	}
+
+	// oracle holds fee suggestions in wei for block after BlockNumber, EIP-1559 fields are empty before London
+	oracle struct {
+		BlockNumber   uint64     `json:"block_number"`
+		Blocks        int        `json:"blocks"`
+		Transactions  int        `json:"transactions"`
+		BaseFeePerGas string     `json:"base_fee_per_gas,omitempty"`
+		FeeHistory    bool       `json:"fee_history"`
+		Slow          suggestion `json:"slow"`
+		Standard      suggestion `json:"standard"`
+		Fast          suggestion `json:"fast"`
+	}
+
+	suggestion struct {
+		GasPrice             string `json:"gas_price"`
+		MaxFeePerGas         string `json:"max_fee_per_gas,omitempty"`
+		MaxPriorityFeePerGas string `json:"max_priority_fee_per_gas,omitempty"`
+	}
+)
+
+func Service(blocks interfaces.BlockSource, client interfaces.EthereumHttpClient, logger interfaces.Logger) *service {
+	return &service{
+		blocks: blocks,
+		client: client,
+		logger: logger,
+	}
+}
+
+// fees returns fee suggestions, they are computed once per new head and concurrent requests wait for computation.
+// If computation fails, suggestions of previous head are returned.
+func (s *service) fees(ctx context.Context) ([]byte, error) {
+	head := s.client.LatestBlockNumber()
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if s.json != nil && s.head == head {
+		return s.json, nil
+	}
+
+	json, err := s.compute(ctx, head)
+	if err != nil {
+		if s.json != nil {
+			logging.FromContext(ctx, s.logger).Error(err)
+			return s.json, nil
+		}
+		return nil, err
+	}
+
+	s.head = head
+	s.json = json
+	return json, nil
+}
+
+// compute suggests priority fees from percentiles of priority fees paid in recent blocks, optionally averaged
+// with eth_feeHistory percentiles. Max fee is twice the next base fee plus priority fee, so it survives few full blocks.
+// Blocks before London get gas price percentiles only.
+func (s *service) compute(ctx context.Context, head uint64) ([]byte, error) {
+	from := uint64(0)
+	if head+1 > config.FeesBlocks {
+		from = head + 1 - config.FeesBlocks
+	}
+
+	blocks, err := s.blocks.GetBlocks(ctx, from, head, true)
+	if err != nil {
+		logging.FromContext(ctx, s.logger).Error(err)
+		return nil, echo.NewHTTPError(http.StatusBadGateway, fmt.Sprintf("unable to fetch blocks %d-%d", from, head))
+	}
+
+	o := oracle{
+		BlockNumber: head,
+		Blocks:      len(blocks),
+	}
+
+	var prices, priorities []*big.Int
+	for _, block := range blocks {
+		baseFee := quantity(gjson.GetBytes(block, "baseFeePerGas"))
+		gjson.GetBytes(block, "transactions").ForEach(func(key, tx gjson.Result) bool {
+			o.Transactions++
+			if price := quantity(tx.Get("gasPrice")); price != nil {
+				prices = append(prices, price)
+			}
+			if baseFee != nil {
+				priorities = append(priorities, priorityFee(tx, baseFee))
+			}
+			return true
+		})
+	}
+
+	latest := gjson.ParseBytes(blocks[len(blocks)-1])
+	baseFee := nextBaseFee(latest)
+
+	var history []*big.Int
+	if baseFee != nil && config.FeesHistory {
+		history, baseFee = s.history(ctx, head, baseFee)
+		o.FeeHistory = history != nil
+	}
+
+	levels := make([]suggestion, len(percentiles))
+	for i, p := range percentiles {
+		if baseFee == nil {
+			levels[i].GasPrice = percentile(prices, p).String()
+			continue
+		}
+
+		priority := percentile(priorities, p)
+		if history != nil {
+			if len(priorities) == 0 {
+				priority = history[i]
+			} else {
+				priority = new(big.Int).Rsh(new(big.Int).Add(priority, history[i]), 1)
+			}
+		}
+
+		maxFee := new(big.Int).Lsh(baseFee, 1)
+		levels[i] = suggestion{
+			GasPrice:             new(big.Int).Add(baseFee, priority).String(),
+			MaxFeePerGas:         maxFee.Add(maxFee, priority).String(),
+			MaxPriorityFeePerGas: priority.String(),
+		}
+	}
+
+	if baseFee != nil {
+		o.BaseFeePerGas = baseFee.String()
+	}
+	o.Slow, o.Standard, o.Fast = levels[0], levels[1], levels[2]
+
+	return json.Marshal(o)
+}
+
+// history returns median of eth_feeHistory reward percentiles across blocks with next base fee reported by upstream.
+// On failure it returns nil with given base fee.
+func (s *service) history(ctx context.Context, head uint64, nextBaseFee *big.Int) ([]*big.Int, *big.Int) {
+	ps := make([]float64, len(percentiles))
+	for i, p := range percentiles {
+		ps[i] = float64(p)
+	}
+
+	res, err := s.client.FeeHistory(ctx, config.FeesBlocks, ethclient.UIntToHex(head), ps)
+	if err != nil {
+		logging.FromContext(ctx, s.logger).Errorf("unable to fetch fee history at block '%d': %v", head, err)
+		return nil, nextBaseFee
+	}
+
+	rewards := make([][]*big.Int, len(percentiles))
+	gjson.GetBytes(res, "reward").ForEach(func(key, block gjson.Result) bool {
+		for i := range percentiles {
+			if reward := quantity(block.Get(fmt.Sprint(i))); reward != nil {
+				rewards[i] = append(rewards[i], reward)
+			}
+		}
+		return true
+	})
+
+	history := make([]*big.Int, len(percentiles))
+	for i := range percentiles {
+		if len(rewards[i]) == 0 {
+			return nil, nextBaseFee
+		}
+		history[i] = percentile(rewards[i], 50)
+	}
+
+	// last base fee is of the block after newest block
+	if baseFee := quantity(gjson.GetBytes(res, "baseFeePerGas|@reverse|0")); baseFee != nil {
+		nextBaseFee = baseFee
+	}
+
+	return history, nextBaseFee
+}
+
+// priorityFee returns priority fee paid by transaction over block base fee
+func priorityFee(tx gjson.Result, baseFee *big.Int) *big.Int {
+	fee := quantity(tx.Get("gasPrice"))
+	if fee == nil {
+		fee = new(big.Int)
+	}
+	fee = new(big.Int).Sub(fee, baseFee)
+
+	if maxPriority := quantity(tx.Get("maxPriorityFeePerGas")); maxPriority != nil && maxPriority.Cmp(fee) < 0 {
+		fee = maxPriority
+	}
+	if fee.Sign() < 0 {
+		fee = new(big.Int)
+	}
+
+	return fee
+}
+
+// nextBaseFee computes EIP-1559 base fee of block after given block, it's nil before London
+func nextBaseFee(block gjson.Result) *big.Int {
+	baseFee := quantity(block.Get("baseFeePerGas"))
+	gasUsed := quantity(block.Get("gasUsed"))
+	gasLimit := quantity(block.Get("gasLimit"))
+	if baseFee == nil || gasUsed == nil || gasLimit == nil {
+		return nil
+	}
+
+	target := new(big.Int).Rsh(gasLimit, 1)
+	if target.Sign() == 0 || gasUsed.Cmp(target) == 0 {
+		return baseFee
+	}
+
+	delta := new(big.Int).Sub(gasUsed, target)
+	delta.Abs(delta)
+	delta.Mul(delta, baseFee)
+	delta.Div(delta, target)
+	delta.Div(delta, big.NewInt(8))
+
+	if gasUsed.Cmp(target) > 0 {
+		if delta.Sign() == 0 {
+			delta.SetInt64(1)
+		}
+		return delta.Add(baseFee, delta)
+	}
+
+	return delta.Sub(baseFee, delta)
+}
+
+// percentile returns p-th percentile of values, zero if there are none
+func percentile(values []*big.Int, p int) *big.Int {
+	if len(values) == 0 {
+		return new(big.Int)
+	}
+
+	sorted := make([]*big.Int, len(values))
+	copy(sorted, values)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Cmp(sorted[j]) < 0
+	})
+
+	return new(big.Int).Set(sorted[(len(sorted)-1)*p/100])
+}
+
+// quantity parses hex quantity, it's nil when missing or invalid
+func quantity(r gjson.Result) *big.Int {
+	if !r.Exists() || r.String() == "" {
+		return nil
+	}
+
+	i, err := ethclient.HexToBigInt(r.String())
+	if err != nil {
+		return nil
+	}
+
+	return &i
+}
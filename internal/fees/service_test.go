@@ -0,0 +1,121 @@
+package fees
+
+import (
+	"context"
+	"errors"
+	"github.com/divilla/ethproxy/config"
+	"github.com/divilla/ethproxy/interfaces"
+	"github.com/divilla/ethproxy/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+	"math/big"
+	"testing"
+)
+
+// fakeClient returns fee history result, upstream error when it's empty
+type fakeClient struct {
+	interfaces.EthereumHttpClient
+	history     string
+	blocks      uint64
+	newest      string
+	percentiles []float64
+}
+
+func (c *fakeClient) FeeHistory(_ context.Context, blocks uint64, newest string, percentiles []float64) ([]byte, error) {
+	c.blocks, c.newest, c.percentiles = blocks, newest, percentiles
+	if c.history == "" {
+		return nil, errors.New("method eth_feeHistory not found")
+	}
+
+	return []byte(c.history), nil
+}
+
+func TestNextBaseFee(t *testing.T) {
+	tests := []struct {
+		name     string
+		block    string
+		expected *big.Int
+	}{
+		{"at target", `{"baseFeePerGas":"0x3b9aca00","gasUsed":"0xe4e1c0","gasLimit":"0x1c9c380"}`, big.NewInt(1000000000)},
+		{"full block", `{"baseFeePerGas":"0x3b9aca00","gasUsed":"0x1c9c380","gasLimit":"0x1c9c380"}`, big.NewInt(1125000000)},
+		{"empty block", `{"baseFeePerGas":"0x3b9aca00","gasUsed":"0x0","gasLimit":"0x1c9c380"}`, big.NewInt(875000000)},
+		{"above target", `{"baseFeePerGas":"0x3b9aca00","gasUsed":"0x1312d00","gasLimit":"0x1c9c380"}`, big.NewInt(1041666666)},
+		{"below target", `{"baseFeePerGas":"0x3b9aca00","gasUsed":"0x989680","gasLimit":"0x1c9c380"}`, big.NewInt(958333334)},
+		{"minimal increase", `{"baseFeePerGas":"0x7","gasUsed":"0xe4e1c1","gasLimit":"0x1c9c380"}`, big.NewInt(8)},
+		{"zero gas limit", `{"baseFeePerGas":"0x7","gasUsed":"0x0","gasLimit":"0x0"}`, big.NewInt(7)},
+		{"before london", `{"gasUsed":"0x0","gasLimit":"0x1c9c380"}`, nil},
+		{"invalid quantity", `{"baseFeePerGas":"0xzz","gasUsed":"0x0","gasLimit":"0x1c9c380"}`, nil},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.expected, nextBaseFee(gjson.Parse(test.block)), test.name)
+	}
+}
+
+func TestPriorityFee(t *testing.T) {
+	tests := []struct {
+		name     string
+		tx       string
+		baseFee  int64
+		expected int64
+	}{
+		{"legacy", `{"type":"0x0","gasPrice":"0x1e"}`, 20, 10},
+		{"legacy below base fee", `{"type":"0x0","gasPrice":"0xa"}`, 20, 0},
+		{"legacy before london", `{"gasPrice":"0x1e"}`, 0, 30},
+		{"access list", `{"type":"0x1","gasPrice":"0x16"}`, 20, 2},
+		{"dynamic fee", `{"type":"0x2","gasPrice":"0x16","maxFeePerGas":"0x64","maxPriorityFeePerGas":"0x2"}`, 20, 2},
+		{"dynamic fee capped by max fee", `{"type":"0x2","gasPrice":"0x15","maxFeePerGas":"0x15","maxPriorityFeePerGas":"0x5"}`, 20, 1},
+		{"dynamic fee over effective price", `{"type":"0x2","gasPrice":"0x1e","maxFeePerGas":"0x64","maxPriorityFeePerGas":"0x5"}`, 20, 5},
+		{"missing gas price", `{"type":"0x0"}`, 20, 0},
+	}
+	for _, test := range tests {
+		assert.Equal(t, big.NewInt(test.expected).String(), priorityFee(gjson.Parse(test.tx), big.NewInt(test.baseFee)).String(), test.name)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	values := []*big.Int{big.NewInt(50), big.NewInt(10), big.NewInt(40), big.NewInt(20), big.NewInt(30)}
+	for p, expected := range map[int]int64{0: 10, 10: 10, 25: 20, 50: 30, 60: 30, 75: 40, 100: 50} {
+		assert.Equal(t, big.NewInt(expected).String(), percentile(values, p).String(), p)
+	}
+	assert.Equal(t, big.NewInt(50), values[0], "values aren't sorted in place")
+
+	assert.Equal(t, "0", percentile(nil, 50).String())
+	assert.Equal(t, big.NewInt(7), percentile([]*big.Int{big.NewInt(7)}, 90))
+}
+
+func TestService_History(t *testing.T) {
+	next := big.NewInt(100)
+	tests := []struct {
+		name     string
+		history  string
+		expected []int64
+		baseFee  int64
+	}{
+		{"upstream error", ``, nil, 100},
+		{"missing reward", `{"baseFeePerGas":["0x1","0x2"]}`, nil, 100},
+		{"empty reward", `{"reward":[],"baseFeePerGas":["0x1","0x2"]}`, nil, 100},
+		{"empty block rewards", `{"reward":[[],[]],"baseFeePerGas":["0x1","0x2","0x3"]}`, nil, 100},
+		{"median of blocks", `{"reward":[["0x1","0x5","0x9"],["0x3","0x6","0xc"],["0x2","0x4","0xa"]],"baseFeePerGas":["0x1","0x2","0x3","0x4"]}`, []int64{2, 5, 10}, 4},
+		{"invalid rewards are skipped", `{"reward":[["0x1","0xzz","0x9"],["0x3","0x6","0xc"]],"baseFeePerGas":["0x1","0x2","0x3"]}`, []int64{1, 6, 9}, 3},
+		{"missing base fee", `{"reward":[["0x1","0x2","0x3"]]}`, []int64{1, 2, 3}, 100},
+		{"empty base fee", `{"reward":[["0x1","0x2","0x3"]],"baseFeePerGas":[]}`, []int64{1, 2, 3}, 100},
+		{"invalid last base fee", `{"reward":[["0x1","0x2","0x3"]],"baseFeePerGas":["0x1","0xzz"]}`, []int64{1, 2, 3}, 100},
+	}
+
+	logger := testutil.Logger()
+	for _, test := range tests {
+		client := &fakeClient{history: test.history}
+		history, baseFee := Service(nil, client, logger).history(context.Background(), 255, next)
+
+		var expected []*big.Int
+		for _, v := range test.expected {
+			expected = append(expected, big.NewInt(v))
+		}
+		assert.Equal(t, expected, history, test.name)
+		assert.Equal(t, big.NewInt(test.baseFee), baseFee, test.name)
+		assert.Equal(t, uint64(config.FeesBlocks), client.blocks, test.name)
+		assert.Equal(t, "0xff", client.newest, test.name)
+		assert.Len(t, client.percentiles, len(percentiles), test.name)
+	}
+	assert.Equal(t, big.NewInt(100), next, "given base fee isn't modified")
+}
@@ -0,0 +1,45 @@
+package logs
+
+import (
+	"github.com/divilla/ethproxy/interfaces"
+	"github.com/divilla/ethproxy/internal/rpc"
+	"github.com/divilla/ethproxy/pkg/cmiddleware"
+	"github.com/labstack/echo/v4"
+	"github.com/tidwall/gjson"
+)
+
+type (
+	controller struct {
+		service *service
+		logger  interfaces.Logger
+	}
+)
+
+func Controller(e *echo.Echo, server *rpc.Server, client interfaces.EthereumHttpClient, cache interfaces.ResponseCacher, maxRange uint64) {
+	c := &controller{
+		service: Service(client, cache, e.Logger, maxRange),
+		logger:  e.Logger,
+	}
+
+	e.GET("/logs", c.getLogs)
+	server.Register("eth_getLogs", c.rpcGetLogs)
+}
+
+func (c *controller) getLogs(ctx echo.Context) error {
+	f, err := parseQuery(ctx)
+	if err != nil {
+		return err
+	}
+
+	json, err := c.service.getLogs(ctx.Request().Context(), f)
+	return cmiddleware.WriteJSON(ctx, json, err)
+}
+
+func (c *controller) rpcGetLogs(ctx echo.Context, params gjson.Result) ([]byte, error) {
+	f, err := parseParams(params)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.service.getLogs(ctx.Request().Context(), f)
+}
@@ -0,0 +1,121 @@
+package logs
+
+import (
+	"fmt"
+	"github.com/divilla/ethproxy/pkg/ethclient"
+	"github.com/labstack/echo/v4"
+	"github.com/tidwall/gjson"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+var topicRegexp = regexp.MustCompile(`^0x[0-9a-fA-F]{64}$`)
+
+type (
+	// filter is eth_getLogs filter with block range given as block numbers or tags
+	filter struct {
+		from      string
+		to        string
+		addresses []string
+		topics    [][]string
+	}
+)
+
+// parseQuery parses 'from', 'to', 'address' & 'topic0'-'topic3' query parameters, lists are comma separated
+func parseQuery(ctx echo.Context) (filter, error) {
+	f := filter{
+		from: ctx.QueryParam("from"),
+		to:   ctx.QueryParam("to"),
+	}
+
+	if v := ctx.QueryParam("address"); v != "" {
+		for _, a := range strings.Split(v, ",") {
+			address, err := ethclient.ParseAddress(strings.TrimSpace(a))
+			if err != nil {
+				return f, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			}
+			f.addresses = append(f.addresses, address)
+		}
+	}
+
+	for i := 0; i < 4; i++ {
+		var topics []string
+		if v := ctx.QueryParam(fmt.Sprintf("topic%d", i)); v != "" {
+			topics = strings.Split(v, ",")
+		}
+		f.topics = append(f.topics, topics)
+	}
+
+	return f, f.validate()
+}
+
+// parseParams parses eth_getLogs filter object
+func parseParams(params gjson.Result) (filter, error) {
+	obj := params.Get("0")
+	if !obj.IsObject() {
+		return filter{}, echo.NewHTTPError(http.StatusBadRequest, "filter object is missing")
+	}
+	if obj.Get("blockHash").Exists() {
+		return filter{}, echo.NewHTTPError(http.StatusBadRequest, "blockHash filter is not supported, use block range")
+	}
+
+	f := filter{
+		from: obj.Get("fromBlock").String(),
+		to:   obj.Get("toBlock").String(),
+	}
+
+	address := obj.Get("address")
+	if address.Type == gjson.String {
+		address = gjson.Parse(fmt.Sprintf("[%s]", address.Raw))
+	}
+	for _, a := range address.Array() {
+		addr, err := ethclient.ParseAddress(a.String())
+		if err != nil {
+			return f, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		f.addresses = append(f.addresses, addr)
+	}
+
+	for _, t := range obj.Get("topics").Array() {
+		var topics []string
+		if t.IsArray() {
+			for _, tt := range t.Array() {
+				topics = append(topics, tt.String())
+			}
+		} else if t.Type == gjson.String {
+			topics = []string{t.String()}
+		}
+		f.topics = append(f.topics, topics)
+	}
+
+	return f, f.validate()
+}
+
+func (f *filter) validate() error {
+	if len(f.topics) > 4 {
+		return echo.NewHTTPError(http.StatusBadRequest, "filter can't have more than 4 topics")
+	}
+
+	for i := range f.topics {
+		for j, t := range f.topics[i] {
+			t = strings.TrimSpace(t)
+			if !topicRegexp.MatchString(t) {
+				return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("topic '%s' is not valid 32 byte hex value", t))
+			}
+			f.topics[i][j] = strings.ToLower(t)
+		}
+	}
+
+	// trailing wildcard topics are redundant
+	for len(f.topics) > 0 && len(f.topics[len(f.topics)-1]) == 0 {
+		f.topics = f.topics[:len(f.topics)-1]
+	}
+
+	return nil
+}
+
+// key returns cache key of filter for given block range
+func (f filter) key(from, to uint64) string {
+	return fmt.Sprintf("logs/%v/%v/%d-%d", f.addresses, f.topics, from, to)
+}
@@ -0,0 +1,206 @@
+package logs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/divilla/ethproxy/config"
+	"github.com/divilla/ethproxy/interfaces"
+	"github.com/divilla/ethproxy/pkg/blockcache"
+	"github.com/divilla/ethproxy/pkg/ethclient"
+	"github.com/divilla/ethproxy/pkg/logging"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"sync/atomic"
+)
+
+type (
+	service struct {
+		client   interfaces.EthereumHttpClient
+		cache    interfaces.ResponseCacher
+		logger   interfaces.Logger
+		maxRange uint64
+		// span is the largest block range upstream is expected to accept, it's halved when upstream rejects range
+		// due to its size and grows by spanGrowth after accepted ranges, so it recovers after dense block ranges
+		span uint64
+	}
+)
+
+// spanGrowth is fraction of span added after upstream accepts range of full span
+const spanGrowth = 8
+
+// Service creates logs service, queries can span at most maxRange blocks
+func Service(client interfaces.EthereumHttpClient, cache interfaces.ResponseCacher, logger interfaces.Logger, maxRange uint64) *service {
+	return &service{
+		client:   client,
+		cache:    cache,
+		logger:   logger,
+		maxRange: maxRange,
+		span:     config.LogsChunkSize,
+	}
+}
+
+// getLogs splits block range into chunks aligned to config.LogsChunkSize, so chunks of finalized blocks
+// can be cached and reused by overlapping queries. Results are merged in block order.
+func (s *service) getLogs(ctx context.Context, f filter) ([]byte, error) {
+	from, err := ethclient.ResolveBlockNumber(ctx, orLatest(f.from), s.client, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := ethclient.ResolveBlockNumber(ctx, orLatest(f.to), s.client, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = ethclient.ValidateBlockRange(from, to, s.maxRange, s.client.LatestBlockNumber()); err != nil {
+		return nil, err
+	}
+
+	res := []byte(`[]`)
+	chunk := uint64(config.LogsChunkSize)
+	for start := from; start <= to; {
+		end := (start/chunk+1)*chunk - 1
+		if end > to {
+			end = to
+		}
+
+		logs, err := s.getChunk(ctx, f, start, end)
+		if err != nil {
+			return nil, err
+		}
+		res = appendArray(res, logs)
+
+		if end == to {
+			break
+		}
+		start = end + 1
+	}
+
+	return res, nil
+}
+
+// getChunk returns cached chunk, chunks of finalized blocks are cached permanently
+func (s *service) getChunk(ctx context.Context, f filter, from, to uint64) ([]byte, error) {
+	key := f.key(from, to)
+	if json, err := s.cache.Get(ctx, key); err == nil {
+		return json, nil
+	}
+
+	json, err := s.fetch(ctx, f, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	if blockcache.BlockExpires(to, s.client) == blockcache.Permanent {
+		if err = s.cache.Put(ctx, key, json, blockcache.Permanent); err != nil {
+			logging.FromContext(ctx, s.logger).Error(err)
+		}
+	}
+
+	return json, nil
+}
+
+// fetch splits block range into ranges of learned span, so requests after upstream rejected large range
+// don't start from full range again
+func (s *service) fetch(ctx context.Context, f filter, from, to uint64) ([]byte, error) {
+	res := []byte(`[]`)
+	for start := from; start <= to; {
+		end := to
+		if span := atomic.LoadUint64(&s.span); end-start >= span {
+			end = start + span - 1
+		}
+
+		logs, err := s.split(ctx, f, start, end)
+		if err != nil {
+			return nil, err
+		}
+		res = appendArray(res, logs)
+
+		if end == to {
+			break
+		}
+		start = end + 1
+	}
+
+	return res, nil
+}
+
+// split splits block range in halves while upstream rejects it due to its range or result size
+func (s *service) split(ctx context.Context, f filter, from, to uint64) ([]byte, error) {
+	json, err := s.client.GetLogs(ctx, f.addresses, f.topics, from, to)
+	if err == nil {
+		s.learn(to-from+1, true)
+		return json, nil
+	}
+
+	if !ethclient.IsLimitError(err) || from == to {
+		logging.FromContext(ctx, s.logger).Error(err)
+		return nil, echo.NewHTTPError(http.StatusBadGateway, fmt.Sprintf("unable to fetch logs in block range '%d-%d'", from, to))
+	}
+	s.learn(to-from+1, false)
+
+	mid := from + (to-from)/2
+	left, err := s.split(ctx, f, from, mid)
+	if err != nil {
+		return nil, err
+	}
+
+	right, err := s.split(ctx, f, mid+1, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return appendArray(left, right), nil
+}
+
+// learn halves span below rejected range size, accepted range of at least span size grows it up to config.LogsChunkSize
+func (s *service) learn(size uint64, accepted bool) {
+	for {
+		span := atomic.LoadUint64(&s.span)
+		next := span
+		switch {
+		case !accepted && size <= span:
+			next = size / 2
+			if next == 0 {
+				next = 1
+			}
+		case accepted && size >= span:
+			next = span + span/spanGrowth + 1
+			if next > config.LogsChunkSize {
+				next = config.LogsChunkSize
+			}
+		}
+		if next == span || atomic.CompareAndSwapUint64(&s.span, span, next) {
+			return
+		}
+	}
+}
+
+// orLatest returns block parameter, 'latest' is default
+func orLatest(nrs string) string {
+	if nrs == "" {
+		return ethclient.TagLatest
+	}
+
+	return nrs
+}
+
+// appendArray appends elements of src json array to dst json array
+func appendArray(dst, src []byte) []byte {
+	src = bytes.TrimSpace(src)
+	if len(src) <= 2 {
+		return dst
+	}
+
+	dst = bytes.TrimSpace(dst)
+	if len(dst) <= 2 {
+		return src
+	}
+
+	res := make([]byte, 0, len(dst)+len(src))
+	res = append(res, dst[:len(dst)-1]...)
+	res = append(res, ',')
+
+	return append(res, src[1:]...)
+}
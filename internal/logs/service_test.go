@@ -0,0 +1,150 @@
+package logs
+
+import (
+	"context"
+	"fmt"
+	"github.com/divilla/ethproxy/internal/testutil"
+	"github.com/divilla/ethproxy/pkg/ethclient"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+// fakeClient returns single log per block, ranges larger than limit are rejected like by upstream
+type fakeClient struct {
+	testutil.Client
+	limit uint64
+	err   error
+	calls [][2]uint64
+	mx    sync.Mutex
+}
+
+func (c *fakeClient) GetLogs(_ context.Context, _ []string, _ [][]string, from, to uint64) ([]byte, error) {
+	c.mx.Lock()
+	c.calls = append(c.calls, [2]uint64{from, to})
+	c.mx.Unlock()
+
+	if c.err != nil {
+		return nil, c.err
+	}
+	if to-from+1 > c.limit {
+		return nil, fmt.Errorf("json RPC response error: %w", &ethclient.RPCError{Raw: `{"code":-32005,"message":"query returned more than 10000 results"}`})
+	}
+
+	res := "["
+	for nr := from; nr <= to; nr++ {
+		if nr > from {
+			res += ","
+		}
+		res += fmt.Sprintf(`{"blockNumber":"%s"}`, ethclient.UIntToHex(nr))
+	}
+
+	return []byte(res + "]"), nil
+}
+
+func (c *fakeClient) reset() [][2]uint64 {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	calls := c.calls
+	c.calls = nil
+	return calls
+}
+
+func newService(t *testing.T, client *fakeClient) *service {
+	logger := testutil.Logger()
+	return Service(client, testutil.ResponseCache(t, logger), logger, 100000)
+}
+
+func blockNumbers(t *testing.T, json []byte) []uint64 {
+	var nrs []uint64
+	for _, l := range gjson.ParseBytes(json).Array() {
+		nr, err := ethclient.HexToUInt(l.Get("blockNumber").String())
+		assert.NoError(t, err)
+		nrs = append(nrs, nr)
+	}
+
+	return nrs
+}
+
+func TestService_GetLogs(t *testing.T) {
+	client := &fakeClient{Client: testutil.Client{Latest: 10000, Safe: 9000, Finalized: 9000}, limit: 30}
+	s := newService(t, client)
+
+	// range crossing chunk boundary is split to aligned chunks & rejected ranges in halves, merged in block order
+	json, err := s.getLogs(context.Background(), filter{from: "1950", to: "2049"})
+	assert.NoError(t, err)
+	nrs := blockNumbers(t, json)
+	if assert.Len(t, nrs, 100) {
+		for i, nr := range nrs {
+			assert.Equal(t, uint64(1950+i), nr)
+		}
+	}
+	first := client.reset()
+	assert.Equal(t, [2]uint64{1950, 1999}, first[0])
+
+	// finalized chunks are cached
+	_, err = s.getLogs(context.Background(), filter{from: "1950", to: "2049"})
+	assert.NoError(t, err)
+	assert.Empty(t, client.reset())
+
+	// learned span is reused, so upstream rejects at most one range of the next request
+	json, err = s.getLogs(context.Background(), filter{from: "3000", to: "3099"})
+	assert.NoError(t, err)
+	assert.Len(t, blockNumbers(t, json), 100)
+	rejected := 0
+	for _, c := range client.reset() {
+		if c[1]-c[0]+1 > client.limit {
+			rejected++
+		}
+	}
+	assert.LessOrEqual(t, rejected, 1)
+}
+
+func TestService_GetLogsErrors(t *testing.T) {
+	client := &fakeClient{Client: testutil.Client{Latest: 10000, Safe: 9000, Finalized: 9000}, limit: 30, err: errors.New("connection refused")}
+	s := newService(t, client)
+
+	// errors other than range limits aren't split
+	_, err := s.getLogs(context.Background(), filter{from: "0", to: "99"})
+	if assert.IsType(t, &echo.HTTPError{}, err) {
+		assert.Equal(t, http.StatusBadGateway, err.(*echo.HTTPError).Code)
+	}
+	assert.Len(t, client.reset(), 1)
+
+	tests := []filter{
+		{from: "100", to: "99"},
+		{from: "0", to: "100000"},
+		{from: "0", to: "10001"},
+		{from: "abc", to: "1"},
+	}
+	for _, f := range tests {
+		_, err = s.getLogs(context.Background(), f)
+		assert.IsType(t, &ethclient.BlockError{}, err, f)
+	}
+	assert.Empty(t, client.reset())
+}
+
+func TestService_Learn(t *testing.T) {
+	s := &service{span: 2000}
+
+	s.learn(2000, false)
+	assert.Equal(t, uint64(1000), s.span)
+
+	// rejection of range larger than span doesn't shrink it
+	s.learn(1500, false)
+	assert.Equal(t, uint64(1000), s.span)
+
+	s.learn(1, false)
+	assert.Equal(t, uint64(1), s.span)
+
+	// accepted ranges grow span back to chunk size
+	for i := 0; i < 100; i++ {
+		s.learn(s.span, true)
+	}
+	assert.Equal(t, uint64(2000), s.span)
+}
@@ -0,0 +1,34 @@
+package receipts
+
+import (
+	"github.com/divilla/ethproxy/interfaces"
+	"github.com/divilla/ethproxy/pkg/cmiddleware"
+	"github.com/labstack/echo/v4"
+)
+
+type (
+	controller struct {
+		service *service
+		logger  interfaces.Logger
+	}
+)
+
+func Controller(e *echo.Echo, blocks interfaces.BlockSource, client interfaces.EthereumHttpClient, cache interfaces.BlockCacher) {
+	c := &controller{
+		service: Service(blocks, client, cache, e.Logger),
+		logger:  e.Logger,
+	}
+
+	e.GET("/block/:bnr/receipts", c.getReceipts)
+	e.GET("/block/:bnr/transaction/:tid/receipt", c.getReceipt)
+}
+
+func (c *controller) getReceipts(ctx echo.Context) error {
+	json, err := c.service.getReceipts(ctx.Request().Context(), ctx.Param("bnr"))
+	return cmiddleware.WriteJSON(ctx, json, err)
+}
+
+func (c *controller) getReceipt(ctx echo.Context) error {
+	json, err := c.service.getReceipt(ctx.Request().Context(), ctx.Param("bnr"), ctx.Param("tid"))
+	return cmiddleware.WriteJSON(ctx, json, err)
+}
@@ -0,0 +1,179 @@
+package receipts
+
+import (
+	"context"
+	"fmt"
+	"github.com/divilla/ethproxy/config"
+	"github.com/divilla/ethproxy/interfaces"
+	"github.com/divilla/ethproxy/pkg/blockcache"
+	"github.com/divilla/ethproxy/pkg/ethclient"
+	"github.com/divilla/ethproxy/pkg/logging"
+	"github.com/labstack/echo/v4"
+	"github.com/tidwall/gjson"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type (
+	service struct {
+		blocks interfaces.BlockSource
+		client interfaces.EthereumHttpClient
+		cache  interfaces.BlockCacher
+		logger interfaces.Logger
+		// unsupported is unix nano time when upstream reported eth_getBlockReceipts unsupported, 0 if it's supported
+		unsupported int64
+	}
+)
+
+func Service(blocks interfaces.BlockSource, client interfaces.EthereumHttpClient, cache interfaces.BlockCacher, logger interfaces.Logger) *service {
+	return &service{
+		blocks: blocks,
+		client: client,
+		cache:  cache,
+		logger: logger,
+	}
+}
+
+// getReceipts returns receipts of all block transactions as json array
+func (s *service) getReceipts(ctx context.Context, nrs string) ([]byte, error) {
+	nr, err := ethclient.ResolveBlockNumber(ctx, nrs, s.client, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.receipts(ctx, nr)
+}
+
+// getReceipt returns receipt of transaction by block number & transaction index, served from block receipts
+func (s *service) getReceipt(ctx context.Context, nrs, trs string) ([]byte, error) {
+	nr, err := ethclient.ResolveBlockNumber(ctx, nrs, s.client, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	tri, err := strconv.ParseUint(trs, 10, 64)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("transaction index '%s' is not valid unsigned integer", trs))
+	}
+
+	json, err := s.receipts(ctx, nr)
+	if err != nil {
+		return nil, err
+	}
+
+	receipt := gjson.GetBytes(json, strconv.FormatUint(tri, 10))
+	if !receipt.Exists() {
+		return nil, echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("transaction with index '%d' in block '%d' not found", tri, nr))
+	}
+
+	return []byte(receipt.Raw), nil
+}
+
+// receipts returns cached block receipts, on miss they are fetched with eth_getBlockReceipts and cached with block TTL.
+// When upstream doesn't support the method, receipts are fetched per transaction in parallel until config.ReceiptsReprobe
+// passes and the method is tried again.
+func (s *service) receipts(ctx context.Context, nr uint64) ([]byte, error) {
+	if json, err := s.cache.Get(ctx, nr); err == nil {
+		return json, nil
+	}
+
+	if latest := s.client.LatestBlockNumber(); nr > latest {
+		return nil, echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("block with number '%d' not found", nr))
+	}
+
+	var json []byte
+	var err error
+	supported := s.supported()
+	if supported {
+		json, err = s.client.GetBlockReceipts(ctx, ethclient.UIntToHex(nr))
+		if ethclient.IsMethodNotFound(err) {
+			logging.FromContext(ctx, s.logger).Infof("upstream doesn't support eth_getBlockReceipts, falling back to transaction receipts: %v", err)
+			atomic.StoreInt64(&s.unsupported, time.Now().UnixNano())
+			supported = false
+		} else if err == nil {
+			atomic.StoreInt64(&s.unsupported, 0)
+		}
+	}
+	if !supported {
+		json, err = s.transactionReceipts(ctx, nr)
+	}
+
+	if err != nil {
+		if _, ok := err.(*echo.HTTPError); ok {
+			return nil, err
+		}
+		logging.FromContext(ctx, s.logger).Error(err)
+		return nil, echo.NewHTTPError(http.StatusBadGateway, fmt.Sprintf("unable to fetch receipts of block '%d'", nr))
+	}
+	if len(json) == 0 {
+		return nil, echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("block with number '%d' not found", nr))
+	}
+
+	if err = s.cache.Put(ctx, nr, json, blockcache.BlockExpires(nr, s.client)); err != nil {
+		logging.FromContext(ctx, s.logger).Error(err)
+	}
+
+	return json, nil
+}
+
+// supported returns true if eth_getBlockReceipts is supported or it's time to probe it again
+func (s *service) supported() bool {
+	unsupported := atomic.LoadInt64(&s.unsupported)
+	return unsupported == 0 || time.Since(time.Unix(0, unsupported)) >= config.ReceiptsReprobe
+}
+
+// transactionReceipts fetches receipts of block transactions in parallel, result is json array in transaction order
+func (s *service) transactionReceipts(ctx context.Context, nr uint64) ([]byte, error) {
+	blocks, err := s.blocks.GetBlocks(ctx, nr, nr, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// cached full block holds transaction objects, header only block transaction hashes
+	var hashes []string
+	gjson.GetBytes(blocks[0], "transactions").ForEach(func(key, tx gjson.Result) bool {
+		if tx.IsObject() {
+			hashes = append(hashes, tx.Get("hash").String())
+		} else {
+			hashes = append(hashes, tx.String())
+		}
+		return true
+	})
+
+	receipts := make([]string, len(hashes))
+	errs := make(chan error, len(hashes))
+	sem := make(chan struct{}, config.ReceiptsParallelism)
+	var wg sync.WaitGroup
+	for i, hash := range hashes {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, hash string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			receipt, err := s.client.GetTransactionReceipt(ctx, hash)
+			if err == nil && len(receipt) == 0 {
+				err = fmt.Errorf("receipt of transaction '%s' in block '%d' not found", hash, nr)
+			}
+			if err != nil {
+				errs <- err
+				return
+			}
+			receipts[i] = string(receipt)
+		}(i, hash)
+	}
+	wg.Wait()
+	close(errs)
+
+	if err = <-errs; err != nil {
+		return nil, err
+	}
+
+	return []byte("[" + strings.Join(receipts, ",") + "]"), nil
+}
@@ -0,0 +1,144 @@
+package receipts
+
+import (
+	"context"
+	"fmt"
+	"github.com/divilla/ethproxy/config"
+	"github.com/divilla/ethproxy/internal/testutil"
+	"github.com/divilla/ethproxy/pkg/ethclient"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeClient serves receipts of blocks with 3 transactions, blockReceiptsErr is returned by eth_getBlockReceipts
+type fakeClient struct {
+	testutil.Client
+	blockReceiptsErr error
+	calls            map[string]int
+	mx               sync.Mutex
+}
+
+func (c *fakeClient) count(method string) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	c.calls[method]++
+}
+
+func (c *fakeClient) called(method string) int {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	n := c.calls[method]
+	c.calls[method] = 0
+	return n
+}
+
+func (c *fakeClient) GetBlockReceipts(_ context.Context, block string) ([]byte, error) {
+	c.count("eth_getBlockReceipts")
+	if c.blockReceiptsErr != nil {
+		return nil, c.blockReceiptsErr
+	}
+
+	return []byte(fmt.Sprintf(`[{"blockNumber":"%s","transactionIndex":"0x0"},{"transactionIndex":"0x1"},{"transactionIndex":"0x2"}]`, block)), nil
+}
+
+func (c *fakeClient) GetTransactionReceipt(_ context.Context, hash string) ([]byte, error) {
+	c.count("eth_getTransactionReceipt")
+	return []byte(fmt.Sprintf(`{"transactionHash":"%s"}`, hash)), nil
+}
+
+type fakeBlocks struct{}
+
+func (fakeBlocks) GetBlocks(_ context.Context, from, _ uint64, _ bool) ([][]byte, error) {
+	return [][]byte{[]byte(fmt.Sprintf(`{"number":"%s","transactions":["0xa","0xb","0xc"]}`, ethclient.UIntToHex(from)))}, nil
+}
+
+func newService(t *testing.T, client *fakeClient) *service {
+	logger := testutil.Logger()
+	client.Client = testutil.Client{Latest: 100, Safe: 90, Finalized: 80}
+	client.calls = make(map[string]int)
+
+	return Service(fakeBlocks{}, client, testutil.BlockCache(t, logger, 100), logger)
+}
+
+func rpcError(raw string) error {
+	return fmt.Errorf("json RPC response error: %w", &ethclient.RPCError{Raw: raw})
+}
+
+func TestService_GetReceipts(t *testing.T) {
+	client := &fakeClient{}
+	s := newService(t, client)
+
+	json, err := s.getReceipts(context.Background(), "50")
+	assert.NoError(t, err)
+	assert.Contains(t, string(json), `"blockNumber":"0x32"`)
+	assert.Equal(t, 1, client.called("eth_getBlockReceipts"))
+
+	// receipts are cached & single receipt is served from them
+	json, err = s.getReceipt(context.Background(), "50", "2")
+	assert.NoError(t, err)
+	assert.Equal(t, `{"transactionIndex":"0x2"}`, string(json))
+	assert.Equal(t, 0, client.called("eth_getBlockReceipts"))
+
+	tests := []struct {
+		nr     string
+		tri    string
+		status int
+	}{
+		{"50", "3", http.StatusNotFound},
+		{"50", "x", http.StatusBadRequest},
+		{"101", "0", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		_, err = s.getReceipt(context.Background(), tt.nr, tt.tri)
+		if assert.IsType(t, &echo.HTTPError{}, err, tt) {
+			assert.Equal(t, tt.status, err.(*echo.HTTPError).Code, tt)
+		}
+	}
+
+	for _, nr := range []string{"pending", "abc"} {
+		_, err = s.getReceipt(context.Background(), nr, "0")
+		assert.IsType(t, &ethclient.BlockError{}, err, nr)
+	}
+}
+
+func TestService_Unsupported(t *testing.T) {
+	// missing block isn't mistaken for unsupported method
+	client := &fakeClient{blockReceiptsErr: rpcError(`{"code":-32000,"message":"block does not exist"}`)}
+	s := newService(t, client)
+
+	_, err := s.getReceipts(context.Background(), "60")
+	if assert.IsType(t, &echo.HTTPError{}, err) {
+		assert.Equal(t, http.StatusBadGateway, err.(*echo.HTTPError).Code)
+	}
+	assert.True(t, s.supported())
+	assert.Equal(t, 1, client.called("eth_getBlockReceipts"))
+
+	// unsupported method falls back to transaction receipts in transaction order
+	client.blockReceiptsErr = rpcError(`{"code":-32601,"message":"the method eth_getBlockReceipts does not exist/is not available"}`)
+	json, err := s.getReceipts(context.Background(), "61")
+	assert.NoError(t, err)
+	assert.Equal(t, `[{"transactionHash":"0xa"},{"transactionHash":"0xb"},{"transactionHash":"0xc"}]`, string(json))
+	assert.Equal(t, 1, client.called("eth_getBlockReceipts"))
+	assert.Equal(t, 3, client.called("eth_getTransactionReceipt"))
+
+	_, err = s.getReceipts(context.Background(), "62")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, client.called("eth_getBlockReceipts"))
+	assert.Equal(t, 3, client.called("eth_getTransactionReceipt"))
+
+	// method is probed again after config.ReceiptsReprobe
+	client.blockReceiptsErr = nil
+	atomic.StoreInt64(&s.unsupported, time.Now().Add(-config.ReceiptsReprobe).UnixNano())
+	_, err = s.getReceipts(context.Background(), "63")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, client.called("eth_getBlockReceipts"))
+	assert.Equal(t, 0, client.called("eth_getTransactionReceipt"))
+	assert.True(t, s.supported())
+}
@@ -0,0 +1,160 @@
+package rpc
+
+import (
+	"errors"
+	"fmt"
+	"github.com/divilla/ethproxy/config"
+	"github.com/divilla/ethproxy/interfaces"
+	"github.com/divilla/ethproxy/pkg/ethclient"
+	"github.com/divilla/ethproxy/pkg/logging"
+	"github.com/labstack/echo/v4"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeServerError    = -32000
+	codeLimitExceeded  = -32005
+)
+
+type (
+	controller struct {
+		server *Server
+		logger interfaces.Logger
+	}
+)
+
+func Controller(e *echo.Echo, server *Server) {
+	c := &controller{
+		server: server,
+		logger: e.Logger,
+	}
+
+	e.POST("/rpc", c.rpc)
+}
+
+// rpc executes single or batch json RPC request, notifications are executed without response
+// and if there's nothing to respond with 204 No Content is returned
+func (c *controller) rpc(ctx echo.Context) error {
+	body, err := ioutil.ReadAll(ctx.Request().Body)
+	if err != nil {
+		return err
+	}
+
+	var res string
+	req := gjson.ParseBytes(body)
+	switch {
+	case !gjson.ValidBytes(body):
+		res = errorResponse("null", codeParseError, "parse error")
+	case req.IsArray() && len(req.Array()) == 0:
+		res = errorResponse("null", codeInvalidRequest, "invalid request")
+	case req.IsArray() && len(req.Array()) > config.RPCMaxBatch:
+		res = errorResponse("null", codeInvalidRequest, fmt.Sprintf("batch of %d requests exceeds limit of %d", len(req.Array()), config.RPCMaxBatch))
+	case req.IsArray():
+		responses := make([]string, 0, len(req.Array()))
+		for _, r := range req.Array() {
+			if rr := c.execute(ctx, r); rr != "" {
+				responses = append(responses, rr)
+			}
+		}
+		if len(responses) > 0 {
+			res = "[" + strings.Join(responses, ",") + "]"
+		}
+	default:
+		res = c.execute(ctx, req)
+	}
+
+	if res == "" {
+		return ctx.NoContent(http.StatusNoContent)
+	}
+
+	return ctx.Blob(http.StatusOK, echo.MIMEApplicationJSON, []byte(res))
+}
+
+// execute returns json RPC response of request, notification (request without id) is executed and its response is empty
+func (c *controller) execute(ctx echo.Context, req gjson.Result) string {
+	id := req.Get("id").Raw
+	if id == "" {
+		id = "null"
+	}
+
+	method := req.Get("method")
+	if !req.IsObject() || method.Type != gjson.String {
+		return errorResponse(id, codeInvalidRequest, "invalid request")
+	}
+
+	res := c.call(ctx, id, method.String(), req.Get("params"))
+	if !req.Get("id").Exists() {
+		return ""
+	}
+
+	return res
+}
+
+func (c *controller) call(ctx echo.Context, id, method string, params gjson.Result) string {
+	h, ok := c.server.handler(method)
+	if !ok {
+		return errorResponse(id, codeMethodNotFound, fmt.Sprintf("method '%s' not found", method))
+	}
+
+	var result []byte
+	err := c.server.check(ctx, method)
+	if err == nil {
+		result, err = h(ctx, params)
+	}
+	// upstream error, i.e. reverted call with its data, is passed to client as returned by upstream
+	var rpcErr *ethclient.RPCError
+	if errors.As(err, &rpcErr) && gjson.Valid(rpcErr.Raw) {
+		return fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"error":%s}`, id, rpcErr.Raw)
+	}
+	var blockErr *ethclient.BlockError
+	if errors.As(err, &blockErr) {
+		return errorResponse(id, codeInvalidParams, blockErr.Error())
+	}
+	if err != nil {
+		if he, ok := err.(*echo.HTTPError); ok {
+			code := codeServerError
+			switch he.Code {
+			case http.StatusBadRequest:
+				code = codeInvalidParams
+			case http.StatusTooManyRequests:
+				code = codeLimitExceeded
+			}
+			return errorResponse(id, code, fmt.Sprint(he.Message))
+		}
+
+		logging.FromContext(ctx.Request().Context(), c.logger).Errorf("json RPC method '%s' failed with error: %v", method, err)
+		return errorResponse(id, codeServerError, http.StatusText(http.StatusInternalServerError))
+	}
+	if len(result) == 0 {
+		result = []byte("null")
+	}
+
+	return fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"result":%s}`, id, result)
+}
+
+func errorResponse(id string, code int, message string) string {
+	res, err := sjson.SetRaw(`{"jsonrpc":"2.0"}`, "id", id)
+	if err != nil {
+		panic(err)
+	}
+
+	res, err = sjson.Set(res, "error.code", code)
+	if err != nil {
+		panic(err)
+	}
+
+	res, err = sjson.Set(res, "error.message", message)
+	if err != nil {
+		panic(err)
+	}
+
+	return res
+}
@@ -0,0 +1,59 @@
+package rpc
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestController_Rpc(t *testing.T) {
+	var calls int
+	e := echo.New()
+	server := New()
+	server.Register("echo", func(ctx echo.Context, params gjson.Result) ([]byte, error) {
+		calls++
+		return []byte(params.Raw), nil
+	})
+	Controller(e, server)
+
+	tests := []struct {
+		name  string
+		body  string
+		code  int
+		json  string
+		calls int
+	}{
+		{"single", `{"jsonrpc":"2.0","id":1,"method":"echo","params":[1]}`, http.StatusOK,
+			`{"jsonrpc":"2.0","id":1,"result":[1]}`, 1},
+		{"parse error", `{"jsonrpc"`, http.StatusOK,
+			`{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"parse error"}}`, 0},
+		{"empty batch", `[]`, http.StatusOK,
+			`{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"invalid request"}}`, 0},
+		{"invalid batch item", `[1]`, http.StatusOK,
+			`[{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"invalid request"}}]`, 0},
+		{"notification", `{"jsonrpc":"2.0","method":"echo","params":[1]}`, http.StatusNoContent, ``, 1},
+		{"notification of unknown method", `{"jsonrpc":"2.0","method":"none"}`, http.StatusNoContent, ``, 0},
+		{"batch of notifications", `[{"jsonrpc":"2.0","method":"echo"},{"jsonrpc":"2.0","method":"echo"}]`, http.StatusNoContent, ``, 2},
+		{"batch with notification", `[{"jsonrpc":"2.0","method":"echo","params":[1]},{"jsonrpc":"2.0","id":2,"method":"echo","params":[2]},{"jsonrpc":"2.0","id":null,"method":"none"}]`, http.StatusOK,
+			`[{"jsonrpc":"2.0","id":2,"result":[2]},{"jsonrpc":"2.0","id":null,"error":{"code":-32601,"message":"method 'none' not found"}}]`, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = 0
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(tt.body)))
+			assert.Equal(t, tt.code, rec.Code)
+			if tt.json == "" {
+				assert.Empty(t, rec.Body.String())
+			} else {
+				assert.JSONEq(t, tt.json, rec.Body.String())
+			}
+			assert.Equal(t, tt.calls, calls)
+		})
+	}
+}
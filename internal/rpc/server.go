@@ -0,0 +1,66 @@
+package rpc
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/tidwall/gjson"
+	"sync"
+)
+
+type (
+	// Handler executes json RPC method with given params and returns raw json result,
+	// headers set by handler are added to http response, in batch requests they are added in request order
+	Handler func(ctx echo.Context, params gjson.Result) ([]byte, error)
+
+	// Guard checks if json RPC method may be executed, error is returned as json RPC error
+	Guard func(ctx echo.Context, method string) error
+
+	// Server holds json RPC methods registered by features
+	Server struct {
+		methods map[string]Handler
+		guards  []Guard
+		rwm     sync.RWMutex
+	}
+)
+
+func New() *Server {
+	return &Server{
+		methods: make(map[string]Handler),
+	}
+}
+
+// Register adds json RPC method handler
+func (s *Server) Register(method string, handler Handler) {
+	s.rwm.Lock()
+	defer s.rwm.Unlock()
+
+	s.methods[method] = handler
+}
+
+// Guard adds guard executed before every json RPC method
+func (s *Server) Guard(guard Guard) {
+	s.rwm.Lock()
+	defer s.rwm.Unlock()
+
+	s.guards = append(s.guards, guard)
+}
+
+func (s *Server) check(ctx echo.Context, method string) error {
+	s.rwm.RLock()
+	defer s.rwm.RUnlock()
+
+	for _, g := range s.guards {
+		if err := g(ctx, method); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Server) handler(method string) (Handler, bool) {
+	s.rwm.RLock()
+	defer s.rwm.RUnlock()
+
+	h, ok := s.methods[method]
+	return h, ok
+}
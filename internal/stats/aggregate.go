@@ -0,0 +1,225 @@
+package stats
+
+import (
+	"github.com/divilla/ethproxy/pkg/ethclient"
+	"github.com/tidwall/gjson"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+type (
+	// aggregate accumulates statistics of consecutive blocks, block time is measured from parent timestamp
+	aggregate struct {
+		blocks       int
+		transactions int
+		gasUsed      uint64
+		gasLimit     uint64
+		baseFees     int
+		baseFeeSum   *big.Int
+		baseFeeMin   *big.Int
+		baseFeeMax   *big.Int
+		value        *big.Int
+		senders      map[string]struct{}
+		creations    int
+		parent       *uint64
+		blockTimes   map[uint64]int
+	}
+
+	rangeStats struct {
+		From                 uint64          `json:"from"`
+		To                   uint64          `json:"to"`
+		Blocks               int             `json:"blocks"`
+		Transactions         int             `json:"transactions"`
+		TransactionsPerBlock float64         `json:"transactions_per_block"`
+		GasUsed              uint64          `json:"gas_used"`
+		GasLimit             uint64          `json:"gas_limit"`
+		GasUtilization       float64         `json:"gas_utilization"`
+		BaseFeePerGas        *baseFeeStats   `json:"base_fee_per_gas,omitempty"`
+		Value                amount          `json:"value"`
+		UniqueSenders        int             `json:"unique_senders"`
+		ContractCreations    int             `json:"contract_creations"`
+		BlockTime            *blockTimeStats `json:"block_time,omitempty"`
+	}
+
+	blockStats struct {
+		Number            uint64  `json:"number"`
+		Hash              string  `json:"hash"`
+		Timestamp         uint64  `json:"timestamp"`
+		BlockTime         *uint64 `json:"block_time,omitempty"`
+		Transactions      int     `json:"transactions"`
+		GasUsed           uint64  `json:"gas_used"`
+		GasLimit          uint64  `json:"gas_limit"`
+		GasUtilization    float64 `json:"gas_utilization"`
+		BaseFeePerGas     *amount `json:"base_fee_per_gas,omitempty"`
+		Value             amount  `json:"value"`
+		UniqueSenders     int     `json:"unique_senders"`
+		ContractCreations int     `json:"contract_creations"`
+	}
+
+	baseFeeStats struct {
+		Min amount `json:"min"`
+		Avg amount `json:"avg"`
+		Max amount `json:"max"`
+	}
+
+	// blockTimeStats holds seconds between blocks, distribution counts blocks by their block time
+	blockTimeStats struct {
+		Min          uint64         `json:"min"`
+		Avg          float64        `json:"avg"`
+		Max          uint64         `json:"max"`
+		Distribution map[string]int `json:"distribution"`
+	}
+
+	// amount is wei value with its value in ether or gwei, like in decoded block format
+	amount struct {
+		Wei   string `json:"wei"`
+		Ether string `json:"ether,omitempty"`
+		Gwei  string `json:"gwei,omitempty"`
+	}
+)
+
+// newAggregate starts aggregate, parent is timestamp of block before the first one, nil for genesis
+func newAggregate(parent *uint64) *aggregate {
+	return &aggregate{
+		baseFeeSum: new(big.Int),
+		value:      new(big.Int),
+		senders:    make(map[string]struct{}),
+		parent:     parent,
+		blockTimes: make(map[uint64]int),
+	}
+}
+
+// add adds full block to statistics, blocks must be added in order
+func (a *aggregate) add(block gjson.Result) {
+	a.blocks++
+	a.gasUsed += quantity(block.Get("gasUsed"))
+	a.gasLimit += quantity(block.Get("gasLimit"))
+
+	if r := block.Get("baseFeePerGas"); r.Exists() {
+		if baseFee, err := ethclient.HexToBigInt(r.String()); err == nil {
+			a.baseFees++
+			a.baseFeeSum.Add(a.baseFeeSum, &baseFee)
+			if a.baseFeeMin == nil || baseFee.Cmp(a.baseFeeMin) < 0 {
+				a.baseFeeMin = new(big.Int).Set(&baseFee)
+			}
+			if a.baseFeeMax == nil || baseFee.Cmp(a.baseFeeMax) > 0 {
+				a.baseFeeMax = new(big.Int).Set(&baseFee)
+			}
+		}
+	}
+
+	ts := quantity(block.Get("timestamp"))
+	if a.parent != nil && ts >= *a.parent {
+		a.blockTimes[ts-*a.parent]++
+	}
+	a.parent = &ts
+
+	block.Get("transactions").ForEach(func(key, tx gjson.Result) bool {
+		a.transactions++
+		a.senders[strings.ToLower(tx.Get("from").String())] = struct{}{}
+
+		if to := tx.Get("to"); to.Type == gjson.Null || to.String() == "" {
+			a.creations++
+		}
+
+		if value, err := ethclient.HexToBigInt(tx.Get("value").String()); err == nil {
+			a.value.Add(a.value, &value)
+		}
+		return true
+	})
+}
+
+func (a *aggregate) rangeStats(from, to uint64) rangeStats {
+	s := rangeStats{
+		From:              from,
+		To:                to,
+		Blocks:            a.blocks,
+		Transactions:      a.transactions,
+		GasUsed:           a.gasUsed,
+		GasLimit:          a.gasLimit,
+		GasUtilization:    ratio(float64(a.gasUsed), float64(a.gasLimit)),
+		Value:             ether(a.value),
+		UniqueSenders:     len(a.senders),
+		ContractCreations: a.creations,
+	}
+	s.TransactionsPerBlock = ratio(float64(a.transactions), float64(a.blocks))
+
+	if a.baseFees > 0 {
+		avg := new(big.Int).Div(a.baseFeeSum, big.NewInt(int64(a.baseFees)))
+		s.BaseFeePerGas = &baseFeeStats{
+			Min: gwei(a.baseFeeMin),
+			Avg: gwei(avg),
+			Max: gwei(a.baseFeeMax),
+		}
+	}
+
+	if len(a.blockTimes) > 0 {
+		bt := &blockTimeStats{Distribution: make(map[string]int, len(a.blockTimes))}
+		var sum uint64
+		var count int
+		for seconds, n := range a.blockTimes {
+			if count == 0 || seconds < bt.Min {
+				bt.Min = seconds
+			}
+			if seconds > bt.Max {
+				bt.Max = seconds
+			}
+			sum += seconds * uint64(n)
+			count += n
+			bt.Distribution[strconv.FormatUint(seconds, 10)] = n
+		}
+		bt.Avg = ratio(float64(sum), float64(count))
+		s.BlockTime = bt
+	}
+
+	return s
+}
+
+func (a *aggregate) blockStats(block gjson.Result) blockStats {
+	s := blockStats{
+		Number:            quantity(block.Get("number")),
+		Hash:              block.Get("hash").String(),
+		Timestamp:         quantity(block.Get("timestamp")),
+		Transactions:      a.transactions,
+		GasUsed:           a.gasUsed,
+		GasLimit:          a.gasLimit,
+		GasUtilization:    ratio(float64(a.gasUsed), float64(a.gasLimit)),
+		Value:             ether(a.value),
+		UniqueSenders:     len(a.senders),
+		ContractCreations: a.creations,
+	}
+
+	if a.baseFeeMax != nil {
+		baseFee := gwei(a.baseFeeMax)
+		s.BaseFeePerGas = &baseFee
+	}
+
+	for seconds := range a.blockTimes {
+		blockTime := seconds
+		s.BlockTime = &blockTime
+	}
+
+	return s
+}
+
+func quantity(r gjson.Result) uint64 {
+	i, _ := ethclient.HexToUInt(r.String())
+	return i
+}
+
+func ratio(a, b float64) float64 {
+	if b == 0 {
+		return 0
+	}
+
+	return a / b
+}
+
+func ether(value *big.Int) amount {
+	return amount{Wei: value.String(), Ether: ethclient.FormatUnits(value, 18)}
+}
+
+func gwei(value *big.Int) amount {
+	return amount{Wei: value.String(), Gwei: ethclient.FormatUnits(value, 9)}
+}
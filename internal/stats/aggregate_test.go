@@ -0,0 +1,87 @@
+package stats
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+	"testing"
+)
+
+// blocks 10-12 after parent at 1000, block 10 is before London, block 12 is empty
+var fixture = []string{
+	`{"number":"0xa","hash":"0x0a","timestamp":"0x3f4","gasUsed":"0x3e8","gasLimit":"0x7d0","transactions":[
+		{"from":"0x00000000000000000000000000000000000000AA","to":"0x00000000000000000000000000000000000000bb","value":"0xde0b6b3a7640000"},
+		{"from":"0x00000000000000000000000000000000000000aa","to":null,"value":"0x0"}]}`,
+	`{"number":"0xb","hash":"0x0b","timestamp":"0x400","gasUsed":"0x7d0","gasLimit":"0x7d0","baseFeePerGas":"0x3b9aca00","transactions":[
+		{"from":"0x00000000000000000000000000000000000000bb","value":"0x6f05b59d3b20000"}]}`,
+	`{"number":"0xc","hash":"0x0c","timestamp":"0x40d","gasUsed":"0x0","gasLimit":"0x7d0","baseFeePerGas":"0x77359400","transactions":[]}`,
+}
+
+func TestAggregate_RangeStats(t *testing.T) {
+	parent := uint64(1000)
+	agg := newAggregate(&parent)
+	for _, block := range fixture {
+		agg.add(gjson.Parse(block))
+	}
+
+	assert.Equal(t, rangeStats{
+		From:                 10,
+		To:                   12,
+		Blocks:               3,
+		Transactions:         3,
+		TransactionsPerBlock: 1,
+		GasUsed:              3000,
+		GasLimit:             6000,
+		GasUtilization:       0.5,
+		BaseFeePerGas: &baseFeeStats{
+			Min: amount{Wei: "1000000000", Gwei: "1"},
+			Avg: amount{Wei: "1500000000", Gwei: "1.5"},
+			Max: amount{Wei: "2000000000", Gwei: "2"},
+		},
+		Value:             amount{Wei: "1500000000000000000", Ether: "1.5"},
+		UniqueSenders:     2,
+		ContractCreations: 2,
+		BlockTime: &blockTimeStats{
+			Min:          12,
+			Avg:          37.0 / 3,
+			Max:          13,
+			Distribution: map[string]int{"12": 2, "13": 1},
+		},
+	}, agg.rangeStats(10, 12))
+}
+
+func TestAggregate_Genesis(t *testing.T) {
+	// genesis has no parent, so there is no block time, empty range has no ratios
+	agg := newAggregate(nil)
+	assert.Equal(t, rangeStats{From: 0, To: 0, Value: amount{Wei: "0", Ether: "0"}}, agg.rangeStats(0, 0))
+
+	agg.add(gjson.Parse(fixture[0]))
+	s := agg.rangeStats(0, 0)
+	assert.Equal(t, 1, s.Blocks)
+	assert.Equal(t, 2.0, s.TransactionsPerBlock)
+	assert.Nil(t, s.BaseFeePerGas)
+	assert.Nil(t, s.BlockTime)
+}
+
+func TestAggregate_BlockStats(t *testing.T) {
+	parent := uint64(1012)
+	agg := newAggregate(&parent)
+	block := gjson.Parse(fixture[1])
+	agg.add(block)
+
+	blockTime := uint64(12)
+	baseFee := amount{Wei: "1000000000", Gwei: "1"}
+	assert.Equal(t, blockStats{
+		Number:            11,
+		Hash:              "0x0b",
+		Timestamp:         1024,
+		BlockTime:         &blockTime,
+		Transactions:      1,
+		GasUsed:           2000,
+		GasLimit:          2000,
+		GasUtilization:    1,
+		BaseFeePerGas:     &baseFee,
+		Value:             amount{Wei: "500000000000000000", Ether: "0.5"},
+		UniqueSenders:     1,
+		ContractCreations: 1,
+	}, agg.blockStats(block))
+}
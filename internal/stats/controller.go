@@ -0,0 +1,34 @@
+package stats
+
+import (
+	"github.com/divilla/ethproxy/interfaces"
+	"github.com/divilla/ethproxy/pkg/cmiddleware"
+	"github.com/labstack/echo/v4"
+)
+
+type (
+	controller struct {
+		service *service
+		logger  interfaces.Logger
+	}
+)
+
+func Controller(e *echo.Echo, blocks interfaces.BlockSource, client interfaces.EthereumHttpClient, cache interfaces.ResponseCacher) {
+	c := &controller{
+		service: Service(blocks, client, cache, e.Logger),
+		logger:  e.Logger,
+	}
+
+	e.GET("/stats/blocks", c.getRangeStats)
+	e.GET("/block/:bnr/stats", c.getBlockStats)
+}
+
+func (c *controller) getRangeStats(ctx echo.Context) error {
+	json, err := c.service.rangeStats(ctx.Request().Context(), ctx.QueryParam("from"), ctx.QueryParam("to"))
+	return cmiddleware.WriteJSON(ctx, json, err)
+}
+
+func (c *controller) getBlockStats(ctx echo.Context) error {
+	json, err := c.service.blockStats(ctx.Request().Context(), ctx.Param("bnr"))
+	return cmiddleware.WriteJSON(ctx, json, err)
+}
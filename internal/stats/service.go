@@ -0,0 +1,136 @@
+package stats
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/divilla/ethproxy/config"
+	"github.com/divilla/ethproxy/interfaces"
+	"github.com/divilla/ethproxy/pkg/blockcache"
+	"github.com/divilla/ethproxy/pkg/ethclient"
+	"github.com/divilla/ethproxy/pkg/logging"
+	"github.com/tidwall/gjson"
+)
+
+type (
+	service struct {
+		blocks interfaces.BlockSource
+		client interfaces.EthereumHttpClient
+		cache  interfaces.ResponseCacher
+		logger interfaces.Logger
+	}
+)
+
+func Service(blocks interfaces.BlockSource, client interfaces.EthereumHttpClient, cache interfaces.ResponseCacher, logger interfaces.Logger) *service {
+	return &service{
+		blocks: blocks,
+		client: client,
+		cache:  cache,
+		logger: logger,
+	}
+}
+
+// rangeStats aggregates blocks in range, they are read from cache and misses are fetched in window of parallel batches.
+// Statistics of finalized ranges are cached permanently.
+func (s *service) rangeStats(ctx context.Context, froms, tos string) ([]byte, error) {
+	from, err := ethclient.ResolveBlockNumber(ctx, froms, s.client, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := ethclient.ResolveBlockNumber(ctx, tos, s.client, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = ethclient.ValidateBlockRange(from, to, config.BlocksMaxRange, s.client.LatestBlockNumber()); err != nil {
+		return nil, err
+	}
+
+	key := fmt.Sprintf("stats/%d/%d", from, to)
+	if json, err := s.cache.Get(ctx, key); err == nil {
+		return json, nil
+	}
+
+	agg, err := s.aggregate(ctx, from)
+	if err != nil {
+		return nil, err
+	}
+
+	window := uint64(config.BlocksBatchSize * config.BlocksParallelism)
+	for start := from; start <= to; start += window {
+		end := start + window - 1
+		if end > to {
+			end = to
+		}
+
+		blocks, err := s.blocks.GetBlocks(ctx, start, end, true)
+		if err != nil {
+			return nil, err
+		}
+		for _, block := range blocks {
+			agg.add(gjson.ParseBytes(block))
+		}
+	}
+
+	return s.cached(ctx, key, to, agg.rangeStats(from, to))
+}
+
+// blockStats summarizes single block, summary of finalized block is cached permanently
+func (s *service) blockStats(ctx context.Context, nrs string) ([]byte, error) {
+	nr, err := ethclient.ResolveBlockNumber(ctx, nrs, s.client, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	key := fmt.Sprintf("stats/block/%d", nr)
+	if json, err := s.cache.Get(ctx, key); err == nil {
+		return json, nil
+	}
+
+	blocks, err := s.blocks.GetBlocks(ctx, nr, nr, true)
+	if err != nil {
+		return nil, err
+	}
+
+	agg, err := s.aggregate(ctx, nr)
+	if err != nil {
+		return nil, err
+	}
+
+	block := gjson.ParseBytes(blocks[0])
+	agg.add(block)
+
+	return s.cached(ctx, key, nr, agg.blockStats(block))
+}
+
+// aggregate starts aggregate with timestamp of parent block, so block time is known for the first block
+func (s *service) aggregate(ctx context.Context, from uint64) (*aggregate, error) {
+	if from == 0 {
+		return newAggregate(nil), nil
+	}
+
+	parents, err := s.blocks.GetBlocks(ctx, from-1, from-1, false)
+	if err != nil {
+		return nil, err
+	}
+
+	ts := quantity(gjson.GetBytes(parents[0], "timestamp"))
+	return newAggregate(&ts), nil
+}
+
+// cached marshals statistics, they are cached permanently if the last block is finalized
+func (s *service) cached(ctx context.Context, key string, last uint64, stats interface{}) ([]byte, error) {
+	body, err := json.Marshal(stats)
+	if err != nil {
+		return nil, err
+	}
+
+	if blockcache.BlockExpires(last, s.client) == blockcache.Permanent {
+		if err = s.cache.Put(ctx, key, body, blockcache.Permanent); err != nil {
+			logging.FromContext(ctx, s.logger).Error(err)
+		}
+	}
+
+	return body, nil
+}
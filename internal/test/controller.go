@@ -2,6 +2,7 @@ package test
 
 import (
 	"github.com/divilla/ethproxy/interfaces"
+	"github.com/divilla/ethproxy/pkg/logging"
 	"github.com/labstack/echo/v4"
 	"github.com/pkg/errors"
 	"net/http"
@@ -31,7 +32,7 @@ func (c *controller) panicRecover(ctx echo.Context) error {
 }
 
 func (c *controller) errorRecover(ctx echo.Context) error {
-	c.logger.Error(errors.New("some new error"))
+	logging.FromContext(ctx.Request().Context(), c.logger).Error(errors.New("some new error"))
 	return errors.New("invalid configuration")
 }
 
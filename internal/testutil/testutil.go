@@ -0,0 +1,48 @@
+// Package testutil holds fake client & service dependencies shared by feature tests
+package testutil
+
+import (
+	"github.com/divilla/ethproxy/interfaces"
+	"github.com/divilla/ethproxy/pkg/blockcache"
+	"github.com/divilla/ethproxy/pkg/rpccache"
+	"github.com/labstack/echo/v4"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+type (
+	// Client is fake ethereum client reporting fixed latest, safe & finalized block numbers. Feature tests embed it
+	// and implement only methods they use, calls to other methods panic.
+	Client struct {
+		interfaces.EthereumHttpClient
+		Latest    uint64
+		Safe      uint64
+		Finalized uint64
+	}
+)
+
+func (c *Client) LatestBlockNumber() uint64    { return c.Latest }
+func (c *Client) SafeBlockNumber() uint64      { return c.Safe }
+func (c *Client) FinalizedBlockNumber() uint64 { return c.Finalized }
+
+// Logger returns echo logger with discarded output
+func Logger() echo.Logger {
+	logger := echo.New().Logger
+	logger.SetOutput(ioutil.Discard)
+	return logger
+}
+
+// ResponseCache returns response cache disposed at the end of test
+func ResponseCache(t *testing.T, logger interfaces.Logger) *rpccache.ResponseCache {
+	cache := rpccache.New(logger, 100, time.Minute)
+	t.Cleanup(cache.Done)
+	return cache
+}
+
+// BlockCache returns block cache of given capacity disposed at the end of test
+func BlockCache(t *testing.T, logger interfaces.Logger, capacity int) *blockcache.EthereumBlockCache {
+	cache := blockcache.New(logger, capacity, time.Minute)
+	t.Cleanup(cache.Done)
+	return cache
+}
@@ -0,0 +1,32 @@
+package timestamp
+
+import (
+	"github.com/divilla/ethproxy/interfaces"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+type (
+	controller struct {
+		service *service
+		logger  interfaces.Logger
+	}
+)
+
+func Controller(e *echo.Echo, blocks interfaces.BlockSource, client interfaces.EthereumHttpClient, cache interfaces.ResponseCacher) {
+	c := &controller{
+		service: Service(blocks, client, cache, e.Logger),
+		logger:  e.Logger,
+	}
+
+	e.GET("/block/at/:timestamp", c.getBlockAt)
+}
+
+func (c *controller) getBlockAt(ctx echo.Context) error {
+	json, err := c.service.blockAt(ctx.Request().Context(), ctx.Param("timestamp"), ctx.QueryParam("mode"))
+	if err != nil {
+		return err
+	}
+
+	return ctx.Blob(http.StatusOK, echo.MIMEApplicationJSON, json)
+}
@@ -0,0 +1,215 @@
+package timestamp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/divilla/ethproxy/interfaces"
+	"github.com/divilla/ethproxy/pkg/blockcache"
+	"github.com/divilla/ethproxy/pkg/logging"
+	"github.com/labstack/echo/v4"
+	"github.com/tidwall/gjson"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	ModeBefore  = "before"
+	ModeAfter   = "after"
+	ModeClosest = "closest"
+)
+
+type (
+	service struct {
+		blocks interfaces.BlockSource
+		client interfaces.EthereumHttpClient
+		cache  interfaces.ResponseCacher
+		logger interfaces.Logger
+	}
+
+	// header is block number with its hash & timestamp
+	header struct {
+		number    uint64
+		hash      string
+		timestamp uint64
+	}
+
+	result struct {
+		Timestamp      uint64 `json:"timestamp"`
+		Mode           string `json:"mode"`
+		BlockNumber    uint64 `json:"block_number"`
+		BlockHash      string `json:"block_hash"`
+		BlockTimestamp uint64 `json:"block_timestamp"`
+		BlockTime      string `json:"block_time"`
+	}
+)
+
+func Service(blocks interfaces.BlockSource, client interfaces.EthereumHttpClient, cache interfaces.ResponseCacher, logger interfaces.Logger) *service {
+	return &service{
+		blocks: blocks,
+		client: client,
+		cache:  cache,
+		logger: logger,
+	}
+}
+
+// blockAt returns the last block at or before timestamp, the first block at or after it or the closest one.
+// Mapping is cached permanently when both blocks bounding the timestamp are finalized.
+func (s *service) blockAt(ctx context.Context, timestamps, mode string) ([]byte, error) {
+	ts, err := parseTimestamp(timestamps)
+	if err != nil {
+		return nil, err
+	}
+
+	if mode == "" {
+		mode = ModeBefore
+	}
+	if mode != ModeBefore && mode != ModeAfter && mode != ModeClosest {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("mode '%s' is not one of 'before', 'after', 'closest'", mode))
+	}
+
+	key := fmt.Sprintf("timestamp/%d/%s", ts, mode)
+	if json, err := s.cache.Get(ctx, key); err == nil {
+		return json, nil
+	}
+
+	// first block at or after timestamp follows the last block before it, so of blocks with equal timestamps it's the first one
+	target := ts
+	if mode == ModeAfter && ts > 0 {
+		target = ts - 1
+	}
+
+	lo, hi, err := s.search(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+
+	var h *header
+	switch {
+	case lo != nil && lo.timestamp == ts:
+		h = lo
+	case mode == ModeBefore:
+		h = lo
+	case mode == ModeAfter:
+		h = hi
+	case lo == nil || hi == nil:
+		h = lo
+		if h == nil {
+			h = hi
+		}
+	case ts-lo.timestamp <= hi.timestamp-ts:
+		h = lo
+	default:
+		h = hi
+	}
+
+	if h == nil {
+		return nil, echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("there is no block %s timestamp '%d'", mode, ts))
+	}
+
+	body, err := json.Marshal(result{
+		Timestamp:      ts,
+		Mode:           mode,
+		BlockNumber:    h.number,
+		BlockHash:      h.hash,
+		BlockTimestamp: h.timestamp,
+		BlockTime:      time.Unix(int64(h.timestamp), 0).UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if hi != nil && blockcache.BlockExpires(hi.number, s.client) == blockcache.Permanent {
+		if err = s.cache.Put(ctx, key, body, blockcache.Permanent); err != nil {
+			logging.FromContext(ctx, s.logger).Error(err)
+		}
+	}
+
+	return body, nil
+}
+
+// search returns adjacent blocks where lo is the last block at or before timestamp and hi the first block after it.
+// lo is nil if timestamp is before genesis, hi is nil if timestamp isn't after latest block.
+// Guess is interpolated from timestamps of bounding blocks, it falls back to bisection when range doesn't halve.
+func (s *service) search(ctx context.Context, ts uint64) (*header, *header, error) {
+	lo, err := s.header(ctx, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	if ts < lo.timestamp {
+		return nil, lo, nil
+	}
+
+	hi, err := s.header(ctx, s.client.LatestBlockNumber())
+	if err != nil {
+		return nil, nil, err
+	}
+	if ts >= hi.timestamp {
+		return hi, nil, nil
+	}
+
+	bisect := false
+	for hi.number-lo.number > 1 {
+		size := hi.number - lo.number
+
+		guess := lo.number + size/2
+		if !bisect && hi.timestamp > lo.timestamp {
+			guess = lo.number + uint64(float64(ts-lo.timestamp)/float64(hi.timestamp-lo.timestamp)*float64(size))
+		}
+		if guess <= lo.number {
+			guess = lo.number + 1
+		}
+		if guess >= hi.number {
+			guess = hi.number - 1
+		}
+
+		h, err := s.header(ctx, guess)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if h.timestamp <= ts {
+			lo = h
+		} else {
+			hi = h
+		}
+		bisect = hi.number-lo.number > size/2
+	}
+
+	return lo, hi, nil
+}
+
+// header returns block header from cache, missing header is fetched from upstream and cached
+func (s *service) header(ctx context.Context, nr uint64) (*header, error) {
+	blocks, err := s.blocks.GetBlocks(ctx, nr, nr, false)
+	if err != nil {
+		return nil, err
+	}
+
+	block := gjson.ParseBytes(blocks[0])
+	ts, err := strconv.ParseUint(block.Get("timestamp").String(), 0, 64)
+	if err != nil {
+		return nil, fmt.Errorf("block '%d' has invalid timestamp: %w", nr, err)
+	}
+
+	return &header{
+		number:    nr,
+		hash:      block.Get("hash").String(),
+		timestamp: ts,
+	}, nil
+}
+
+// parseTimestamp parses unix seconds or RFC 3339 time
+func parseTimestamp(value string) (uint64, error) {
+	if ts, err := strconv.ParseUint(value, 10, 64); err == nil {
+		return ts, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil || t.Unix() < 0 {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("timestamp '%s' is not valid unix seconds or RFC 3339 time", value))
+	}
+
+	return uint64(t.Unix()), nil
+}
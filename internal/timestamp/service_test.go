@@ -0,0 +1,160 @@
+package timestamp
+
+import (
+	"context"
+	"fmt"
+	"github.com/divilla/ethproxy/internal/testutil"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+// chain serves headers with given timestamps, the last one is the latest block, lookups are counted
+type chain struct {
+	testutil.Client
+	timestamps []uint64
+	lookups    int
+	mx         sync.Mutex
+}
+
+func (c *chain) LatestBlockNumber() uint64 { return uint64(len(c.timestamps) - 1) }
+
+func (c *chain) GetBlocks(_ context.Context, from, to uint64, _ bool) ([][]byte, error) {
+	c.mx.Lock()
+	c.lookups++
+	c.mx.Unlock()
+
+	var blocks [][]byte
+	for nr := from; nr <= to; nr++ {
+		blocks = append(blocks, []byte(fmt.Sprintf(`{"number":"0x%x","hash":"0x%d","timestamp":"0x%x"}`, nr, nr, c.timestamps[nr])))
+	}
+
+	return blocks, nil
+}
+
+func (c *chain) reset() int {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	lookups := c.lookups
+	c.lookups = 0
+	return lookups
+}
+
+func newService(t *testing.T, c *chain) *service {
+	logger := testutil.Logger()
+	return Service(c, c, testutil.ResponseCache(t, logger), logger)
+}
+
+// blockAt returns block number found for timestamp, -1 if there is none
+func blockAt(t *testing.T, s *service, ts uint64, mode string) int {
+	json, err := s.blockAt(context.Background(), fmt.Sprint(ts), mode)
+	if err != nil {
+		if assert.IsType(t, &echo.HTTPError{}, err) {
+			assert.Equal(t, http.StatusNotFound, err.(*echo.HTTPError).Code)
+		}
+		return -1
+	}
+
+	assert.Equal(t, mode, gjson.GetBytes(json, "mode").String())
+	return int(gjson.GetBytes(json, "block_number").Int())
+}
+
+func TestService_BlockAt(t *testing.T) {
+	// blocks every 12 seconds from 1000, with equal timestamps of blocks 4-6 & of the latest blocks 9-10
+	c := &chain{timestamps: []uint64{1000, 1012, 1024, 1036, 1048, 1048, 1048, 1060, 1072, 1084, 1084}}
+	s := newService(t, c)
+
+	tests := []struct {
+		ts      uint64
+		before  int
+		after   int
+		closest int
+	}{
+		{0, -1, 0, 0},
+		{999, -1, 0, 0},
+		{1000, 0, 0, 0},
+		{1005, 0, 1, 0},
+		{1006, 0, 1, 0},
+		{1007, 0, 1, 1},
+		{1012, 1, 1, 1},
+		{1030, 2, 3, 2},
+		{1048, 6, 4, 6},
+		{1050, 6, 7, 6},
+		{1059, 6, 7, 7},
+		{1084, 10, 9, 10},
+		{1085, 10, -1, 10},
+		{5000, 10, -1, 10},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.before, blockAt(t, s, test.ts, ModeBefore), "%d before", test.ts)
+		assert.Equal(t, test.after, blockAt(t, s, test.ts, ModeAfter), "%d after", test.ts)
+		assert.Equal(t, test.closest, blockAt(t, s, test.ts, ModeClosest), "%d closest", test.ts)
+	}
+
+	_, err := s.blockAt(context.Background(), "1000", "exact")
+	if assert.IsType(t, &echo.HTTPError{}, err) {
+		assert.Equal(t, http.StatusBadRequest, err.(*echo.HTTPError).Code)
+	}
+	_, err = s.blockAt(context.Background(), "yesterday", ModeBefore)
+	if assert.IsType(t, &echo.HTTPError{}, err) {
+		assert.Equal(t, http.StatusBadRequest, err.(*echo.HTTPError).Code)
+	}
+
+	json, err := s.blockAt(context.Background(), "1970-01-01T00:17:10Z", "")
+	if assert.NoError(t, err) {
+		assert.JSONEq(t, `{"timestamp":1030,"mode":"before","block_number":2,"block_hash":"0x2","block_timestamp":1024,"block_time":"1970-01-01T00:17:04Z"}`, string(json))
+	}
+}
+
+func TestService_Search(t *testing.T) {
+	// block time changes from 1 to 100 seconds half way, so interpolated guesses are far off
+	c := &chain{}
+	for nr := uint64(0); nr < 20000; nr++ {
+		ts := nr
+		if nr > 10000 {
+			ts = 10000 + (nr-10000)*100
+		}
+		c.timestamps = append(c.timestamps, ts)
+	}
+	s := newService(t, c)
+
+	for _, ts := range []uint64{1, 5000, 9999, 10000, 10050, 10100, 500000, 999999} {
+		c.reset()
+		lo, hi, err := s.search(context.Background(), ts)
+		if !assert.NoError(t, err) {
+			continue
+		}
+
+		assert.Equal(t, lo.number+1, hi.number, ts)
+		assert.LessOrEqual(t, lo.timestamp, ts)
+		assert.Greater(t, hi.timestamp, ts)
+		// interpolation falls back to bisection, so lookups stay logarithmic
+		assert.LessOrEqual(t, c.reset(), 2*15+2, ts)
+	}
+}
+
+func TestService_BlockAt_Cache(t *testing.T) {
+	c := &chain{Client: testutil.Client{Safe: 2, Finalized: 2}, timestamps: []uint64{1000, 1012, 1024, 1036, 1048}}
+	s := newService(t, c)
+
+	// mapping is cached when block after timestamp is finalized
+	assert.Equal(t, 1, blockAt(t, s, 1020, ModeBefore))
+	assert.NotZero(t, c.reset())
+	assert.Equal(t, 1, blockAt(t, s, 1020, ModeBefore))
+	assert.Zero(t, c.reset())
+
+	assert.Equal(t, 2, blockAt(t, s, 1030, ModeBefore))
+	assert.NotZero(t, c.reset())
+	assert.Equal(t, 2, blockAt(t, s, 1030, ModeBefore))
+	assert.NotZero(t, c.reset())
+
+	// timestamp after the latest block isn't cached
+	c.Safe, c.Finalized = 4, 4
+	assert.Equal(t, 4, blockAt(t, s, 2000, ModeBefore))
+	assert.Equal(t, 4, blockAt(t, s, 2000, ModeBefore))
+	assert.Equal(t, 4, c.reset())
+}
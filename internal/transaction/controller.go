@@ -0,0 +1,63 @@
+package transaction
+
+import (
+	"github.com/divilla/ethproxy/interfaces"
+	"github.com/divilla/ethproxy/internal/rpc"
+	"github.com/divilla/ethproxy/pkg/upstream"
+	"github.com/labstack/echo/v4"
+	"github.com/tidwall/gjson"
+	"io/ioutil"
+	"net/http"
+)
+
+type (
+	controller struct {
+		service *service
+		logger  interfaces.Logger
+	}
+)
+
+func Controller(e *echo.Echo, server *rpc.Server, pool *upstream.Pool, client interfaces.EthereumHttpClient, cache interfaces.ResponseCacher) {
+	c := &controller{
+		service: Service(pool, client, cache, e.Logger),
+		logger:  e.Logger,
+	}
+
+	e.POST("/transaction", c.send)
+	e.GET("/transaction/:hash", c.status)
+	server.Register("eth_sendRawTransaction", c.rpcSend)
+}
+
+// send broadcasts signed transaction given in 'raw' field of request body
+func (c *controller) send(ctx echo.Context) error {
+	body, err := ioutil.ReadAll(ctx.Request().Body)
+	if err != nil {
+		return err
+	}
+
+	hash, err := c.service.send(ctx.Request().Context(), gjson.GetBytes(body, "raw").String())
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]string{"hash": hash})
+}
+
+// status returns inclusion status of transaction by hash
+func (c *controller) status(ctx echo.Context) error {
+	json, err := c.service.status(ctx.Request().Context(), ctx.Param("hash"))
+	if err != nil {
+		return err
+	}
+
+	return ctx.Blob(http.StatusOK, echo.MIMEApplicationJSON, json)
+}
+
+func (c *controller) rpcSend(ctx echo.Context, params gjson.Result) ([]byte, error) {
+	hash, err := c.service.send(ctx.Request().Context(), params.Get("0").String())
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(`"` + hash + `"`), nil
+}
@@ -0,0 +1,266 @@
+package transaction
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/divilla/ethproxy/config"
+	"github.com/divilla/ethproxy/interfaces"
+	"github.com/divilla/ethproxy/pkg/ethclient"
+	"github.com/divilla/ethproxy/pkg/logging"
+	"github.com/divilla/ethproxy/pkg/upstream"
+	"github.com/labstack/echo/v4"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	StatusAccepted = "accepted"
+	StatusKnown    = "known"
+	StatusRejected = "rejected"
+	StatusFailed   = "failed"
+
+	StatusIncluded = "included"
+	StatusReverted = "reverted"
+	StatusPending  = "pending"
+	StatusUnknown  = "unknown"
+)
+
+var hashRegexp = regexp.MustCompile(`^0x[0-9a-fA-F]{64}$`)
+
+// knownErrors are fragments of upstream errors returned for transaction already in its pool or chain
+var knownErrors = []string{
+	"already known",
+	"alreadyknown",
+	"known transaction",
+	"already imported",
+}
+
+type (
+	service struct {
+		pool   *upstream.Pool
+		client interfaces.EthereumHttpClient
+		cache  interfaces.ResponseCacher
+		logger interfaces.Logger
+	}
+
+	// submission is record of broadcast transaction, upstreams are counted by broadcast status
+	submission struct {
+		Hash        string         `json:"hash"`
+		Type        int            `json:"type"`
+		Nonce       uint64         `json:"nonce"`
+		SubmittedAt time.Time      `json:"submitted_at"`
+		Upstreams   map[string]int `json:"upstreams"`
+	}
+
+	broadcast struct {
+		status string
+		err    error
+	}
+)
+
+func Service(pool *upstream.Pool, client interfaces.EthereumHttpClient, cache interfaces.ResponseCacher, logger interfaces.Logger) *service {
+	return &service{
+		pool:   pool,
+		client: client,
+		cache:  cache,
+		logger: logger,
+	}
+}
+
+// send validates raw transaction and broadcasts it to all healthy upstreams in parallel.
+// Hash is returned after first upstream accepts it or already knows it, the rest are recorded in background.
+func (s *service) send(ctx context.Context, raw string) (string, error) {
+	tx, err := ethclient.ParseRawTransaction(raw)
+	if err != nil {
+		return "", echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	ups := s.pool.Healthy()
+	if len(ups) == 0 {
+		return "", echo.NewHTTPError(http.StatusServiceUnavailable, "no healthy upstream to broadcast transaction to")
+	}
+
+	results := make(chan broadcast, len(ups))
+	for _, u := range ups {
+		go func(u upstream.Upstream) {
+			results <- s.sendTo(ctx, u, raw, tx.Hash)
+		}(u)
+	}
+
+	first := make(chan error, 1)
+	go s.collect(ctx, tx, results, len(ups), first)
+
+	if err = <-first; err != nil {
+		return "", err
+	}
+
+	return tx.Hash, nil
+}
+
+// sendTo submits raw transaction to single upstream, 'already known' errors count as success
+func (s *service) sendTo(ctx context.Context, u upstream.Upstream, raw, hash string) broadcast {
+	res, err := ethclient.Call(ctx, u.Client, "sendRawTransaction", raw)
+	if err == nil {
+		if !strings.EqualFold(string(res), hash) {
+			logging.FromContext(ctx, s.logger).Errorf("upstream '%s' returned hash '%s' for transaction '%s'", u.Url, res, hash)
+		}
+		return broadcast{status: StatusAccepted}
+	}
+
+	var rpcErr *ethclient.RPCError
+	if !errors.As(err, &rpcErr) {
+		logging.FromContext(ctx, s.logger).Errorf("upstream '%s' failed to broadcast transaction '%s': %v", u.Url, hash, err)
+		return broadcast{status: StatusFailed, err: err}
+	}
+
+	msg := strings.ToLower(rpcErr.Message())
+	for _, fragment := range knownErrors {
+		if strings.Contains(msg, fragment) {
+			return broadcast{status: StatusKnown}
+		}
+	}
+
+	return broadcast{status: StatusRejected, err: echo.NewHTTPError(http.StatusBadRequest, rpcErr.Message())}
+}
+
+// collect counts broadcast results, submission is recorded when first upstream accepts transaction and when all answered.
+// If none accepted it, first rejection is returned, or bad gateway when all upstreams failed.
+func (s *service) collect(ctx context.Context, tx ethclient.RawTransaction, results chan broadcast, n int, first chan error) {
+	sub := submission{
+		Hash:        tx.Hash,
+		Type:        tx.Type,
+		Nonce:       tx.Nonce,
+		SubmittedAt: time.Now().UTC(),
+		Upstreams:   make(map[string]int),
+	}
+
+	var rejection error
+	answered := false
+	for i := 0; i < n; i++ {
+		b := <-results
+		sub.Upstreams[b.status]++
+
+		switch b.status {
+		case StatusAccepted, StatusKnown:
+			if !answered {
+				answered = true
+				s.record(ctx, sub)
+				first <- nil
+			}
+		case StatusRejected:
+			if rejection == nil {
+				rejection = b.err
+			}
+		}
+	}
+
+	if answered {
+		s.record(ctx, sub)
+		return
+	}
+
+	if rejection != nil {
+		first <- rejection
+		return
+	}
+
+	first <- echo.NewHTTPError(http.StatusBadGateway, fmt.Sprintf("unable to broadcast transaction '%s'", tx.Hash))
+}
+
+func (s *service) record(ctx context.Context, sub submission) {
+	body, err := json.Marshal(sub)
+	if err != nil {
+		logging.FromContext(ctx, s.logger).Error(err)
+		return
+	}
+
+	if err = s.cache.Put(ctx, key(sub.Hash), body, config.SubmittedTTL); err != nil {
+		logging.FromContext(ctx, s.logger).Error(err)
+	}
+}
+
+// status returns inclusion status of transaction, submission record is included when it was broadcast by the proxy.
+// Transaction unknown to upstream is 'unknown' if it was submitted, otherwise it's not found.
+func (s *service) status(ctx context.Context, hash string) ([]byte, error) {
+	if !hashRegexp.MatchString(hash) {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("hash '%s' is not valid transaction hash", hash))
+	}
+	hash = strings.ToLower(hash)
+
+	res, err := s.cache.Get(ctx, key(hash))
+	recorded := err == nil
+	if !recorded {
+		res = []byte(fmt.Sprintf(`{"hash":"%s"}`, hash))
+	}
+
+	fields, err := s.inclusion(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if fields == nil {
+		if !recorded {
+			return nil, echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("transaction '%s' not found", hash))
+		}
+		fields = map[string]interface{}{"status": StatusUnknown}
+	}
+
+	for k, v := range fields {
+		if res, err = sjson.SetBytes(res, k, v); err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
+
+// inclusion returns status fields from receipt, or pending status if transaction is only known to upstream
+func (s *service) inclusion(ctx context.Context, hash string) (map[string]interface{}, error) {
+	receipt, err := s.client.GetTransactionReceipt(ctx, hash)
+	if err != nil {
+		logging.FromContext(ctx, s.logger).Error(err)
+		return nil, echo.NewHTTPError(http.StatusBadGateway, fmt.Sprintf("unable to fetch receipt of transaction '%s'", hash))
+	}
+
+	if len(receipt) > 0 {
+		nr, err := ethclient.HexToUInt(gjson.GetBytes(receipt, "blockNumber").String())
+		if err != nil {
+			return nil, err
+		}
+
+		status := StatusIncluded
+		if gjson.GetBytes(receipt, "status").String() == "0x0" {
+			status = StatusReverted
+		}
+
+		fields := map[string]interface{}{
+			"status":       status,
+			"block_number": nr,
+			"block_hash":   gjson.GetBytes(receipt, "blockHash").String(),
+		}
+		if latest := s.client.LatestBlockNumber(); latest >= nr {
+			fields["confirmations"] = latest - nr + 1
+		}
+		return fields, nil
+	}
+
+	tx, err := s.client.GetTransactionByHash(ctx, hash)
+	if err != nil {
+		logging.FromContext(ctx, s.logger).Error(err)
+		return nil, echo.NewHTTPError(http.StatusBadGateway, fmt.Sprintf("unable to fetch transaction '%s'", hash))
+	}
+	if len(tx) > 0 {
+		return map[string]interface{}{"status": StatusPending}, nil
+	}
+
+	return nil, nil
+}
+
+func key(hash string) string {
+	return "submitted/" + hash
+}
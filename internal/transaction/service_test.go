@@ -0,0 +1,243 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"github.com/divilla/ethproxy/internal/testutil"
+	"github.com/divilla/ethproxy/pkg/upstream"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	// raw & hash are signed transaction from EIP-155 example
+	raw  = "0xf86c098504a817c800825208943535353535353535353535353535353535353535880de0b6b3a76400008025a028ef61340bd939bc2195fe537567866003e1a15d3c71ff63e1590620aa636276a067cbe9d8997f761aecb703304b3800ccf555c9f3dc64214b297fb1966a3b6d83"
+	hash = "0x33469b22e9f636356c4160a87eb19df52b7412e8eac32a4a55ffe88ea8350788"
+
+	accepts = `"result":"` + hash + `"`
+	knows   = `"error":{"code":-32000,"message":"already known"}`
+	rejects = `"error":{"code":-32000,"message":"nonce too low"}`
+)
+
+// node is upstream that answers health checks and replies to broadcast with reply, it fails when reply is empty.
+// When gate is set, broadcast waits for it to be closed.
+type node struct {
+	reply string
+	gate  chan struct{}
+	sent  int32
+}
+
+func (n *node) Url(string) error {
+	return nil
+}
+
+func (n *node) Post(_ context.Context, body string) ([]byte, error) {
+	reply := `"result":"0x64"`
+	if gjson.Get(body, "method").String() == "eth_sendRawTransaction" {
+		atomic.AddInt32(&n.sent, 1)
+		if n.gate != nil {
+			<-n.gate
+		}
+		if n.reply == "" {
+			return nil, errors.New("connection refused")
+		}
+		reply = n.reply
+	}
+
+	return []byte(`{"jsonrpc":"2.0","id":` + gjson.Get(body, "id").Raw + `,` + reply + `}`), nil
+}
+
+// fakeClient returns receipts & transactions known to upstream
+type fakeClient struct {
+	testutil.Client
+	receipts map[string]string
+	txs      map[string]string
+}
+
+func (c *fakeClient) GetTransactionReceipt(_ context.Context, hash string) ([]byte, error) {
+	return []byte(c.receipts[hash]), nil
+}
+
+func (c *fakeClient) GetTransactionByHash(_ context.Context, hash string) ([]byte, error) {
+	return []byte(c.txs[hash]), nil
+}
+
+func newService(t *testing.T, client *fakeClient, nodes ...*node) *service {
+	logger := testutil.Logger()
+	client.Latest = 100
+
+	ups := make([]upstream.Upstream, len(nodes))
+	for i, n := range nodes {
+		ups[i] = upstream.Upstream{Url: string(rune('a' + i)), Client: n}
+	}
+	pool := upstream.New(logger, time.Hour, 0, ups...)
+	t.Cleanup(pool.Done)
+
+	return Service(pool, client, testutil.ResponseCache(t, logger), logger)
+}
+
+// submitted returns upstream counts of submission record
+func submitted(t *testing.T, s *service) map[string]int64 {
+	json, err := s.cache.Get(context.Background(), key(hash))
+	if !assert.NoError(t, err) {
+		return nil
+	}
+
+	counts := make(map[string]int64)
+	gjson.GetBytes(json, "upstreams").ForEach(func(k, v gjson.Result) bool {
+		counts[k.String()] = v.Int()
+		return true
+	})
+	return counts
+}
+
+func TestService_Send(t *testing.T) {
+	tests := []struct {
+		name    string
+		replies []string
+		counts  map[string]int64
+	}{
+		{"accepted", []string{accepts, accepts}, map[string]int64{StatusAccepted: 2}},
+		{"already known counts as success", []string{knows, accepts}, map[string]int64{StatusKnown: 1, StatusAccepted: 1}},
+		{"known to all upstreams", []string{knows, knows}, map[string]int64{StatusKnown: 2}},
+		{"accepted despite rejection & failure", []string{rejects, "", accepts}, map[string]int64{StatusRejected: 1, StatusFailed: 1, StatusAccepted: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes := make([]*node, len(tt.replies))
+			for i, reply := range tt.replies {
+				nodes[i] = &node{reply: reply}
+			}
+			s := newService(t, &fakeClient{}, nodes...)
+
+			res, err := s.send(context.Background(), raw)
+			if assert.NoError(t, err) {
+				assert.Equal(t, hash, res)
+			}
+			assert.Eventually(t, func() bool {
+				return assert.ObjectsAreEqual(tt.counts, submitted(t, s))
+			}, time.Second, 5*time.Millisecond)
+			for _, n := range nodes {
+				assert.Equal(t, int32(1), atomic.LoadInt32(&n.sent))
+			}
+		})
+	}
+}
+
+func TestService_SendFirstWins(t *testing.T) {
+	slow := &node{reply: accepts, gate: make(chan struct{})}
+	s := newService(t, &fakeClient{}, slow, &node{reply: knows})
+
+	// hash is returned and submission is recorded without waiting for the slow upstream
+	res, err := s.send(context.Background(), raw)
+	if assert.NoError(t, err) {
+		assert.Equal(t, hash, res)
+	}
+	assert.Equal(t, map[string]int64{StatusKnown: 1}, submitted(t, s))
+
+	close(slow.gate)
+	assert.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual(map[string]int64{StatusKnown: 1, StatusAccepted: 1}, submitted(t, s))
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestService_SendErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		replies []string
+		code    int
+		message string
+	}{
+		{"invalid transaction", raw[:len(raw)-2], []string{accepts}, http.StatusBadRequest, ""},
+		{"rejected by all upstreams", raw, []string{rejects, rejects}, http.StatusBadRequest, "nonce too low"},
+		{"rejection is returned over failure", raw, []string{"", rejects}, http.StatusBadRequest, "nonce too low"},
+		{"failed on all upstreams", raw, []string{"", ""}, http.StatusBadGateway, hash},
+		{"no upstream", raw, nil, http.StatusServiceUnavailable, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes := make([]*node, len(tt.replies))
+			for i, reply := range tt.replies {
+				nodes[i] = &node{reply: reply}
+			}
+			s := newService(t, &fakeClient{}, nodes...)
+
+			_, err := s.send(context.Background(), tt.raw)
+			if assert.IsType(t, &echo.HTTPError{}, err) {
+				assert.Equal(t, tt.code, err.(*echo.HTTPError).Code)
+				assert.Contains(t, err.Error(), tt.message)
+			}
+			_, err = s.cache.Get(context.Background(), key(hash))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestService_Status(t *testing.T) {
+	included := "0x" + strings.Repeat("1", 64)
+	reverted := "0x" + strings.Repeat("2", 64)
+	pending := "0x" + strings.Repeat("3", 64)
+	client := &fakeClient{
+		receipts: map[string]string{
+			included: `{"blockNumber":"0x5a","blockHash":"0xb1","status":"0x1"}`,
+			reverted: `{"blockNumber":"0x64","blockHash":"0xb2","status":"0x0"}`,
+		},
+		txs: map[string]string{pending: `{"hash":"` + pending + `"}`},
+	}
+	s := newService(t, client, &node{reply: accepts})
+
+	tests := []struct {
+		hash string
+		json string
+	}{
+		{included, `{"hash":"` + included + `","status":"included","block_number":90,"block_hash":"0xb1","confirmations":11}`},
+		{reverted, `{"hash":"` + reverted + `","status":"reverted","block_number":100,"block_hash":"0xb2","confirmations":1}`},
+		{strings.ToUpper(pending[2:]), ""},
+		{"0x" + strings.ToUpper(pending[2:]), `{"hash":"` + pending + `","status":"pending"}`},
+	}
+	for _, tt := range tests {
+		json, err := s.status(context.Background(), tt.hash)
+		if tt.json == "" {
+			if assert.IsType(t, &echo.HTTPError{}, err, tt.hash) {
+				assert.Equal(t, http.StatusBadRequest, err.(*echo.HTTPError).Code, tt.hash)
+			}
+			continue
+		}
+		if assert.NoError(t, err, tt.hash) {
+			assert.JSONEq(t, tt.json, string(json), tt.hash)
+		}
+	}
+
+	// transaction unknown to upstream is not found unless it was submitted by proxy
+	_, err := s.status(context.Background(), hash)
+	if assert.IsType(t, &echo.HTTPError{}, err) {
+		assert.Equal(t, http.StatusNotFound, err.(*echo.HTTPError).Code)
+	}
+
+	_, err = s.send(context.Background(), raw)
+	assert.NoError(t, err)
+	json, err := s.status(context.Background(), hash)
+	if assert.NoError(t, err) {
+		assert.Equal(t, StatusUnknown, gjson.GetBytes(json, "status").String())
+		assert.Equal(t, int64(9), gjson.GetBytes(json, "nonce").Int())
+		assert.Equal(t, int64(1), gjson.GetBytes(json, "upstreams."+StatusAccepted).Int())
+	}
+
+	// submission record is kept once transaction is included
+	client.receipts[hash] = `{"blockNumber":"0x63","blockHash":"0xb3","status":"0x1"}`
+	json, err = s.status(context.Background(), hash)
+	if assert.NoError(t, err) {
+		assert.Equal(t, StatusIncluded, gjson.GetBytes(json, "status").String())
+		assert.Equal(t, int64(2), gjson.GetBytes(json, "confirmations").Int())
+		assert.Equal(t, int64(9), gjson.GetBytes(json, "nonce").Int())
+	}
+}
@@ -0,0 +1,235 @@
+package apikey
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/divilla/ethproxy/config"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	RejectedQuota  = "quota"
+	RejectedMethod = "method"
+)
+
+type (
+	// Store holds API keys with their quota windows & usage counters
+	Store struct {
+		keys map[string]*entry
+		now  func() time.Time
+		mx   sync.Mutex
+	}
+
+	entry struct {
+		key      config.APIKey
+		minute   window
+		day      window
+		requests uint64
+		rejected map[string]uint64
+		methods  map[string]uint64
+	}
+
+	// window counts requests in fixed window starting at start
+	window struct {
+		start time.Time
+		count int
+	}
+
+	// QuotaError is returned when key used up its quota, RetryAfter is time until window resets
+	QuotaError struct {
+		Window     string
+		Limit      int
+		RetryAfter time.Duration
+	}
+
+	// Usage holds counters of single key, key itself is masked
+	Usage struct {
+		Key       string            `json:"key"`
+		Name      string            `json:"name"`
+		Requests  uint64            `json:"requests"`
+		Minute    int               `json:"minute"`
+		PerMinute int               `json:"per_minute"`
+		Day       int               `json:"day"`
+		PerDay    int               `json:"per_day"`
+		Rejected  map[string]uint64 `json:"rejected"`
+		Methods   map[string]uint64 `json:"methods"`
+	}
+)
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("API key quota of %d requests per %s exceeded, retry in %d seconds", e.Limit, e.Window, int(math.Ceil(e.RetryAfter.Seconds())))
+}
+
+func New(keys []config.APIKey) *Store {
+	s := &Store{
+		keys: make(map[string]*entry, len(keys)),
+		now:  time.Now,
+	}
+
+	for _, k := range keys {
+		s.keys[k.Key] = &entry{
+			key:      k,
+			rejected: make(map[string]uint64),
+			methods:  make(map[string]uint64),
+		}
+	}
+
+	return s
+}
+
+// Load reads json array of API keys from file, missing file holds no keys
+func Load(file string) ([]config.APIKey, error) {
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []config.APIKey
+	if err = json.Unmarshal(data, &keys); err != nil {
+		return nil, errors.Wrapf(err, "API keys file '%s' is not valid json array", file)
+	}
+
+	for i, k := range keys {
+		if k.Key == "" {
+			return nil, errors.Errorf("API key %d in file '%s' is empty", i, file)
+		}
+	}
+
+	return keys, nil
+}
+
+// Enabled returns true if there are any keys, otherwise authentication is disabled
+func (s *Store) Enabled() bool {
+	return len(s.keys) > 0
+}
+
+// Key returns API key by its value
+func (s *Store) Key(key string) (config.APIKey, bool) {
+	e, ok := s.keys[key]
+	if !ok {
+		return config.APIKey{}, false
+	}
+
+	return e.key, true
+}
+
+// Take counts request against key quotas, it returns *QuotaError if minute or day quota is used up
+func (s *Store) Take(key string) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	e, ok := s.keys[key]
+	if !ok {
+		return errors.New("invalid API key")
+	}
+
+	now := s.now().UTC()
+	e.minute.reset(now.Truncate(time.Minute))
+	e.day.reset(now.Truncate(24 * time.Hour))
+
+	if e.key.PerMinute > 0 && e.minute.count >= e.key.PerMinute {
+		e.rejected[RejectedQuota]++
+		return &QuotaError{Window: "minute", Limit: e.key.PerMinute, RetryAfter: e.minute.start.Add(time.Minute).Sub(now)}
+	}
+	if e.key.PerDay > 0 && e.day.count >= e.key.PerDay {
+		e.rejected[RejectedQuota]++
+		return &QuotaError{Window: "day", Limit: e.key.PerDay, RetryAfter: e.day.start.Add(24 * time.Hour).Sub(now)}
+	}
+
+	e.minute.count++
+	e.day.count++
+	e.requests++
+
+	return nil
+}
+
+// Allow returns true if method is on key allowlist, allowed methods are counted in key usage
+func (s *Store) Allow(key, method string) bool {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	e, ok := s.keys[key]
+	if !ok {
+		return false
+	}
+
+	allowed := len(e.key.Methods) == 0
+	for _, m := range e.key.Methods {
+		if m == method {
+			allowed = true
+			break
+		}
+	}
+
+	if !allowed {
+		e.rejected[RejectedMethod]++
+		return false
+	}
+
+	e.methods[method]++
+	return true
+}
+
+// Usage returns usage of all keys ordered by name
+func (s *Store) Usage() []Usage {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	now := s.now().UTC()
+	usage := make([]Usage, 0, len(s.keys))
+	for _, e := range s.keys {
+		e.minute.reset(now.Truncate(time.Minute))
+		e.day.reset(now.Truncate(24 * time.Hour))
+
+		u := Usage{
+			Key:       mask(e.key.Key),
+			Name:      e.key.Name,
+			Requests:  e.requests,
+			Minute:    e.minute.count,
+			PerMinute: e.key.PerMinute,
+			Day:       e.day.count,
+			PerDay:    e.key.PerDay,
+			Rejected:  make(map[string]uint64, len(e.rejected)),
+			Methods:   make(map[string]uint64, len(e.methods)),
+		}
+		for k, v := range e.rejected {
+			u.Rejected[k] = v
+		}
+		for k, v := range e.methods {
+			u.Methods[k] = v
+		}
+		usage = append(usage, u)
+	}
+
+	sort.Slice(usage, func(i, j int) bool {
+		return usage[i].Name < usage[j].Name
+	})
+
+	return usage
+}
+
+// reset starts new window if start changed
+func (w *window) reset(start time.Time) {
+	if !w.start.Equal(start) {
+		w.start = start
+		w.count = 0
+	}
+}
+
+// mask hides all but first 4 characters of key
+func mask(key string) string {
+	if len(key) <= 4 {
+		return "****"
+	}
+
+	return key[:4] + "****"
+}
@@ -0,0 +1,70 @@
+package apikey
+
+import (
+	"github.com/divilla/ethproxy/config"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStore_Take(t *testing.T) {
+	now := time.Date(2021, 8, 1, 12, 0, 30, 0, time.UTC)
+	s := New([]config.APIKey{{Key: "secret", Name: "a", PerMinute: 2, PerDay: 3}})
+	s.now = func() time.Time { return now }
+
+	assert.NoError(t, s.Take("secret"))
+	assert.NoError(t, s.Take("secret"))
+
+	err := s.Take("secret")
+	if assert.IsType(t, &QuotaError{}, err) {
+		assert.Equal(t, "minute", err.(*QuotaError).Window)
+		assert.Equal(t, 30*time.Second, err.(*QuotaError).RetryAfter)
+	}
+
+	now = now.Add(time.Minute)
+	assert.NoError(t, s.Take("secret"))
+	err = s.Take("secret")
+	if assert.IsType(t, &QuotaError{}, err) {
+		assert.Equal(t, "day", err.(*QuotaError).Window)
+	}
+
+	assert.Error(t, s.Take("unknown"))
+
+	usage := s.Usage()
+	if assert.Len(t, usage, 1) {
+		assert.Equal(t, "secr****", usage[0].Key)
+		assert.Equal(t, uint64(3), usage[0].Requests)
+		assert.Equal(t, uint64(2), usage[0].Rejected[RejectedQuota])
+	}
+}
+
+func TestStore_Allow(t *testing.T) {
+	s := New([]config.APIKey{{Key: "all"}, {Key: "some", Methods: []string{"/block/:bnr", "eth_call"}}})
+
+	assert.True(t, s.Allow("all", "eth_getLogs"))
+	assert.True(t, s.Allow("some", "eth_call"))
+	assert.False(t, s.Allow("some", "eth_getLogs"))
+	assert.False(t, s.Allow("unknown", "eth_call"))
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	keys, err := Load(filepath.Join(dir, "missing.json"))
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+
+	file := filepath.Join(dir, "keys.json")
+	assert.NoError(t, os.WriteFile(file, []byte(`[{"key":"k","name":"n","per_minute":10,"methods":["eth_call"]}]`), 0600))
+	keys, err = Load(file)
+	if assert.NoError(t, err) && assert.Len(t, keys, 1) {
+		assert.Equal(t, 10, keys[0].PerMinute)
+		assert.Equal(t, []string{"eth_call"}, keys[0].Methods)
+	}
+
+	assert.NoError(t, os.WriteFile(file, []byte(`[{"name":"n"}]`), 0600))
+	_, err = Load(file)
+	assert.Error(t, err)
+}
@@ -1,8 +1,12 @@
 package blockcache
 
 import (
+	"context"
 	"github.com/divilla/ethproxy/interfaces"
+	"github.com/divilla/ethproxy/pkg/logging"
+	"github.com/divilla/ethproxy/pkg/tracing"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
 	"sync"
 	"time"
 )
@@ -49,8 +53,28 @@ func New(logger interfaces.Logger, capacity int, removeExpired time.Duration) *E
 	return c
 }
 
-//Get returns ethereum block json
-func (c *EthereumBlockCache) Get(nr uint64) ([]byte, error) {
+//Get returns ethereum block json, lookup is traced and recorded to request access log
+func (c *EthereumBlockCache) Get(ctx context.Context, nr uint64) ([]byte, error) {
+	_, span := tracing.Start(ctx, "blockcache.Get", attribute.Int64("block.number", int64(nr)))
+	defer span.End()
+
+	json, err := c.get(nr)
+	logging.Cached(ctx, err == nil)
+	span.SetAttributes(attribute.Bool("cache.hit", err == nil))
+
+	return json, err
+}
+
+//Put caches ethereum block json, it's traced
+func (c *EthereumBlockCache) Put(ctx context.Context, nr uint64, json []byte, ttl time.Duration) error {
+	_, span := tracing.Start(ctx, "blockcache.Put", attribute.Int64("block.number", int64(nr)))
+	err := c.put(nr, json, ttl)
+	tracing.End(span, err)
+
+	return err
+}
+
+func (c *EthereumBlockCache) get(nr uint64) ([]byte, error) {
 	c.rwm.RLock()
 	defer c.rwm.RUnlock()
 
@@ -65,21 +89,20 @@ func (c *EthereumBlockCache) Get(nr uint64) ([]byte, error) {
 	return nil, errors.New("block not found")
 }
 
-//Put caches ethereum block json
-func (c *EthereumBlockCache) Put(nr uint64, json []byte, ttl time.Duration) error {
+func (c *EthereumBlockCache) put(nr uint64, json []byte, ttl time.Duration) error {
 	i := &item{
 		nr:      nr,
 		json:    json,
 		expires: time.Now().Add(ttl).UnixNano(),
 	}
 
+	c.rwm.Lock()
+	defer c.rwm.Unlock()
+
 	if val, ok := c.items[nr]; ok && val.expires > time.Now().UnixNano() {
 		return errors.Errorf("block number '%d' already exists in cache", nr)
 	}
 
-	c.rwm.Lock()
-	defer c.rwm.Unlock()
-
 	c.clearOne()
 	c.items[nr] = i
 
@@ -1,23 +1,112 @@
 package blockcache
 
 import (
+	"context"
+	"fmt"
 	"github.com/divilla/ethproxy/config"
+	"github.com/divilla/ethproxy/interfaces"
+	"github.com/divilla/ethproxy/pkg/ethclient"
 	"time"
 )
 
-func BlockExpires(blockNumber, latestBlockNumber uint64) time.Duration {
+// Permanent is TTL of blocks that can't be reorganized anymore
+const Permanent = time.Hour * 24 * 365 * 10
 
-	// Last 20 blocks have small TTL due to possible reorg
-	if blockNumber >= latestBlockNumber-20 {
-		// the further the block from the last one, the more TTL it has.
-		return config.CacheDefaultTTL
+type (
+	// ExpiryPolicy calculates block TTL from its finality
+	ExpiryPolicy struct {
+		ShortTTL      time.Duration
+		MediumTTL     time.Duration
+		SafeDistance  uint64
+		FinalDistance uint64
+	}
+)
+
+// DefaultExpiryPolicy is configured from config package constants
+var DefaultExpiryPolicy = ExpiryPolicy{
+	ShortTTL:      config.CacheDefaultTTL,
+	MediumTTL:     config.CacheSafeTTL,
+	SafeDistance:  config.FallbackSafeDistance,
+	FinalDistance: config.FallbackFinalDistance,
+}
+
+// BlockExpires returns block TTL using DefaultExpiryPolicy. If tracker has its own finality profile its distances
+// are used and short TTL is limited to its block time.
+func BlockExpires(blockNumber uint64, tracker interfaces.FinalityTracker) time.Duration {
+	p := DefaultExpiryPolicy
+	if fp, ok := tracker.(interfaces.FinalityProfile); ok {
+		p.SafeDistance, p.FinalDistance = fp.FinalityDistances()
+		if bt := fp.BlockTime(); bt > 0 && bt < p.ShortTTL {
+			p.ShortTTL = bt
+		}
+	}
+
+	return p.Expires(blockNumber, tracker)
+}
+
+// ResolveBlock returns json RPC block parameter resolved to block number with its cache TTL, 'latest' is default
+// and it's cached for latestTTL, other blocks use BlockExpires. 'pending' is returned as is and it's never cached.
+func ResolveBlock(ctx context.Context, blocks string, tracker interfaces.FinalityTracker, latestTTL time.Duration) (string, time.Duration, error) {
+	if blocks == "" {
+		blocks = ethclient.TagLatest
+	}
+
+	bp, err := ethclient.ParseBlockParam(blocks)
+	if err != nil {
+		return "", 0, &ethclient.BlockError{Message: err.Error()}
+	}
+
+	if bp.Tag == ethclient.TagPending && bp.Offset == 0 {
+		return bp.Tag, 0, nil
+	}
+
+	nr, err := bp.ResolveNumber(ctx, tracker, nil)
+	if err != nil {
+		return "", 0, err
+	}
+
+	if latest := tracker.LatestBlockNumber(); nr > latest {
+		return "", 0, &ethclient.BlockError{Message: fmt.Sprintf("block number '%d' is after latest block '%d'", nr, latest)}
+	}
+
+	ttl := BlockExpires(nr, tracker)
+	if bp.Tag == ethclient.TagLatest && bp.Offset == 0 {
+		ttl = latestTTL
+	}
+
+	return ethclient.UIntToHex(nr), ttl, nil
+}
+
+// Expires returns short TTL until block is safe, medium until it's finalized and permanent after it's finalized.
+// If chain doesn't support 'safe' & 'finalized' tags, distance from the latest block is used instead.
+func (p ExpiryPolicy) Expires(blockNumber uint64, tracker interfaces.FinalityTracker) time.Duration {
+	safe, finalized := tracker.SafeBlockNumber(), tracker.FinalizedBlockNumber()
+	if finalized == 0 {
+		return p.distanceExpires(blockNumber, tracker.LatestBlockNumber())
+	}
+
+	if blockNumber <= finalized {
+		return Permanent
+	}
+
+	if safe != 0 && blockNumber <= safe {
+		return p.MediumTTL
+	}
+
+	return p.ShortTTL
+}
+
+func (p ExpiryPolicy) distanceExpires(blockNumber, latestBlockNumber uint64) time.Duration {
+	// Last blocks have small TTL due to possible reorg
+	if blockNumber+p.SafeDistance >= latestBlockNumber {
+		return p.ShortTTL
 	}
 
-	// between 20 and 1000 blocks we set TTL depending on distance. The further the block the longer its TTL
-	if blockNumber >= latestBlockNumber-1000 {
-		return config.CacheDefaultTTL * time.Duration(latestBlockNumber-blockNumber)
+	// between safe and final distance we set TTL depending on distance. The further the block the longer its TTL
+	if blockNumber+p.FinalDistance >= latestBlockNumber {
+		return p.ShortTTL * time.Duration(latestBlockNumber-blockNumber)
 	}
 
 	// blocks that are safe to cache get 10 years of TTL
-	return time.Hour * 24 * 365 * 10
+	return Permanent
 }
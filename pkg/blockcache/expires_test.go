@@ -0,0 +1,55 @@
+package blockcache
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type tracker struct {
+	latest, safe, finalized uint64
+}
+
+func (t tracker) LatestBlockNumber() uint64    { return t.latest }
+func (t tracker) SafeBlockNumber() uint64      { return t.safe }
+func (t tracker) FinalizedBlockNumber() uint64 { return t.finalized }
+
+func TestExpiryPolicy_Expires(t *testing.T) {
+	p := ExpiryPolicy{
+		ShortTTL:      time.Second,
+		MediumTTL:     time.Minute,
+		SafeDistance:  20,
+		FinalDistance: 1000,
+	}
+
+	tags := tracker{latest: 10000, safe: 9970, finalized: 9940}
+	assert.Equal(t, Permanent, p.Expires(9940, tags))
+	assert.Equal(t, time.Minute, p.Expires(9941, tags))
+	assert.Equal(t, time.Minute, p.Expires(9970, tags))
+	assert.Equal(t, time.Second, p.Expires(9971, tags))
+	assert.Equal(t, time.Second, p.Expires(10001, tags))
+
+	distance := tracker{latest: 10000}
+	assert.Equal(t, time.Second, p.Expires(9980, distance))
+	assert.Equal(t, 100*time.Second, p.Expires(9900, distance))
+	assert.Equal(t, Permanent, p.Expires(8999, distance))
+	assert.Equal(t, time.Second, p.Expires(5, tracker{latest: 10}))
+}
+
+type profileTracker struct {
+	tracker
+	blockTime time.Duration
+}
+
+func (t profileTracker) FinalityDistances() (uint64, uint64) { return 100, 200 }
+func (t profileTracker) BlockTime() time.Duration            { return t.blockTime }
+
+func TestBlockExpires(t *testing.T) {
+	fast := profileTracker{tracker: tracker{latest: 10000}, blockTime: 2 * time.Second}
+	assert.Equal(t, 2*time.Second, BlockExpires(9950, fast))
+	assert.Equal(t, 300*time.Second, BlockExpires(9850, fast))
+	assert.Equal(t, Permanent, BlockExpires(9799, fast))
+
+	slow := profileTracker{tracker: tracker{latest: 10000}, blockTime: time.Minute}
+	assert.Equal(t, DefaultExpiryPolicy.ShortTTL, BlockExpires(9950, slow))
+}
@@ -0,0 +1,256 @@
+package blockexport
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/divilla/ethproxy/interfaces"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	FormatCSV      = "csv"
+	FormatColumnar = "columnar"
+
+	progressFile = "progress.json"
+)
+
+type (
+	// Job exports block range to block & transaction tables, progress is recorded after every chunk
+	// so interrupted job continues where it stopped
+	Job struct {
+		dir       string
+		chunkSize uint64
+		source    interfaces.BlockSource
+		logger    interfaces.Logger
+		progress  Progress
+		stop      chan struct{}
+		stopOnce  sync.Once
+		rwm       sync.RWMutex
+	}
+
+	// Progress is persisted job state, file sizes are used to discard partially written chunk on resume
+	Progress struct {
+		From   uint64           `json:"from"`
+		To     uint64           `json:"to"`
+		Next   uint64           `json:"next"`
+		Format string           `json:"format"`
+		Sizes  map[string]int64 `json:"sizes"`
+		Done   bool             `json:"done"`
+		Error  string           `json:"error,omitempty"`
+	}
+)
+
+// NewJob creates new export job in given directory or loads existing job with the same range & format
+func NewJob(dir string, from, to uint64, format string, chunkSize uint64, source interfaces.BlockSource, logger interfaces.Logger) (*Job, error) {
+	if format != FormatCSV && format != FormatColumnar {
+		return nil, errors.Errorf("export format '%s' is not one of: csv, columnar", format)
+	}
+	if from > to {
+		return nil, errors.Errorf("export range start '%d' is after end '%d'", from, to)
+	}
+	if chunkSize == 0 {
+		return nil, errors.New("export chunk size must be positive")
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	j := &Job{
+		dir:       dir,
+		chunkSize: chunkSize,
+		source:    source,
+		logger:    logger,
+		stop:      make(chan struct{}),
+		progress: Progress{
+			From:   from,
+			To:     to,
+			Next:   from,
+			Format: format,
+			Sizes:  make(map[string]int64),
+		},
+	}
+
+	saved, err := ioutil.ReadFile(filepath.Join(dir, progressFile))
+	if os.IsNotExist(err) {
+		return j, j.save()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var p Progress
+	if err = json.Unmarshal(saved, &p); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse export progress in '%s'", dir)
+	}
+	if p.From != from || p.To != to || p.Format != format {
+		return nil, errors.Errorf("export directory '%s' holds different job: %d-%d %s", dir, p.From, p.To, p.Format)
+	}
+	if p.Sizes == nil {
+		p.Sizes = make(map[string]int64)
+	}
+	p.Error = ""
+	j.progress = p
+
+	return j, nil
+}
+
+// Progress returns copy of current job progress
+func (j *Job) Progress() Progress {
+	j.rwm.RLock()
+	defer j.rwm.RUnlock()
+
+	p := j.progress
+	p.Sizes = make(map[string]int64, len(j.progress.Sizes))
+	for k, v := range j.progress.Sizes {
+		p.Sizes[k] = v
+	}
+
+	return p
+}
+
+// Run exports remaining chunks, it returns when job is done, stopped or failed
+func (j *Job) Run() error {
+	err := j.run()
+	if err != nil {
+		j.rwm.Lock()
+		j.progress.Error = err.Error()
+		j.rwm.Unlock()
+		j.logger.Errorf("export to '%s' failed with error: %v", j.dir, err)
+	}
+
+	return err
+}
+
+// Stop interrupts running job after current chunk
+func (j *Job) Stop() {
+	j.stopOnce.Do(func() {
+		close(j.stop)
+	})
+}
+
+func (j *Job) run() error {
+	p := j.Progress()
+	if p.Done {
+		return nil
+	}
+
+	writers, err := j.open(p)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for _, w := range writers {
+			_ = w.Close()
+		}
+	}()
+
+	for start := p.Next; start <= p.To; start += j.chunkSize {
+		select {
+		case <-j.stop:
+			return nil
+		default:
+		}
+
+		end := start + j.chunkSize - 1
+		if end > p.To || end < start {
+			end = p.To
+		}
+
+		blocks, err := j.source.GetBlocks(context.Background(), start, end, true)
+		if err != nil {
+			return err
+		}
+
+		blockRows, transactionRows := flatten(blocks)
+		if err = writers[blocksTable.name].Write(blockRows); err != nil {
+			return err
+		}
+		if err = writers[transactionsTable.name].Write(transactionRows); err != nil {
+			return err
+		}
+
+		if err = j.advance(end+1, end == p.To); err != nil {
+			return err
+		}
+		if end == p.To {
+			break
+		}
+	}
+
+	return nil
+}
+
+// open truncates table files to recorded sizes, discarding chunk that was written but not recorded
+func (j *Job) open(p Progress) (map[string]tableWriter, error) {
+	writers := make(map[string]tableWriter)
+	for _, t := range []table{blocksTable, transactionsTable} {
+		path := j.path(t, p.Format)
+		if size, ok := p.Sizes[filepath.Base(path)]; ok {
+			if err := os.Truncate(path, size); err != nil && !os.IsNotExist(err) {
+				return nil, err
+			}
+		} else if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		newWriter := newCSVWriter
+		if p.Format == FormatColumnar {
+			newWriter = newColumnarWriter
+		}
+
+		w, err := newWriter(path, t)
+		if err != nil {
+			return nil, err
+		}
+		writers[t.name] = w
+	}
+
+	return writers, nil
+}
+
+func (j *Job) advance(next uint64, done bool) error {
+	j.rwm.Lock()
+	j.progress.Next = next
+	j.progress.Done = done
+	for _, t := range []table{blocksTable, transactionsTable} {
+		path := j.path(t, j.progress.Format)
+		info, err := os.Stat(path)
+		if err != nil {
+			j.rwm.Unlock()
+			return err
+		}
+		j.progress.Sizes[filepath.Base(path)] = info.Size()
+	}
+	j.rwm.Unlock()
+
+	return j.save()
+}
+
+// save writes progress to temporary file which is renamed, so progress file is never partially written
+func (j *Job) save() error {
+	p := j.Progress()
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(j.dir, progressFile+".tmp")
+	if err = ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, filepath.Join(j.dir, progressFile))
+}
+
+func (j *Job) path(t table, format string) string {
+	if format == FormatColumnar {
+		return filepath.Join(j.dir, t.name+".cols.gz")
+	}
+
+	return filepath.Join(j.dir, t.name+".csv")
+}
@@ -0,0 +1,90 @@
+package blockexport
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type source struct {
+	failAt uint64
+}
+
+func (s *source) GetBlocks(_ context.Context, from, to uint64, full bool) ([][]byte, error) {
+	var blocks [][]byte
+	for nr := from; nr <= to; nr++ {
+		if s.failAt != 0 && nr == s.failAt {
+			return nil, errors.New("upstream failed")
+		}
+		blocks = append(blocks, []byte(fmt.Sprintf(`{"number":"0x%x","hash":"0x%x","timestamp":"0x5f5e1000","transactions":[`+
+			`{"blockNumber":"0x%x","transactionIndex":"0x0","value":"0xde0b6b3a7640000","to":null,"input":"0xa9059cbb0000"}]}`, nr, nr, nr)))
+	}
+
+	return blocks, nil
+}
+
+func TestJob_RunResume(t *testing.T) {
+	dir := t.TempDir()
+	src := &source{failAt: 15}
+
+	job, err := NewJob(dir, 10, 24, FormatCSV, 4, src, echo.New().Logger)
+	assert.NoError(t, err)
+	assert.Error(t, job.Run())
+	assert.Equal(t, uint64(14), job.Progress().Next)
+
+	// partially written chunk is discarded on resume
+	f, err := os.OpenFile(filepath.Join(dir, "blocks.csv"), os.O_APPEND|os.O_WRONLY, 0644)
+	assert.NoError(t, err)
+	_, _ = f.WriteString("garbage\n")
+	_ = f.Close()
+
+	src.failAt = 0
+	job, err = NewJob(dir, 10, 24, FormatCSV, 4, src, echo.New().Logger)
+	assert.NoError(t, err)
+	assert.NoError(t, job.Run())
+	assert.True(t, job.Progress().Done)
+
+	f, err = os.Open(filepath.Join(dir, "blocks.csv"))
+	assert.NoError(t, err)
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	assert.NoError(t, err)
+	assert.Len(t, rows, 16)
+	assert.Equal(t, "number", rows[0][0])
+	assert.Equal(t, "10", rows[1][0])
+	assert.Equal(t, "24", rows[15][0])
+
+	_, err = NewJob(dir, 10, 25, FormatCSV, 4, src, echo.New().Logger)
+	assert.Error(t, err)
+}
+
+func TestJob_RunColumnar(t *testing.T) {
+	dir := t.TempDir()
+	job, err := NewJob(dir, 1, 5, FormatColumnar, 2, &source{}, echo.New().Logger)
+	assert.NoError(t, err)
+	assert.NoError(t, job.Run())
+
+	f, err := os.Open(filepath.Join(dir, "transactions.cols.gz"))
+	assert.NoError(t, err)
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	assert.NoError(t, err)
+
+	var lines []string
+	scanner := bufio.NewScanner(gz)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	assert.Len(t, lines, 4)
+	assert.Contains(t, lines[0], `"table":"transactions"`)
+	assert.Contains(t, lines[1], `{"name":"value","values":["1000000000000000000","1000000000000000000"]}`)
+	assert.Contains(t, lines[1], `{"name":"method_selector","values":["0xa9059cbb","0xa9059cbb"]}`)
+}
@@ -0,0 +1,121 @@
+package blockexport
+
+import (
+	"github.com/divilla/ethproxy/pkg/ethclient"
+	"github.com/tidwall/gjson"
+	"strconv"
+	"strings"
+)
+
+const (
+	colHex = iota
+	colQuantity
+	colSelector
+	colInputSize
+	colCount
+)
+
+type (
+	column struct {
+		name string
+		path string
+		kind int
+	}
+
+	table struct {
+		name    string
+		columns []column
+	}
+)
+
+var (
+	blocksTable = table{
+		name: "blocks",
+		columns: []column{
+			{"number", "number", colQuantity},
+			{"hash", "hash", colHex},
+			{"parent_hash", "parentHash", colHex},
+			{"timestamp", "timestamp", colQuantity},
+			{"miner", "miner", colHex},
+			{"gas_used", "gasUsed", colQuantity},
+			{"gas_limit", "gasLimit", colQuantity},
+			{"base_fee_per_gas", "baseFeePerGas", colQuantity},
+			{"size", "size", colQuantity},
+			{"transaction_count", "transactions", colCount},
+		},
+	}
+
+	transactionsTable = table{
+		name: "transactions",
+		columns: []column{
+			{"block_number", "blockNumber", colQuantity},
+			{"transaction_index", "transactionIndex", colQuantity},
+			{"hash", "hash", colHex},
+			{"from", "from", colHex},
+			{"to", "to", colHex},
+			{"value", "value", colQuantity},
+			{"gas", "gas", colQuantity},
+			{"gas_price", "gasPrice", colQuantity},
+			{"max_fee_per_gas", "maxFeePerGas", colQuantity},
+			{"max_priority_fee_per_gas", "maxPriorityFeePerGas", colQuantity},
+			{"nonce", "nonce", colQuantity},
+			{"type", "type", colQuantity},
+			{"method_selector", "input", colSelector},
+			{"input_size", "input", colInputSize},
+		},
+	}
+)
+
+func (t table) header() []string {
+	header := make([]string, len(t.columns))
+	for i, c := range t.columns {
+		header[i] = c.name
+	}
+
+	return header
+}
+
+// row flattens json object to table row, quantities are converted to decimal, missing values are empty
+func (t table) row(json gjson.Result) []string {
+	row := make([]string, len(t.columns))
+	for i, c := range t.columns {
+		value := json.Get(c.path)
+		if !value.Exists() || value.Type == gjson.Null {
+			continue
+		}
+
+		switch c.kind {
+		case colQuantity:
+			if bi, err := ethclient.HexToBigInt(value.String()); err == nil {
+				row[i] = bi.String()
+			}
+		case colSelector:
+			if input := value.String(); len(input) >= 10 {
+				row[i] = strings.ToLower(input[:10])
+			}
+		case colInputSize:
+			row[i] = strconv.Itoa(len(strings.TrimPrefix(value.String(), "0x")) / 2)
+		case colCount:
+			row[i] = strconv.Itoa(len(value.Array()))
+		default:
+			row[i] = value.String()
+		}
+	}
+
+	return row
+}
+
+// flatten converts full blocks to block & transaction rows
+func flatten(blocks [][]byte) (blockRows [][]string, transactionRows [][]string) {
+	for _, json := range blocks {
+		block := gjson.ParseBytes(json)
+		blockRows = append(blockRows, blocksTable.row(block))
+
+		block.Get("transactions").ForEach(func(key, value gjson.Result) bool {
+			transactionRows = append(transactionRows, transactionsTable.row(value))
+			return true
+		})
+	}
+
+	return blockRows, transactionRows
+}
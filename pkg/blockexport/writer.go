@@ -0,0 +1,148 @@
+package blockexport
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+)
+
+type (
+	tableWriter interface {
+		Write(rows [][]string) error
+		Close() error
+	}
+
+	// csvWriter writes table as CSV file with header row
+	csvWriter struct {
+		file *os.File
+		csv  *csv.Writer
+	}
+
+	// columnarWriter writes every chunk of rows as separate gzip member containing single json line
+	// with column-major values, first member holds table schema. Concatenated gzip members are readable as one stream.
+	columnarWriter struct {
+		file  *os.File
+		table table
+	}
+
+	columnarSchema struct {
+		Table   string   `json:"table"`
+		Columns []string `json:"columns"`
+	}
+
+	columnarRowGroup struct {
+		Rows    int          `json:"rows"`
+		Columns []columnData `json:"columns"`
+	}
+
+	columnData struct {
+		Name   string   `json:"name"`
+		Values []string `json:"values"`
+	}
+)
+
+func openFile(path string) (*os.File, int64, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, 0, err
+	}
+
+	return f, info.Size(), nil
+}
+
+func newCSVWriter(path string, t table) (tableWriter, error) {
+	f, size, err := openFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &csvWriter{
+		file: f,
+		csv:  csv.NewWriter(f),
+	}
+
+	if size == 0 {
+		if err = w.Write([][]string{t.header()}); err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+	}
+
+	return w, nil
+}
+
+func (w *csvWriter) Write(rows [][]string) error {
+	if err := w.csv.WriteAll(rows); err != nil {
+		return err
+	}
+
+	return w.file.Sync()
+}
+
+func (w *csvWriter) Close() error {
+	return w.file.Close()
+}
+
+func newColumnarWriter(path string, t table) (tableWriter, error) {
+	f, size, err := openFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &columnarWriter{
+		file:  f,
+		table: t,
+	}
+
+	if size == 0 {
+		if err = w.writeMember(columnarSchema{Table: t.name, Columns: t.header()}); err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+	}
+
+	return w, nil
+}
+
+func (w *columnarWriter) Write(rows [][]string) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	group := columnarRowGroup{
+		Rows:    len(rows),
+		Columns: make([]columnData, len(w.table.columns)),
+	}
+	for i, c := range w.table.columns {
+		values := make([]string, len(rows))
+		for r, row := range rows {
+			values[r] = row[i]
+		}
+		group.Columns[i] = columnData{Name: c.name, Values: values}
+	}
+
+	return w.writeMember(group)
+}
+
+func (w *columnarWriter) writeMember(v interface{}) error {
+	gz := gzip.NewWriter(w.file)
+	if err := json.NewEncoder(gz).Encode(v); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	return w.file.Sync()
+}
+
+func (w *columnarWriter) Close() error {
+	return w.file.Close()
+}
@@ -0,0 +1,109 @@
+package cmiddleware
+
+import (
+	"errors"
+	"fmt"
+	"github.com/divilla/ethproxy/config"
+	"github.com/divilla/ethproxy/pkg/apikey"
+	"github.com/labstack/echo/v4"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+const (
+	// ContextAPIKey is context key of authenticated API key
+	ContextAPIKey    = "api_key"
+	HeaderRetryAfter = "Retry-After"
+)
+
+type (
+	APIKeyConfig struct {
+		Store *apikey.Store
+		// Public are route paths that don't require API key
+		Public []string
+		// RPC are json RPC route paths, they are open to all keys and methods are checked by APIKeyGuard
+		RPC []string
+	}
+)
+
+// APIKeyWithConfig authenticates requests by API key in header or query parameter, counts them against key quotas
+// and checks route path against key allowlist. Json RPC paths are charged per method by APIKeyGuard.
+// Authentication is disabled when store holds no keys.
+func APIKeyWithConfig(cfg APIKeyConfig) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			if !cfg.Store.Enabled() || contains(cfg.Public, ctx.Path()) {
+				return next(ctx)
+			}
+
+			key := ctx.Request().Header.Get(config.APIKeyHeader)
+			if key == "" {
+				key = ctx.QueryParam(config.APIKeyParam)
+			}
+			if key == "" {
+				return echo.NewHTTPError(http.StatusUnauthorized, fmt.Sprintf("API key is required in '%s' header or '%s' query parameter", config.APIKeyHeader, config.APIKeyParam))
+			}
+			if _, ok := cfg.Store.Key(key); !ok {
+				return echo.NewHTTPError(http.StatusUnauthorized, "API key is not valid")
+			}
+
+			ctx.Set(ContextAPIKey, key)
+			if contains(cfg.RPC, ctx.Path()) {
+				return next(ctx)
+			}
+
+			if err := take(ctx, cfg.Store, key); err != nil {
+				return err
+			}
+			if !cfg.Store.Allow(key, ctx.Path()) {
+				return echo.NewHTTPError(http.StatusForbidden, fmt.Sprintf("route '%s' is not allowed for API key", ctx.Path()))
+			}
+
+			return next(ctx)
+		}
+	}
+}
+
+// APIKeyGuard returns json RPC guard that checks method against allowlist of authenticated API key
+// and counts it against key quotas, so every request of a batch is charged
+func APIKeyGuard(store *apikey.Store) func(ctx echo.Context, method string) error {
+	return func(ctx echo.Context, method string) error {
+		key, ok := ctx.Get(ContextAPIKey).(string)
+		if !ok {
+			return nil
+		}
+
+		if !store.Allow(key, method) {
+			return echo.NewHTTPError(http.StatusForbidden, fmt.Sprintf("method '%s' is not allowed for API key", method))
+		}
+
+		return take(ctx, store, key)
+	}
+}
+
+// take counts request against key quotas, used up quota returns too many requests with Retry-After header
+func take(ctx echo.Context, store *apikey.Store, key string) error {
+	err := store.Take(key)
+	if err == nil {
+		return nil
+	}
+
+	var quotaErr *apikey.QuotaError
+	if errors.As(err, &quotaErr) {
+		ctx.Response().Header().Set(HeaderRetryAfter, strconv.Itoa(int(math.Ceil(quotaErr.RetryAfter.Seconds()))))
+		return echo.NewHTTPError(http.StatusTooManyRequests, quotaErr.Error())
+	}
+
+	return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,104 @@
+package cmiddleware
+
+import (
+	"github.com/divilla/ethproxy/config"
+	"github.com/divilla/ethproxy/pkg/apikey"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+// apiKeyEcho serves '/rpc' by running guard for every method in 'methods' query parameter, like json RPC batch
+func apiKeyEcho(store *apikey.Store) *echo.Echo {
+	e := echo.New()
+	e.HTTPErrorHandler = HTTPErrorHandler
+	e.Use(APIKeyWithConfig(APIKeyConfig{
+		Store:  store,
+		Public: []string{"/healthcheck"},
+		RPC:    []string{"/rpc"},
+	}))
+
+	ok := func(ctx echo.Context) error { return ctx.String(http.StatusOK, "ok") }
+	e.GET("/healthcheck", ok)
+	e.GET("/block/:bnr", ok)
+	e.GET("/fees", ok)
+	guard := APIKeyGuard(store)
+	e.POST("/rpc", func(ctx echo.Context) error {
+		for _, m := range ctx.QueryParams()["method"] {
+			if err := guard(ctx, m); err != nil {
+				return err
+			}
+		}
+		return ctx.String(http.StatusOK, "ok")
+	})
+
+	return e
+}
+
+func TestAPIKeyWithConfig(t *testing.T) {
+	store := apikey.New([]config.APIKey{
+		{Key: "limited", PerMinute: 2},
+		{Key: "blocks", Methods: []string{"/block/:bnr"}},
+	})
+	e := apiKeyEcho(store)
+
+	tests := []struct {
+		name   string
+		target string
+		header map[string]string
+		status int
+	}{
+		{"public", "/healthcheck", nil, http.StatusOK},
+		{"missing key", "/block/1", nil, http.StatusUnauthorized},
+		{"invalid key", "/block/1", map[string]string{config.APIKeyHeader: "wrong"}, http.StatusUnauthorized},
+		{"header key", "/block/1", map[string]string{config.APIKeyHeader: "blocks"}, http.StatusOK},
+		{"query key", "/block/1?" + config.APIKeyParam + "=blocks", nil, http.StatusOK},
+		{"route not allowed", "/fees", map[string]string{config.APIKeyHeader: "blocks"}, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.status, serve(e, http.MethodGet, tt.target, tt.header).Code)
+		})
+	}
+
+	header := map[string]string{config.APIKeyHeader: "limited"}
+	assert.Equal(t, http.StatusOK, serve(e, http.MethodGet, "/block/1", header).Code)
+	assert.Equal(t, http.StatusOK, serve(e, http.MethodGet, "/block/1", header).Code)
+	rec := serve(e, http.MethodGet, "/block/1", header)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	retry, err := strconv.Atoi(rec.Header().Get(HeaderRetryAfter))
+	assert.NoError(t, err)
+	assert.True(t, retry > 0 && retry <= 60, retry)
+
+	// disabled store lets all requests through
+	assert.Equal(t, http.StatusOK, serve(apiKeyEcho(apikey.New(nil)), http.MethodGet, "/fees", nil).Code)
+}
+
+func TestAPIKeyGuard(t *testing.T) {
+	store := apikey.New([]config.APIKey{
+		{Key: "limited", Name: "limited", PerMinute: 3},
+		{Key: "calls", Name: "calls", Methods: []string{"eth_call"}},
+	})
+	e := apiKeyEcho(store)
+
+	// every method of batch is charged, rpc route itself isn't
+	header := map[string]string{config.APIKeyHeader: "limited"}
+	assert.Equal(t, http.StatusOK, serve(e, http.MethodPost, "/rpc?method=eth_call&method=eth_call", header).Code)
+	rec := serve(e, http.MethodPost, "/rpc?method=eth_call&method=eth_call", header)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get(HeaderRetryAfter))
+
+	header = map[string]string{config.APIKeyHeader: "calls"}
+	assert.Equal(t, http.StatusOK, serve(e, http.MethodPost, "/rpc?method=eth_call", header).Code)
+	assert.Equal(t, http.StatusForbidden, serve(e, http.MethodPost, "/rpc?method=eth_getLogs", header).Code)
+	assert.Equal(t, http.StatusUnauthorized, serve(e, http.MethodPost, "/rpc?method=eth_call", nil).Code)
+
+	usage := store.Usage()
+	if assert.Len(t, usage, 2) {
+		assert.Equal(t, "limited", usage[1].Name)
+		assert.Equal(t, uint64(3), usage[1].Requests)
+	}
+}
@@ -0,0 +1,52 @@
+package cmiddleware
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"net"
+	"net/http"
+)
+
+// ParseProxies parses CIDR ranges of trusted reverse proxies
+func ParseProxies(cidrs []string) ([]*net.IPNet, error) {
+	proxies := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid trusted proxy range '%s'", cidr)
+		}
+		proxies[i] = n
+	}
+
+	return proxies, nil
+}
+
+// IPExtractor returns echo.IPExtractor that ignores client supplied headers. Without proxies client ip is
+// connection peer address, otherwise it's the nearest X-Forwarded-For address that isn't trusted proxy.
+func IPExtractor(proxies []*net.IPNet) echo.IPExtractor {
+	if len(proxies) == 0 {
+		return echo.ExtractIPDirect()
+	}
+
+	options := []echo.TrustOption{echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false)}
+	for _, p := range proxies {
+		options = append(options, echo.TrustIPRange(p))
+	}
+
+	return echo.ExtractIPFromXFFHeader(options...)
+}
+
+// forwarded reports whether request connection peer is trusted proxy
+func forwarded(req *http.Request, proxies []*net.IPNet) bool {
+	ip := net.ParseIP(echo.ExtractIPDirect()(req))
+	if ip == nil {
+		return false
+	}
+	for _, p := range proxies {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
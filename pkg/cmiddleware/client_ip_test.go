@@ -0,0 +1,61 @@
+package cmiddleware
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseProxies(t *testing.T) {
+	proxies, err := ParseProxies([]string{"10.0.0.0/8", "192.0.2.1/32"})
+	if assert.NoError(t, err) && assert.Len(t, proxies, 2) {
+		assert.Equal(t, "10.0.0.0/8", proxies[0].String())
+		assert.Equal(t, "192.0.2.1/32", proxies[1].String())
+	}
+
+	_, err = ParseProxies([]string{"10.0.0.1"})
+	assert.Error(t, err)
+}
+
+func TestIPExtractor(t *testing.T) {
+	proxies, err := ParseProxies([]string{"10.0.0.0/8"})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	tests := []struct {
+		proxies  bool
+		remote   string
+		header   map[string]string
+		expected string
+	}{
+		// without proxies client headers are ignored
+		{false, "192.0.2.1:1234", nil, "192.0.2.1"},
+		{false, "192.0.2.1:1234", map[string]string{echo.HeaderXForwardedFor: "198.51.100.7"}, "192.0.2.1"},
+		{false, "192.0.2.1:1234", map[string]string{echo.HeaderXRealIP: "198.51.100.7"}, "192.0.2.1"},
+		// X-Forwarded-For is used only on requests from trusted proxy, private & loopback peers aren't trusted
+		{true, "10.0.0.2:1234", map[string]string{echo.HeaderXForwardedFor: "198.51.100.7"}, "198.51.100.7"},
+		{true, "10.0.0.2:1234", map[string]string{echo.HeaderXForwardedFor: "203.0.113.9, 198.51.100.7, 10.0.0.3"}, "198.51.100.7"},
+		{true, "10.0.0.2:1234", map[string]string{echo.HeaderXRealIP: "198.51.100.7"}, "10.0.0.2"},
+		{true, "192.0.2.1:1234", map[string]string{echo.HeaderXForwardedFor: "198.51.100.7"}, "192.0.2.1"},
+		{true, "192.168.1.1:1234", map[string]string{echo.HeaderXForwardedFor: "198.51.100.7"}, "192.168.1.1"},
+		{true, "127.0.0.1:1234", map[string]string{echo.HeaderXForwardedFor: "198.51.100.7"}, "127.0.0.1"},
+	}
+	for i, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = test.remote
+		for k, v := range test.header {
+			req.Header.Set(k, v)
+		}
+
+		var extract echo.IPExtractor
+		if test.proxies {
+			extract = IPExtractor(proxies)
+		} else {
+			extract = IPExtractor(nil)
+		}
+		assert.Equal(t, test.expected, extract(req), i)
+	}
+}
@@ -1,6 +1,11 @@
 package cmiddleware
 
 import (
+	"errors"
+	"github.com/divilla/ethproxy/pkg/ethclient"
+	"github.com/divilla/ethproxy/pkg/logging"
+	"github.com/divilla/ethproxy/pkg/quorum"
+	"github.com/divilla/ethproxy/pkg/throttle"
 	"github.com/labstack/echo/v4"
 	"net/http"
 )
@@ -13,6 +18,27 @@ func HTTPErrorHandler(err error, c echo.Context) {
 				he = herr
 			}
 		}
+	} else if throttle.IsOverloaded(err) {
+		he = &echo.HTTPError{
+			Code:    http.StatusServiceUnavailable,
+			Message: err.Error(),
+		}
+		c.Response().Header().Set(HeaderRetryAfter, "1")
+	} else if berr := (*ethclient.BlockError)(nil); errors.As(err, &berr) {
+		he = &echo.HTTPError{
+			Code:    http.StatusBadRequest,
+			Message: berr.Error(),
+		}
+	} else if verr := (*ethclient.VerificationError)(nil); errors.As(err, &verr) {
+		he = &echo.HTTPError{
+			Code:    http.StatusBadGateway,
+			Message: verr.Error(),
+		}
+	} else if qerr := (*quorum.Error)(nil); errors.As(err, &qerr) {
+		he = &echo.HTTPError{
+			Code:    http.StatusBadGateway,
+			Message: qerr.Error(),
+		}
 	} else {
 		he = &echo.HTTPError{
 			Code:    http.StatusInternalServerError,
@@ -20,6 +46,10 @@ func HTTPErrorHandler(err error, c echo.Context) {
 		}
 	}
 
+	if he.Code == http.StatusInternalServerError {
+		logging.FromContext(c.Request().Context(), c.Logger()).Errorf("request failed with error: %v", err)
+	}
+
 	// Issue #1426
 	code := he.Code
 	message := he.Message
@@ -0,0 +1,96 @@
+package cmiddleware
+
+import (
+	"fmt"
+	"github.com/divilla/ethproxy/pkg/ratelimit"
+	"github.com/labstack/echo/v4"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	HeaderRateLimitLimit     = "X-RateLimit-Limit"
+	HeaderRateLimitRemaining = "X-RateLimit-Remaining"
+	HeaderRateLimitReset     = "X-RateLimit-Reset"
+)
+
+type (
+	RateLimitConfig struct {
+		Limiter *ratelimit.Limiter
+		// Costs are token costs of route paths & json RPC methods, zero cost isn't limited
+		Costs       map[string]float64
+		DefaultCost float64
+		// Key identifies client by 'ip', 'api_key' or 'header:<name>', client ip is used when key isn't present.
+		// Header is used only on requests forwarded by Proxies, since clients can set any header.
+		Key     string
+		Proxies []*net.IPNet
+	}
+)
+
+// RateLimitWithConfig takes route cost from client token bucket, when bucket is empty it returns
+// too many requests with Retry-After header. Bucket state is returned in X-RateLimit-* headers.
+func RateLimitWithConfig(cfg RateLimitConfig) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			if err := cfg.take(ctx, ctx.Path()); err != nil {
+				return err
+			}
+
+			return next(ctx)
+		}
+	}
+}
+
+// RateLimitGuard returns json RPC guard that takes method cost from client token bucket
+func RateLimitGuard(cfg RateLimitConfig) func(ctx echo.Context, method string) error {
+	return func(ctx echo.Context, method string) error {
+		return cfg.take(ctx, method)
+	}
+}
+
+func (cfg RateLimitConfig) take(ctx echo.Context, name string) error {
+	cost, ok := cfg.Costs[name]
+	if !ok {
+		cost = cfg.DefaultCost
+	}
+	if cost <= 0 {
+		return nil
+	}
+
+	res := cfg.Limiter.Take(cfg.client(ctx), cost)
+
+	h := ctx.Response().Header()
+	h.Set(HeaderRateLimitLimit, strconv.FormatFloat(res.Limit, 'f', -1, 64))
+	h.Set(HeaderRateLimitRemaining, strconv.FormatFloat(math.Floor(res.Remaining), 'f', -1, 64))
+	h.Set(HeaderRateLimitReset, seconds(res.Reset))
+
+	if !res.Allowed {
+		h.Set(HeaderRetryAfter, seconds(res.RetryAfter))
+		return echo.NewHTTPError(http.StatusTooManyRequests, fmt.Sprintf("rate limit exceeded, '%s' costs %g tokens, retry in %s seconds", name, cost, seconds(res.RetryAfter)))
+	}
+
+	return nil
+}
+
+func (cfg RateLimitConfig) client(ctx echo.Context) string {
+	switch {
+	case cfg.Key == "api_key":
+		if key, ok := ctx.Get(ContextAPIKey).(string); ok && key != "" {
+			return "key:" + key
+		}
+	case strings.HasPrefix(cfg.Key, "header:") && forwarded(ctx.Request(), cfg.Proxies):
+		if v := ctx.Request().Header.Get(strings.TrimPrefix(cfg.Key, "header:")); v != "" {
+			return "header:" + v
+		}
+	}
+
+	return "ip:" + ctx.RealIP()
+}
+
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
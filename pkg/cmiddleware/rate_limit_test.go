@@ -0,0 +1,185 @@
+package cmiddleware
+
+import (
+	"github.com/divilla/ethproxy/config"
+	"github.com/divilla/ethproxy/pkg/apikey"
+	"github.com/divilla/ethproxy/pkg/ratelimit"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// rateLimitEcho serves routes behind API key & rate limit middleware, '/rpc' runs guard for every method
+// in 'method' query parameter. Bucket of burst tokens refills one token in 1000 seconds, so it doesn't refill during test.
+// Test requests come from trusted proxy, so X-Forwarded-For sets client ip.
+func rateLimitEcho(t *testing.T, key string, burst float64, costs map[string]float64) *echo.Echo {
+	limiter := ratelimit.New(0.001, burst, time.Hour)
+	t.Cleanup(limiter.Done)
+	cfg := RateLimitConfig{
+		Limiter:     limiter,
+		Costs:       costs,
+		DefaultCost: 1,
+		Key:         key,
+		Proxies:     testProxies(t),
+	}
+	store := apikey.New([]config.APIKey{{Key: "alice"}, {Key: "bob"}})
+
+	e := echo.New()
+	e.IPExtractor = IPExtractor(cfg.Proxies)
+	e.HTTPErrorHandler = HTTPErrorHandler
+	e.Use(APIKeyWithConfig(APIKeyConfig{
+		Store:  store,
+		Public: []string{"/healthcheck"},
+		RPC:    []string{"/rpc"},
+	}))
+	e.Use(RateLimitWithConfig(cfg))
+
+	ok := func(ctx echo.Context) error { return ctx.String(http.StatusOK, "ok") }
+	e.GET("/healthcheck", ok)
+	e.GET("/block/:bnr", ok)
+	e.GET("/fees", ok)
+	guard := RateLimitGuard(cfg)
+	e.POST("/rpc", func(ctx echo.Context) error {
+		for _, m := range ctx.QueryParams()["method"] {
+			if err := guard(ctx, m); err != nil {
+				return err
+			}
+		}
+		return ctx.String(http.StatusOK, "ok")
+	})
+
+	return e
+}
+
+func TestRateLimitWithConfig(t *testing.T) {
+	e := rateLimitEcho(t, "ip", 3, map[string]float64{"/fees": 2, "/healthcheck": 0, "/rpc": 0})
+	alice := map[string]string{config.APIKeyHeader: "alice"}
+
+	rec := serve(e, http.MethodGet, "/block/1", alice)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "3", rec.Header().Get(HeaderRateLimitLimit))
+	assert.Equal(t, "2", rec.Header().Get(HeaderRateLimitRemaining))
+	assert.Equal(t, "1000", rec.Header().Get(HeaderRateLimitReset))
+	assert.Empty(t, rec.Header().Get(HeaderRetryAfter))
+
+	rec = serve(e, http.MethodGet, "/fees", alice)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "0", rec.Header().Get(HeaderRateLimitRemaining))
+	assert.Equal(t, "3000", rec.Header().Get(HeaderRateLimitReset))
+
+	rec = serve(e, http.MethodGet, "/block/2", alice)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "0", rec.Header().Get(HeaderRateLimitRemaining))
+	assert.Equal(t, "1000", rec.Header().Get(HeaderRetryAfter))
+	assert.Contains(t, rec.Body.String(), "rate limit exceeded, '/block/:bnr' costs 1 tokens, retry in 1000 seconds")
+
+	// zero cost routes aren't limited & don't report bucket
+	rec = serve(e, http.MethodGet, "/healthcheck", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get(HeaderRateLimitLimit))
+
+	// unauthenticated requests are rejected before they are charged
+	rec = serve(e, http.MethodGet, "/block/1", map[string]string{echo.HeaderXForwardedFor: "10.0.0.2"})
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	rec = serve(e, http.MethodGet, "/fees", map[string]string{config.APIKeyHeader: "bob", echo.HeaderXForwardedFor: "10.0.0.2"})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get(HeaderRateLimitRemaining))
+}
+
+func TestRateLimitGuard(t *testing.T) {
+	e := rateLimitEcho(t, "ip", 3, map[string]float64{"/rpc": 0, "eth_getLogs": 3, "eth_chainId": 0})
+	alice := map[string]string{config.APIKeyHeader: "alice"}
+
+	// route isn't charged, methods are charged by guard
+	rec := serve(e, http.MethodPost, "/rpc?method=eth_chainId&method=eth_blockNumber", alice)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get(HeaderRateLimitRemaining))
+
+	rec = serve(e, http.MethodPost, "/rpc?method=eth_getLogs", alice)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "1000", rec.Header().Get(HeaderRetryAfter))
+	assert.Contains(t, rec.Body.String(), "'eth_getLogs' costs 3 tokens")
+
+	rec = serve(e, http.MethodPost, "/rpc?method=eth_blockNumber&method=eth_blockNumber&method=eth_blockNumber", alice)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "0", rec.Header().Get(HeaderRateLimitRemaining))
+}
+
+func TestRateLimitConfig_Client(t *testing.T) {
+	tests := []struct {
+		key string
+		// second request is made by different client if it's allowed
+		first, second map[string]string
+		allowed       bool
+	}{
+		{"ip", map[string]string{config.APIKeyHeader: "alice"}, map[string]string{config.APIKeyHeader: "bob"}, false},
+		{"ip", map[string]string{config.APIKeyHeader: "alice"}, map[string]string{config.APIKeyHeader: "alice", echo.HeaderXForwardedFor: "10.0.0.2"}, true},
+		{"", map[string]string{config.APIKeyHeader: "alice"}, map[string]string{config.APIKeyHeader: "alice", echo.HeaderXForwardedFor: "10.0.0.2"}, true},
+		{"api_key", map[string]string{config.APIKeyHeader: "alice"}, map[string]string{config.APIKeyHeader: "bob"}, true},
+		{"api_key", map[string]string{config.APIKeyHeader: "alice"}, map[string]string{config.APIKeyHeader: "alice", echo.HeaderXForwardedFor: "10.0.0.2"}, false},
+		{"header:X-Client", map[string]string{config.APIKeyHeader: "alice", "X-Client": "one"}, map[string]string{config.APIKeyHeader: "alice", "X-Client": "two"}, true},
+		{"header:X-Client", map[string]string{config.APIKeyHeader: "alice", "X-Client": "one"}, map[string]string{config.APIKeyHeader: "bob", "X-Client": "one", echo.HeaderXForwardedFor: "10.0.0.2"}, false},
+		// client ip is used when key isn't present
+		{"header:X-Client", map[string]string{config.APIKeyHeader: "alice"}, map[string]string{config.APIKeyHeader: "alice", "X-Client": "one"}, true},
+		{"header:X-Client", map[string]string{config.APIKeyHeader: "alice"}, map[string]string{config.APIKeyHeader: "bob"}, false},
+	}
+	for i, test := range tests {
+		e := rateLimitEcho(t, test.key, 1, nil)
+		assert.Equal(t, http.StatusOK, serve(e, http.MethodGet, "/block/1", test.first).Code, i)
+
+		code := http.StatusTooManyRequests
+		if test.allowed {
+			code = http.StatusOK
+		}
+		assert.Equal(t, code, serve(e, http.MethodGet, "/block/1", test.second).Code, i)
+	}
+
+	// public routes have no API key, so client ip is used
+	e := rateLimitEcho(t, "api_key", 1, nil)
+	assert.Equal(t, http.StatusOK, serve(e, http.MethodGet, "/healthcheck", nil).Code)
+	assert.Equal(t, http.StatusTooManyRequests, serve(e, http.MethodGet, "/healthcheck", map[string]string{config.APIKeyHeader: "alice"}).Code)
+	assert.Equal(t, http.StatusOK, serve(e, http.MethodGet, "/healthcheck", map[string]string{echo.HeaderXForwardedFor: "10.0.0.2"}).Code)
+}
+
+func TestRateLimitConfig_ClientHeader(t *testing.T) {
+	// header key isn't trusted on requests that don't come from proxy
+	e := rateLimitEcho(t, "header:X-Client", 1, nil)
+	request := func(remote, client string) int {
+		req := httptest.NewRequest(http.MethodGet, "/block/1", nil)
+		req.RemoteAddr = remote
+		req.Header.Set(config.APIKeyHeader, "alice")
+		req.Header.Set("X-Client", client)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, request("198.51.100.7:1234", "one"))
+	assert.Equal(t, http.StatusTooManyRequests, request("198.51.100.7:1234", "two"))
+	assert.Equal(t, http.StatusOK, request("192.0.2.1:1234", "two"))
+	assert.Equal(t, http.StatusTooManyRequests, request("192.0.2.2:1234", "two"), "same forwarded client")
+}
+
+// testProxies trusts peer address of httptest requests
+func testProxies(t *testing.T) []*net.IPNet {
+	proxies, err := ParseProxies([]string{"192.0.2.0/24"})
+	assert.NoError(t, err)
+
+	return proxies
+}
+
+func serve(e *echo.Echo, method, target string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	return rec
+}
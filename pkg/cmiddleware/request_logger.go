@@ -0,0 +1,102 @@
+package cmiddleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/divilla/ethproxy/config"
+	"github.com/divilla/ethproxy/pkg/logging"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+	"go.opentelemetry.io/otel/trace"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// requestIDRegexp limits propagated request ids to safe characters
+var requestIDRegexp = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+type (
+	RequestLoggerConfig struct {
+		Logger *logging.Logger
+		// AccessLog writes line with method, uri, status, latency & cache status after every request
+		AccessLog bool
+	}
+)
+
+// RequestLoggerWithConfig assigns request id from X-Request-ID header, or generates one, and returns it in response header.
+// Logger with request id, and trace id when request is traced, is passed in request context,
+// so services, caches & upstream clients log with it.
+func RequestLoggerWithConfig(cfg RequestLoggerConfig) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			req := ctx.Request()
+			id := req.Header.Get(echo.HeaderXRequestID)
+			if !requestIDRegexp.MatchString(id) {
+				id = requestID()
+			}
+			ctx.Response().Header().Set(echo.HeaderXRequestID, id)
+
+			logger := cfg.Logger.With("request_id", id)
+			if sc := trace.SpanContextFromContext(req.Context()); sc.IsValid() {
+				logger = logger.With("trace_id", sc.TraceID().String())
+			}
+			rctx, access := logging.WithAccess(logging.NewContext(req.Context(), logger))
+			ctx.SetRequest(req.WithContext(rctx))
+			ctx.SetLogger(logger)
+
+			start := time.Now()
+			if err := next(ctx); err != nil {
+				ctx.Error(err)
+			}
+			if !cfg.AccessLog {
+				return nil
+			}
+
+			res := ctx.Response()
+			j := log.JSON{
+				"message":    "access",
+				"method":     req.Method,
+				"uri":        RedactedURI(req.RequestURI),
+				"status":     res.Status,
+				"latency_ms": float64(time.Since(start).Microseconds()) / 1000,
+				"bytes_out":  res.Size,
+				"remote_ip":  ctx.RealIP(),
+			}
+			if cache := access.CacheStatus(); cache != "" {
+				j["cache"] = cache
+			}
+			logger.Infoj(j)
+
+			return nil
+		}
+	}
+}
+
+// RedactedURI returns request uri with value of config.APIKeyParam query parameter replaced,
+// so API keys don't end up in logs & traces
+func RedactedURI(uri string) string {
+	u, err := url.ParseRequestURI(uri)
+	if err != nil {
+		return strings.SplitN(uri, "?", 2)[0]
+	}
+
+	q := u.Query()
+	if _, ok := q[config.APIKeyParam]; !ok {
+		return uri
+	}
+	q.Set(config.APIKeyParam, "REDACTED")
+	u.RawQuery = q.Encode()
+
+	return u.RequestURI()
+}
+
+func requestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+
+	return hex.EncodeToString(b)
+}
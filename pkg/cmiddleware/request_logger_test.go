@@ -0,0 +1,63 @@
+package cmiddleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/divilla/ethproxy/pkg/logging"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRedactedURI(t *testing.T) {
+	tests := []struct {
+		uri  string
+		want string
+	}{
+		{"/block/latest", "/block/latest"},
+		{"/block/latest?fields=hash", "/block/latest?fields=hash"},
+		{"/block/latest?api_key=secret", "/block/latest?api_key=REDACTED"},
+		{"/logs?from=1&api_key=secret&to=2", "/logs?api_key=REDACTED&from=1&to=2"},
+		{"/logs?api_key=a&api_key=b", "/logs?api_key=REDACTED"},
+		{"%zz?api_key=secret", "%zz"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, RedactedURI(tt.uri), tt.uri)
+	}
+}
+
+func TestRequestLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	e := echo.New()
+	e.Use(RequestLoggerWithConfig(RequestLoggerConfig{Logger: logging.New(buf, log.INFO), AccessLog: true}))
+	e.GET("/block/:bnr", func(ctx echo.Context) error {
+		return ctx.String(http.StatusOK, "ok")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/block/1?api_key=secret", nil)
+	req.Header.Set(echo.HeaderXRequestID, "client-id")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, "client-id", rec.Header().Get(echo.HeaderXRequestID))
+
+	line := map[string]interface{}{}
+	if assert.NoError(t, json.Unmarshal(buf.Bytes(), &line)) {
+		assert.Equal(t, "access", line["message"])
+		assert.Equal(t, "client-id", line["request_id"])
+		assert.Equal(t, "/block/1?api_key=REDACTED", line["uri"])
+		assert.Equal(t, float64(http.StatusOK), line["status"])
+	}
+	assert.NotContains(t, buf.String(), "secret")
+
+	// invalid request id is replaced by generated one
+	buf.Reset()
+	req = httptest.NewRequest(http.MethodGet, "/block/1", nil)
+	req.Header.Set(echo.HeaderXRequestID, "bad id\n")
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Regexp(t, "^[0-9a-f]{32}$", rec.Header().Get(echo.HeaderXRequestID))
+}
@@ -0,0 +1,61 @@
+package cmiddleware
+
+import (
+	"fmt"
+	"github.com/divilla/ethproxy/pkg/tracing"
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+)
+
+// Tracing starts server span for every request, continuing trace from client's traceparent header.
+// Span is passed in request context, so caches, coalescer & upstream calls are traced as its children.
+func Tracing() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			req := ctx.Request()
+			rctx, span := tracing.Tracer().Start(tracing.Extract(req.Context(), req.Header), "HTTP "+req.Method,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					semconv.HTTPMethodKey.String(req.Method),
+					semconv.HTTPTargetKey.String(RedactedURI(req.RequestURI)),
+					semconv.HTTPClientIPKey.String(ctx.RealIP()),
+				))
+			defer span.End()
+			ctx.SetRequest(req.WithContext(rctx))
+
+			err := next(ctx)
+			if err != nil {
+				ctx.Error(err)
+			}
+
+			status := ctx.Response().Status
+			span.SetAttributes(semconv.HTTPStatusCodeKey.Int(status))
+			if status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(status))
+			}
+
+			return nil
+		}
+	}
+}
+
+// TraceRoute names request span by matched route of chain, it's used by chain routers,
+// since route isn't known by the time Tracing starts the span
+func TraceRoute(chain string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			span := trace.SpanFromContext(ctx.Request().Context())
+			span.SetName(fmt.Sprintf("%s %s", ctx.Request().Method, ctx.Path()))
+			span.SetAttributes(
+				semconv.HTTPRouteKey.String(ctx.Path()),
+				attribute.String("chain", chain),
+			)
+
+			return next(ctx)
+		}
+	}
+}
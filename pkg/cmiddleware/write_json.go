@@ -0,0 +1,15 @@
+package cmiddleware
+
+import (
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+// WriteJSON writes service json result, service error is returned to be turned into http error by HTTPErrorHandler
+func WriteJSON(ctx echo.Context, json []byte, err error) error {
+	if err != nil {
+		return err
+	}
+
+	return ctx.Blob(http.StatusOK, echo.MIMEApplicationJSON, json)
+}
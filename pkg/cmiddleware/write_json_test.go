@@ -0,0 +1,30 @@
+package cmiddleware
+
+import (
+	"github.com/divilla/ethproxy/pkg/ethclient"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestWriteJSON(t *testing.T) {
+	e := echo.New()
+	e.HTTPErrorHandler = HTTPErrorHandler
+	e.GET("/ok", func(ctx echo.Context) error {
+		return WriteJSON(ctx, []byte(`{"number":"0x1"}`), nil)
+	})
+	e.GET("/block", func(ctx echo.Context) error {
+		return WriteJSON(ctx, nil, &ethclient.BlockError{Message: "block range start '2' is after end '1'"})
+	})
+
+	rec := serve(e, http.MethodGet, "/ok", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, echo.MIMEApplicationJSON, rec.Header().Get(echo.HeaderContentType))
+	assert.Equal(t, `{"number":"0x1"}`, rec.Body.String())
+
+	// block errors are bad requests
+	rec = serve(e, http.MethodGet, "/block", nil)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.JSONEq(t, `{"message":"block range start '2' is after end '1'"}`, rec.Body.String())
+}
@@ -0,0 +1,51 @@
+package ethclient
+
+import (
+	"encoding/hex"
+	"fmt"
+	"golang.org/x/crypto/sha3"
+	"regexp"
+	"strings"
+)
+
+var addressRegexp = regexp.MustCompile(`^0x[0-9a-fA-F]{40}$`)
+
+// Keccak256 returns legacy Keccak-256 hash used by Ethereum
+func Keccak256(data ...[]byte) []byte {
+	h := sha3.NewLegacyKeccak256()
+	for _, d := range data {
+		h.Write(d)
+	}
+
+	return h.Sum(nil)
+}
+
+// ChecksumAddress returns EIP-55 mixed case checksum address
+func ChecksumAddress(address string) string {
+	lower := strings.ToLower(strings.TrimPrefix(address, "0x"))
+	hash := hex.EncodeToString(Keccak256([]byte(lower)))
+
+	res := []byte(lower)
+	for i, ch := range res {
+		if ch >= 'a' && ch <= 'f' && hash[i] >= '8' {
+			res[i] = ch - 32
+		}
+	}
+
+	return "0x" + string(res)
+}
+
+// ParseAddress validates address and returns it lowercase, mixed case address must have valid EIP-55 checksum
+func ParseAddress(address string) (string, error) {
+	if !addressRegexp.MatchString(address) {
+		return "", fmt.Errorf("address '%s' is not valid 20 byte hex address", address)
+	}
+
+	lower := strings.ToLower(address)
+	body := address[2:]
+	if body != strings.ToLower(body) && body != strings.ToUpper(body) && address != ChecksumAddress(address) {
+		return "", fmt.Errorf("address '%s' has invalid checksum", address)
+	}
+
+	return lower, nil
+}
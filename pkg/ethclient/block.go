@@ -0,0 +1,181 @@
+package ethclient
+
+import (
+	"context"
+	"fmt"
+	"github.com/divilla/ethproxy/interfaces"
+	"strconv"
+	"strings"
+)
+
+const (
+	TagLatest    = "latest"
+	TagEarliest  = "earliest"
+	TagPending   = "pending"
+	TagSafe      = "safe"
+	TagFinalized = "finalized"
+)
+
+type (
+	// BlockParam is parsed block identifier, either concrete block number or block tag with optional offset
+	BlockParam struct {
+		Tag    string
+		Number uint64
+		Offset uint64
+	}
+
+	// BlockError is returned for block parameter that is invalid or can't be resolved to block number
+	// and for invalid block range
+	BlockError struct {
+		Message string
+	}
+
+	// TagResolver returns block number of block tag that isn't tracked by poller, i.e. from upstream
+	TagResolver func(ctx context.Context, tag string) (uint64, error)
+)
+
+func (e *BlockError) Error() string {
+	return e.Message
+}
+
+// ParseBlockParam parses decimal or 0x-prefixed hex block number, block tag or relative form like 'latest-10'
+func ParseBlockParam(value string) (BlockParam, error) {
+	if strings.HasPrefix(value, "0x") || strings.HasPrefix(value, "0X") {
+		nr, err := HexToUInt(strings.ToLower(value))
+		if err != nil || len(value) == 2 {
+			return BlockParam{}, fmt.Errorf("block number '%s' is not valid hex number", value)
+		}
+
+		return BlockParam{Number: nr}, nil
+	}
+
+	if nr, err := strconv.ParseUint(value, 10, 64); err == nil {
+		return BlockParam{Number: nr}, nil
+	}
+
+	tag, offset, relative := value, "", false
+	if i := strings.Index(value, "-"); i >= 0 {
+		tag, offset, relative = value[:i], value[i+1:], true
+	}
+
+	switch tag {
+	case TagLatest, TagSafe, TagFinalized:
+	case TagEarliest:
+		if relative {
+			return BlockParam{}, fmt.Errorf("block tag '%s' does not support offset", tag)
+		}
+		return BlockParam{Number: 0}, nil
+	case TagPending:
+		if relative {
+			return BlockParam{}, fmt.Errorf("block tag '%s' does not support offset", tag)
+		}
+	default:
+		return BlockParam{}, fmt.Errorf("block number '%s' is not valid integer, hex number or block tag", value)
+	}
+
+	bp := BlockParam{Tag: tag}
+	if relative {
+		nr, err := strconv.ParseUint(offset, 10, 64)
+		if err != nil {
+			return BlockParam{}, fmt.Errorf("block offset '%s' is not valid integer", offset)
+		}
+		bp.Offset = nr
+	}
+
+	return bp, nil
+}
+
+// IsTag returns true if block parameter has to be resolved from block tag
+func (p BlockParam) IsTag() bool {
+	return p.Tag != ""
+}
+
+// Resolve returns block number relative to given tag block number
+func (p BlockParam) Resolve(tagNumber uint64) (uint64, error) {
+	if p.Offset > tagNumber {
+		return 0, fmt.Errorf("block offset '%d' is larger than '%s' block number '%d'", p.Offset, p.Tag, tagNumber)
+	}
+
+	return tagNumber - p.Offset, nil
+}
+
+// ResolveTracked returns concrete block number, tags are resolved from numbers tracked by poller
+func (p BlockParam) ResolveTracked(tracker interfaces.FinalityTracker) (uint64, error) {
+	if !p.IsTag() {
+		return p.Number, nil
+	}
+
+	var tagNumber uint64
+	switch p.Tag {
+	case TagLatest:
+		tagNumber = tracker.LatestBlockNumber()
+	case TagSafe:
+		tagNumber = tracker.SafeBlockNumber()
+	case TagFinalized:
+		tagNumber = tracker.FinalizedBlockNumber()
+	}
+	if tagNumber == 0 {
+		return 0, fmt.Errorf("block tag '%s' can't be resolved to block number", p.Tag)
+	}
+
+	return p.Resolve(tagNumber)
+}
+
+// ResolveBlockNumber parses block number, tag or relative form like 'latest-10' and resolves it to concrete block number,
+// see BlockParam.ResolveNumber
+func ResolveBlockNumber(ctx context.Context, value string, tracker interfaces.FinalityTracker, untracked TagResolver) (uint64, error) {
+	bp, err := ParseBlockParam(value)
+	if err != nil {
+		return 0, &BlockError{Message: err.Error()}
+	}
+
+	return bp.ResolveNumber(ctx, tracker, untracked)
+}
+
+// ResolveNumber returns concrete block number, tags are resolved from numbers tracked by poller and tags it doesn't
+// track by untracked, without it they are BlockError. Pending block has no number.
+func (p BlockParam) ResolveNumber(ctx context.Context, tracker interfaces.FinalityTracker, untracked TagResolver) (uint64, error) {
+	if !p.IsTag() {
+		return p.Number, nil
+	}
+
+	if p.Tag == TagPending {
+		return 0, &BlockError{Message: fmt.Sprintf("block tag '%s' can't be resolved to block number", p.Tag)}
+	}
+
+	nr, err := p.ResolveTracked(tracker)
+	if err == nil {
+		return nr, nil
+	}
+	if untracked == nil {
+		return 0, &BlockError{Message: err.Error()}
+	}
+
+	tagNumber, err := untracked(ctx, p.Tag)
+	if err != nil {
+		return 0, err
+	}
+
+	nr, err = p.Resolve(tagNumber)
+	if err != nil {
+		return 0, &BlockError{Message: err.Error()}
+	}
+
+	return nr, nil
+}
+
+// ValidateBlockRange returns BlockError unless block range starts at or before its end, holds at most maxRange blocks
+// and ends at or before latest block
+func ValidateBlockRange(from, to, maxRange, latest uint64) error {
+	if from > to {
+		return &BlockError{Message: fmt.Sprintf("block range start '%d' is after end '%d'", from, to)}
+	}
+	if to-from >= maxRange {
+		return &BlockError{Message: fmt.Sprintf("block range '%d-%d' is larger than %d blocks", from, to, maxRange)}
+	}
+	if to > latest {
+		return &BlockError{Message: fmt.Sprintf("block range end '%d' is after latest block '%d'", to, latest)}
+	}
+
+	return nil
+}
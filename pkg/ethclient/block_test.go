@@ -0,0 +1,105 @@
+package ethclient
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseBlockParam(t *testing.T) {
+	valid := map[string]BlockParam{
+		"12988583":    {Number: 12988583},
+		"0x100":       {Number: 256},
+		"0XFF":        {Number: 255},
+		"earliest":    {Number: 0},
+		"latest":      {Tag: TagLatest},
+		"pending":     {Tag: TagPending},
+		"safe":        {Tag: TagSafe},
+		"finalized":   {Tag: TagFinalized},
+		"latest-10":   {Tag: TagLatest, Offset: 10},
+		"finalized-3": {Tag: TagFinalized, Offset: 3},
+	}
+	for value, expected := range valid {
+		bp, err := ParseBlockParam(value)
+		if assert.NoError(t, err, value) {
+			assert.Equal(t, expected, bp, value)
+		}
+	}
+
+	invalid := []string{"", "0x", "0xzz", "-1", "latest-", "latest-x", "pending-1", "earliest-1", "newest"}
+	for _, value := range invalid {
+		_, err := ParseBlockParam(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestBlockParam_Resolve(t *testing.T) {
+	nr, err := BlockParam{Tag: TagLatest, Offset: 10}.Resolve(100)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(90), nr)
+
+	_, err = BlockParam{Tag: TagLatest, Offset: 101}.Resolve(100)
+	assert.Error(t, err)
+}
+
+type tracker struct {
+	latest, safe, finalized uint64
+}
+
+func (t tracker) LatestBlockNumber() uint64    { return t.latest }
+func (t tracker) SafeBlockNumber() uint64      { return t.safe }
+func (t tracker) FinalizedBlockNumber() uint64 { return t.finalized }
+
+func TestResolveBlockNumber(t *testing.T) {
+	tr := tracker{latest: 100, finalized: 80}
+	var untracked []string
+	fetch := func(_ context.Context, tag string) (uint64, error) {
+		untracked = append(untracked, tag)
+		return 90, nil
+	}
+
+	tests := []struct {
+		value    string
+		expected uint64
+	}{
+		{"12", 12},
+		{"0xc", 12},
+		{"earliest", 0},
+		{"latest", 100},
+		{"latest-10", 90},
+		{"finalized-5", 75},
+		{"safe-1", 89},
+	}
+	for _, test := range tests {
+		nr, err := ResolveBlockNumber(context.Background(), test.value, tr, fetch)
+		if assert.NoError(t, err, test.value) {
+			assert.Equal(t, test.expected, nr, test.value)
+		}
+	}
+	assert.Equal(t, []string{TagSafe}, untracked)
+
+	// invalid & unresolvable blocks are BlockError, untracked tag fails without resolver
+	for _, value := range []string{"x", "pending", "latest-101", "safe"} {
+		_, err := ResolveBlockNumber(context.Background(), value, tr, nil)
+		assert.IsType(t, &BlockError{}, err, value)
+	}
+	_, err := ResolveBlockNumber(context.Background(), "safe-91", tr, fetch)
+	assert.IsType(t, &BlockError{}, err)
+
+	// resolver error is returned as is
+	_, err = ResolveBlockNumber(context.Background(), "safe", tracker{latest: 100}, func(context.Context, string) (uint64, error) {
+		return 0, errors.New("upstream failed")
+	})
+	assert.EqualError(t, err, "upstream failed")
+}
+
+func TestValidateBlockRange(t *testing.T) {
+	assert.NoError(t, ValidateBlockRange(10, 10, 100, 10))
+	assert.NoError(t, ValidateBlockRange(0, 99, 100, 1000))
+
+	for _, rng := range [][2]uint64{{11, 10}, {0, 100}, {995, 1001}} {
+		err := ValidateBlockRange(rng[0], rng[1], 100, 1000)
+		assert.IsType(t, &BlockError{}, err, rng)
+	}
+}
@@ -1,9 +1,18 @@
 package ethclient
 
 import (
+	"context"
+	"fmt"
+	"github.com/divilla/ethproxy/config"
 	"github.com/divilla/ethproxy/interfaces"
+	"github.com/divilla/ethproxy/pkg/logging"
+	"github.com/divilla/ethproxy/pkg/tracing"
 	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
+	"go.opentelemetry.io/otel/attribute"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -16,6 +25,15 @@ type (
 		refreshLatest     time.Duration
 		baseRequest       string
 		latestBlockNumber uint64
+		safeBlockNumber   uint64
+		finalBlockNumber  uint64
+		finalityTags      bool
+		safeDistance      uint64
+		finalDistance     uint64
+		blockTime         int64
+		adaptive          bool
+		headChanged       time.Time
+		fallbacks         func() []interfaces.HttpClient
 		done              chan struct{}
 		fetchMap          map[string]fetch
 		mx                sync.Mutex
@@ -32,7 +50,9 @@ type (
 	}
 )
 
-func New(client interfaces.HttpClient, logger interfaces.Logger, refreshInterval time.Duration) *EthereumHttpClient {
+// New creates client with chain profile and starts latest block poller, profile without block time
+// polls every refreshInterval
+func New(client interfaces.HttpClient, logger interfaces.Logger, refreshInterval time.Duration, profile config.Profile) *EthereumHttpClient {
 	c := &EthereumHttpClient{
 		client:        client,
 		logger:        logger,
@@ -41,16 +61,19 @@ func New(client interfaces.HttpClient, logger interfaces.Logger, refreshInterval
 		done:          make(chan struct{}),
 		fetchMap:      make(map[string]fetch),
 	}
+	c.profile(profile)
 
 	c.setLatestBlockNumber()
+	c.setFinalityBlockNumbers()
 
 	go func(c *EthereumHttpClient) {
 		for {
 			select {
 			case <-c.done:
 				return
-			case <-time.After(c.refreshLatest):
+			case <-time.After(c.refreshInterval()):
 				c.setLatestBlockNumber()
+				c.setFinalityBlockNumbers()
 			}
 		}
 	}(c)
@@ -58,16 +81,141 @@ func New(client interfaces.HttpClient, logger interfaces.Logger, refreshInterval
 	return c
 }
 
+// Verify enables verification of block hash & transactions root, blocks failing verification
+// are fetched from fallback upstreams until one passes, they never reach the caller
+func (c *EthereumHttpClient) Verify(fallbacks func() []interfaces.HttpClient) {
+	c.fallbacks = fallbacks
+}
+
 func (c *EthereumHttpClient) LatestBlockNumber() uint64 {
-	return c.latestBlockNumber
+	return atomic.LoadUint64(&c.latestBlockNumber)
+}
+
+// SafeBlockNumber returns number of the latest 'safe' block, 0 if chain doesn't support the tag
+func (c *EthereumHttpClient) SafeBlockNumber() uint64 {
+	return atomic.LoadUint64(&c.safeBlockNumber)
+}
+
+// FinalizedBlockNumber returns number of the latest 'finalized' block, 0 if chain doesn't support the tag
+func (c *EthereumHttpClient) FinalizedBlockNumber() uint64 {
+	return atomic.LoadUint64(&c.finalBlockNumber)
+}
+
+func (c *EthereumHttpClient) GetLatestBlock(ctx context.Context) ([]byte, error) {
+	return c.getBlockByNumber(ctx, TagLatest, true)
+}
+
+func (c *EthereumHttpClient) GetBlockByNumber(ctx context.Context, nr uint64) ([]byte, error) {
+	return c.getBlockByNumber(ctx, UIntToHex(nr), true)
+}
+
+func (c *EthereumHttpClient) GetBlockByTag(ctx context.Context, tag string) ([]byte, error) {
+	return c.getBlockByNumber(ctx, tag, true)
+}
+
+// GetBlocksByNumber fetches blocks in single batch request, results are in the order of numbers
+func (c *EthereumHttpClient) GetBlocksByNumber(ctx context.Context, nrs []uint64, full bool) ([][]byte, error) {
+	reqs := make([]*jsonRPCRequest, len(nrs))
+	for i, nr := range nrs {
+		reqs[i] = request("getBlockByNumber").
+			param(UIntToHex(nr)).
+			param(full)
+	}
+
+	json, err := c.client.Post(ctx, batch(reqs))
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := parseBatchResponse(json, reqs)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, nr := range nrs {
+		if results[i], err = c.verified(ctx, results[i], UIntToHex(nr), full); err != nil {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+// GetBlockHeaderByNumber returns block with transaction hashes instead of transaction objects
+func (c *EthereumHttpClient) GetBlockHeaderByNumber(ctx context.Context, nr uint64) ([]byte, error) {
+	return c.getBlockByNumber(ctx, UIntToHex(nr), false)
+}
+
+// GetBlockHeaderByTag returns block with transaction hashes instead of transaction objects
+func (c *EthereumHttpClient) GetBlockHeaderByTag(ctx context.Context, tag string) ([]byte, error) {
+	return c.getBlockByNumber(ctx, tag, false)
 }
 
-func (c *EthereumHttpClient) GetLatestBlock() ([]byte, error) {
-	return c.getBlockByNumber("latest")
+// GetBalance returns account balance in wei as hex quantity at given block number or tag
+func (c *EthereumHttpClient) GetBalance(ctx context.Context, address string, block string) ([]byte, error) {
+	return c.call(ctx, "getBalance", address, block)
 }
 
-func (c *EthereumHttpClient) GetBlockByNumber(nr uint64) ([]byte, error) {
-	return c.getBlockByNumber(UIntToHex(nr))
+// GetTransactionCount returns account nonce as hex quantity at given block number or tag
+func (c *EthereumHttpClient) GetTransactionCount(ctx context.Context, address string, block string) ([]byte, error) {
+	return c.call(ctx, "getTransactionCount", address, block)
+}
+
+// GetCode returns contract code at given block number or tag
+func (c *EthereumHttpClient) GetCode(ctx context.Context, address string, block string) ([]byte, error) {
+	return c.call(ctx, "getCode", address, block)
+}
+
+// GetStorageAt returns 32 byte storage slot value at given block number or tag
+func (c *EthereumHttpClient) GetStorageAt(ctx context.Context, address string, slot string, block string) ([]byte, error) {
+	return c.call(ctx, "getStorageAt", address, slot, block)
+}
+
+// CallContract executes message call without creating transaction at given block number or tag
+func (c *EthereumHttpClient) CallContract(ctx context.Context, msg map[string]string, block string) ([]byte, error) {
+	return c.call(ctx, "call", msg, block)
+}
+
+// GetTransactionByHash returns transaction by hash, it's empty when upstream doesn't know the transaction
+func (c *EthereumHttpClient) GetTransactionByHash(ctx context.Context, hash string) ([]byte, error) {
+	return c.call(ctx, "getTransactionByHash", hash)
+}
+
+// GetTransactionReceipt returns receipt of transaction by hash, it's empty until transaction is included in block
+func (c *EthereumHttpClient) GetTransactionReceipt(ctx context.Context, hash string) ([]byte, error) {
+	return c.call(ctx, "getTransactionReceipt", hash)
+}
+
+// GetBlockReceipts returns receipts of all block transactions, it's not supported by all upstreams
+func (c *EthereumHttpClient) GetBlockReceipts(ctx context.Context, block string) ([]byte, error) {
+	return c.call(ctx, "getBlockReceipts", block)
+}
+
+// FeeHistory returns base fees, gas used ratios & priority fee percentiles of blocks ending with newest block
+func (c *EthereumHttpClient) FeeHistory(ctx context.Context, blocks uint64, newest string, percentiles []float64) ([]byte, error) {
+	return c.call(ctx, "feeHistory", UIntToHex(blocks), newest, percentiles)
+}
+
+// GetLogs returns logs matching filter in block range, topics are positional and nil topic matches any value
+func (c *EthereumHttpClient) GetLogs(ctx context.Context, addresses []string, topics [][]string, from, to uint64) ([]byte, error) {
+	filter := map[string]interface{}{
+		"fromBlock": UIntToHex(from),
+		"toBlock":   UIntToHex(to),
+	}
+	if len(addresses) > 0 {
+		filter["address"] = addresses
+	}
+	if len(topics) > 0 {
+		ts := make([]interface{}, len(topics))
+		for i, t := range topics {
+			if len(t) > 0 {
+				ts[i] = t
+			}
+		}
+		filter["topics"] = ts
+	}
+
+	return c.call(ctx, "getLogs", filter)
 }
 
 func (c *EthereumHttpClient) Done() {
@@ -77,41 +225,188 @@ func (c *EthereumHttpClient) Done() {
 
 func (c *EthereumHttpClient) setLatestBlockNumber() {
 	req := request("blockNumber")
-	json, err := c.client.Post(req.String())
+	json, err := c.client.Post(context.Background(), req.String())
 	if err != nil {
-		c.logger.Errorf("EthereumHttpClient failed to execute request '%s', with error: %w", req.String(), err)
+		c.logger.Errorf("EthereumHttpClient failed to execute request '%s', with error: %v", req.String(), err)
 	}
 
 	resHex, err := parseResponse(json, req)
 	if err != nil {
-		c.logger.Errorf("EthereumHttpClient failed to parse response '%s' from request '%s' with error: %w", json, req, err)
+		c.logger.Errorf("EthereumHttpClient failed to parse response '%s' from request '%s' with error: %v", json, req, err)
 	}
 
 	resInt, err := HexToUInt(string(resHex))
 	if err != nil {
-		c.logger.Errorf("EthereumHttpClient failed to parse hex '%s' to int, with error: %w", resHex, err)
+		c.logger.Errorf("EthereumHttpClient failed to parse hex '%s' to int, with error: %v", resHex, err)
+	}
+
+	c.observeBlockTime(c.LatestBlockNumber(), resInt, time.Now())
+	atomic.StoreUint64(&c.latestBlockNumber, resInt)
+}
+
+func (c *EthereumHttpClient) setFinalityBlockNumbers() {
+	if !c.finalityTags {
+		return
+	}
+
+	safe, err := c.blockNumberByTag(TagSafe)
+	if err != nil {
+		return
+	}
+
+	final, err := c.blockNumberByTag(TagFinalized)
+	if err != nil {
+		return
 	}
 
-	c.latestBlockNumber = resInt
+	atomic.StoreUint64(&c.safeBlockNumber, safe)
+	atomic.StoreUint64(&c.finalBlockNumber, final)
 }
 
-func (c *EthereumHttpClient) getBlockByNumber(nr string) ([]byte, error) {
-	if _, ok := c.fetchMap[nr]; !ok {
-		c.mx.Lock()
-		c.fetchMap[nr] = fetch{
+// blockNumberByTag fetches block header by tag and returns its number. Tracking is disabled only when upstream
+// doesn't support the method or the tag, other errors & missing block are retried by the next poll.
+func (c *EthereumHttpClient) blockNumberByTag(tag string) (uint64, error) {
+	req := request("getBlockByNumber").
+		param(tag).
+		param(false)
+	json, err := c.client.Post(context.Background(), req.String())
+	if err != nil {
+		c.logger.Errorf("EthereumHttpClient failed to execute request '%s', with error: %v", req.String(), err)
+		return 0, err
+	}
+
+	header, err := parseResponse(json, req)
+	if err == nil && len(header) == 0 {
+		err = errors.Errorf("block with tag '%s' not found", tag)
+	}
+	if IsMethodNotFound(err) || IsInvalidParams(err) {
+		c.logger.Infof("EthereumHttpClient disabled finality tracking, upstream doesn't support '%s' tag: %v", tag, err)
+		c.finalityTags = false
+		atomic.StoreUint64(&c.safeBlockNumber, 0)
+		atomic.StoreUint64(&c.finalBlockNumber, 0)
+	} else if err != nil {
+		c.logger.Errorf("EthereumHttpClient failed to fetch '%s' block number, it's retried by the next poll: %v", tag, err)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	nr, err := HexToUInt(gjson.GetBytes(header, "number").String())
+	if err != nil {
+		c.logger.Errorf("EthereumHttpClient failed to parse '%s' block number, with error: %v", tag, err)
+	}
+
+	return nr, err
+}
+
+func (c *EthereumHttpClient) getBlockByNumber(ctx context.Context, nr string, full bool) ([]byte, error) {
+	return c.coalesce(ctx, fmt.Sprintf("getBlockByNumber/%s/%t", nr, full), func(ctx context.Context) ([]byte, error) {
+		req := request("getBlockByNumber").
+			param(nr).
+			param(full)
+
+		json, err := c.client.Post(ctx, req.String())
+		if err != nil {
+			return nil, err
+		}
+
+		block, err := parseResponse(json, req)
+		if err != nil {
+			return nil, err
+		}
+
+		return c.verified(ctx, block, nr, full)
+	})
+}
+
+// verified returns block if it passes verification, otherwise it returns the first block
+// fetched from fallback upstreams that passes it. Unverifiable blocks are returned and logged,
+// since no upstream can verify them. Verification is disabled without fallbacks.
+func (c *EthereumHttpClient) verified(ctx context.Context, block []byte, nr string, full bool) ([]byte, error) {
+	if c.fallbacks == nil || len(block) == 0 {
+		return block, nil
+	}
+
+	logger := logging.FromContext(ctx, c.logger)
+	v, err := VerifyBlock(block)
+	if err == nil {
+		if v == Unverifiable {
+			logger.Infof("EthereumHttpClient returned block '%s' that is %s", nr, v)
+		}
+		return block, nil
+	}
+	logger.Errorf("EthereumHttpClient rejected block '%s' from primary upstream: %v", nr, err)
+
+	for _, fallback := range c.fallbacks() {
+		fb, ferr := Call(ctx, fallback, "getBlockByNumber", nr, full)
+		if ferr == nil && len(fb) > 0 {
+			v, ferr = VerifyBlock(fb)
+		}
+		if ferr != nil {
+			logger.Errorf("EthereumHttpClient rejected block '%s' from fallback upstream: %v", nr, ferr)
+			continue
+		}
+		if len(fb) > 0 {
+			if v == Unverifiable {
+				logger.Infof("EthereumHttpClient returned block '%s' from fallback upstream that is %s", nr, v)
+			}
+			return fb, nil
+		}
+	}
+
+	return nil, err
+}
+
+// call executes json RPC method, concurrent calls with the same params are coalesced
+func (c *EthereumHttpClient) call(ctx context.Context, method string, params ...interface{}) ([]byte, error) {
+	return c.coalesce(ctx, fmt.Sprintf("%s/%v", method, params), func(ctx context.Context) ([]byte, error) {
+		return Call(ctx, c.client, method, params...)
+	})
+}
+
+// ChainID returns chain id reported by upstream
+func ChainID(ctx context.Context, client interfaces.HttpClient) (uint64, error) {
+	res, err := Call(ctx, client, "chainId")
+	if err != nil {
+		return 0, err
+	}
+
+	return HexToUInt(string(res))
+}
+
+// Call executes single json RPC method on given upstream, calls aren't coalesced
+func Call(ctx context.Context, client interfaces.HttpClient, method string, params ...interface{}) ([]byte, error) {
+	req := request(method)
+	for _, p := range params {
+		req.param(p)
+	}
+
+	json, err := client.Post(ctx, req.String())
+	if err != nil {
+		return nil, err
+	}
+
+	return parseResponse(json, req)
+}
+
+// coalesce executes single call for all concurrent requests with the same key,
+// response is passed to all requests arriving within a second via channels.
+// Wait is traced, upstream call is traced as child of request that started it.
+func (c *EthereumHttpClient) coalesce(ctx context.Context, key string, call func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+	ctx, span := tracing.Start(ctx, "ethclient.coalesce", attribute.String("coalesce.key", key))
+
+	c.mx.Lock()
+	f, ok := c.fetchMap[key]
+	span.SetAttributes(attribute.Bool("coalesce.joined", ok))
+	if !ok {
+		f = fetch{
 			add:      make(chan struct{}, 1000),
 			response: make(chan response),
 		}
-		c.mx.Unlock()
+		c.fetchMap[key] = f
 
 		go func(c *EthereumHttpClient) {
-			req := request("getBlockByNumber").
-				param(nr).
-				param(true)
-
-			json, err := c.client.Post(req.String())
-			json, err = parseResponse(json, req)
-
+			json, err := call(ctx)
 			res := response{
 				json: json,
 				err:  err,
@@ -119,27 +414,25 @@ func (c *EthereumHttpClient) getBlockByNumber(nr string) ([]byte, error) {
 
 			for {
 				select {
-				case <-c.fetchMap[nr].add:
-					c.fetchMap[nr].response <- res
+				case <-f.add:
+					f.response <- res
 				case <-time.After(time.Second):
-					if len(c.fetchMap[nr].add) == 0 {
-						c.mx.Lock()
-						delete(c.fetchMap, nr)
+					c.mx.Lock()
+					if len(f.add) == 0 {
+						delete(c.fetchMap, key)
 						c.mx.Unlock()
 						return
 					}
+					c.mx.Unlock()
 				}
 			}
 		}(c)
+	}
+	f.add <- struct{}{}
+	c.mx.Unlock()
 
-		c.fetchMap[nr].add <- struct{}{}
-
-		res := <-c.fetchMap[nr].response
-		return res.json, res.err
-	} else {
-		c.fetchMap[nr].add <- struct{}{}
+	res := <-f.response
+	tracing.End(span, res.err)
 
-		res := <-c.fetchMap[nr].response
-		return res.json, res.err
-	}
+	return res.json, res.err
 }
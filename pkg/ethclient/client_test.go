@@ -0,0 +1,90 @@
+package ethclient
+
+import (
+	"context"
+	"fmt"
+	"github.com/divilla/ethproxy/config"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEthereumHttpClient_SetFinalityBlockNumbers(t *testing.T) {
+	logger := echo.New().Logger
+	logger.SetOutput(ioutil.Discard)
+	n := &node{head: 100}
+	c := New(n, logger, time.Hour, config.Profile{FinalityTags: true})
+	defer c.Done()
+	assert.Equal(t, uint64(90), c.SafeBlockNumber())
+
+	// transient errors keep tracking enabled & the last numbers
+	for _, res := range []string{
+		`"result":null`,
+		`"error":{"code":-32000,"message":"header not found"}`,
+		`"result":{"number":"0xzz"}`,
+	} {
+		n.respond(res)
+		c.setFinalityBlockNumbers()
+		assert.True(t, c.finalityTags, res)
+		assert.Equal(t, uint64(90), c.SafeBlockNumber(), res)
+	}
+
+	n.head = 200
+	n.respond("")
+	c.setFinalityBlockNumbers()
+	assert.Equal(t, uint64(190), c.SafeBlockNumber())
+	assert.Equal(t, uint64(190), c.FinalizedBlockNumber())
+
+	// unsupported tag disables tracking
+	n.respond(`"error":{"code":-32602,"message":"invalid argument 0: hex string without 0x prefix"}`)
+	c.setFinalityBlockNumbers()
+	assert.False(t, c.finalityTags)
+	assert.Equal(t, uint64(0), c.SafeBlockNumber())
+	assert.Equal(t, uint64(0), c.FinalizedBlockNumber())
+}
+
+// batchNode answers batch of eth_getBlockByNumber requests in reverse order, without response to block dropped
+type batchNode struct {
+	dropped  string
+	requests []string
+}
+
+func (n *batchNode) Url(string) error {
+	return nil
+}
+
+func (n *batchNode) Post(_ context.Context, body string) ([]byte, error) {
+	var responses []string
+	for _, req := range gjson.Parse(body).Array() {
+		nr := req.Get("params.0").String()
+		n.requests = append(n.requests, req.Get("method").String()+"/"+nr)
+		if nr == n.dropped {
+			continue
+		}
+		responses = append([]string{fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"result":{"number":"%s"}}`, req.Get("id").Raw, nr)}, responses...)
+	}
+
+	return []byte("[" + strings.Join(responses, ",") + "]"), nil
+}
+
+func TestEthereumHttpClient_GetBlocksByNumber(t *testing.T) {
+	n := &batchNode{}
+	c := &EthereumHttpClient{client: n}
+
+	// batch is sent as single request, results are in the order of numbers whatever the order of responses
+	res, err := c.GetBlocksByNumber(context.Background(), []uint64{7, 3, 5}, true)
+	if assert.NoError(t, err) && assert.Len(t, res, 3) {
+		assert.JSONEq(t, `{"number":"0x7"}`, string(res[0]))
+		assert.JSONEq(t, `{"number":"0x3"}`, string(res[1]))
+		assert.JSONEq(t, `{"number":"0x5"}`, string(res[2]))
+	}
+	assert.Equal(t, []string{"eth_getBlockByNumber/0x7", "eth_getBlockByNumber/0x3", "eth_getBlockByNumber/0x5"}, n.requests)
+
+	n.dropped = "0x3"
+	_, err = c.GetBlocksByNumber(context.Background(), []uint64{7, 3, 5}, true)
+	assert.Contains(t, fmt.Sprint(err), "json RPC batch response is missing id")
+}
@@ -0,0 +1,153 @@
+package ethclient
+
+import (
+	"fmt"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	"math/big"
+	"strings"
+	"time"
+)
+
+const (
+	kindQuantity = iota + 1
+	kindTimestamp
+	kindWei
+	kindGasPrice
+)
+
+var (
+	blockKinds = map[string]int{
+		"number":          kindQuantity,
+		"difficulty":      kindQuantity,
+		"totalDifficulty": kindQuantity,
+		"size":            kindQuantity,
+		"gasLimit":        kindQuantity,
+		"gasUsed":         kindQuantity,
+		"blobGasUsed":     kindQuantity,
+		"excessBlobGas":   kindQuantity,
+		"timestamp":       kindTimestamp,
+		"baseFeePerGas":   kindGasPrice,
+	}
+
+	transactionKinds = map[string]int{
+		"blockNumber":          kindQuantity,
+		"chainId":              kindQuantity,
+		"gas":                  kindQuantity,
+		"nonce":                kindQuantity,
+		"transactionIndex":     kindQuantity,
+		"type":                 kindQuantity,
+		"value":                kindWei,
+		"gasPrice":             kindGasPrice,
+		"maxFeePerGas":         kindGasPrice,
+		"maxPriorityFeePerGas": kindGasPrice,
+		"maxFeePerBlobGas":     kindGasPrice,
+	}
+)
+
+// DecodeBlock converts block quantities to decimal strings, timestamp to RFC 3339 and fees to gwei,
+// transaction objects are decoded with DecodeTransaction
+func DecodeBlock(json []byte) ([]byte, error) {
+	return decode(json, blockKinds, func(key string, value gjson.Result) (string, error) {
+		if key != "transactions" || !value.IsArray() {
+			return value.Raw, nil
+		}
+
+		raw := []byte(`[]`)
+		var err error
+		for _, tx := range value.Array() {
+			txRaw := []byte(tx.Raw)
+			if tx.IsObject() {
+				txRaw, err = DecodeTransaction(txRaw)
+				if err != nil {
+					return "", err
+				}
+			}
+
+			raw, err = sjson.SetRawBytes(raw, "-1", txRaw)
+			if err != nil {
+				return "", err
+			}
+		}
+
+		return string(raw), nil
+	})
+}
+
+// DecodeTransaction converts transaction quantities to decimal strings, value to wei & ether and gas prices to gwei
+func DecodeTransaction(json []byte) ([]byte, error) {
+	return decode(json, transactionKinds, nil)
+}
+
+// decode rebuilds json object preserving key order, nested values are handled by custom function
+func decode(json []byte, kinds map[string]int, custom func(key string, value gjson.Result) (string, error)) ([]byte, error) {
+	var err error
+	res := []byte(`{}`)
+
+	gjson.ParseBytes(json).ForEach(func(key, value gjson.Result) bool {
+		raw := value.Raw
+		if kind, ok := kinds[key.String()]; ok && value.Type == gjson.String {
+			raw, err = decodeValue(kind, value.String())
+			if err != nil {
+				err = fmt.Errorf("unable to decode '%s' value '%s': %w", key.String(), value.String(), err)
+				return false
+			}
+		} else if custom != nil {
+			raw, err = custom(key.String(), value)
+			if err != nil {
+				return false
+			}
+		}
+
+		res, err = sjson.SetRawBytes(res, escapeKey(key.String()), []byte(raw))
+		return err == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func decodeValue(kind int, hex string) (string, error) {
+	value, err := HexToBigInt(hex)
+	if err != nil {
+		return "", err
+	}
+
+	switch kind {
+	case kindTimestamp:
+		return fmt.Sprintf(`"%s"`, time.Unix(value.Int64(), 0).UTC().Format(time.RFC3339)), nil
+	case kindWei:
+		return fmt.Sprintf(`{"wei":"%s","ether":"%s"}`, value.String(), FormatUnits(&value, 18)), nil
+	case kindGasPrice:
+		return fmt.Sprintf(`{"wei":"%s","gwei":"%s"}`, value.String(), FormatUnits(&value, 9)), nil
+	}
+
+	return fmt.Sprintf(`"%s"`, value.String()), nil
+}
+
+// FormatUnits formats integer value as decimal number with given number of decimals, e.g. wei as ether
+func FormatUnits(value *big.Int, decimals int) string {
+	sign := ""
+	abs := new(big.Int).Abs(value)
+	if value.Sign() < 0 {
+		sign = "-"
+	}
+
+	digits := abs.String()
+	if len(digits) <= decimals {
+		digits = strings.Repeat("0", decimals-len(digits)+1) + digits
+	}
+
+	whole, fraction := digits[:len(digits)-decimals], strings.TrimRight(digits[len(digits)-decimals:], "0")
+	if fraction == "" {
+		return sign + whole
+	}
+
+	return sign + whole + "." + fraction
+}
+
+func escapeKey(key string) string {
+	return strings.NewReplacer(".", `\.`, "*", `\*`, "?", `\?`, "|", `\|`, "#", `\#`).Replace(key)
+}
@@ -0,0 +1,32 @@
+package ethclient
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+	"math/big"
+	"testing"
+)
+
+func TestFormatUnits(t *testing.T) {
+	assert.Equal(t, "1", FormatUnits(big.NewInt(1000000000000000000), 18))
+	assert.Equal(t, "0.000000000000000001", FormatUnits(big.NewInt(1), 18))
+	assert.Equal(t, "1.5", FormatUnits(big.NewInt(1500000000), 9))
+	assert.Equal(t, "0", FormatUnits(big.NewInt(0), 9))
+	assert.Equal(t, "-0.25", FormatUnits(big.NewInt(-250000000), 9))
+}
+
+func TestDecodeBlock(t *testing.T) {
+	json, err := DecodeBlock([]byte(`{"number":"0x10","hash":"0xab","timestamp":"0x5f5e1000","baseFeePerGas":"0x3b9aca00",` +
+		`"transactions":[{"hash":"0xcd","value":"0xde0b6b3a7640000","gasPrice":"0x77359400","nonce":"0x2"},"0xef"]}`))
+	if assert.NoError(t, err) {
+		block := gjson.ParseBytes(json)
+		assert.Equal(t, "16", block.Get("number").String())
+		assert.Equal(t, "0xab", block.Get("hash").String())
+		assert.Equal(t, "2020-09-13T12:26:40Z", block.Get("timestamp").String())
+		assert.Equal(t, "1", block.Get("baseFeePerGas.gwei").String())
+		assert.Equal(t, "1", block.Get("transactions.0.value.ether").String())
+		assert.Equal(t, "2", block.Get("transactions.0.gasPrice.gwei").String())
+		assert.Equal(t, "2", block.Get("transactions.0.nonce").String())
+		assert.Equal(t, "0xef", block.Get("transactions.1").String())
+	}
+}
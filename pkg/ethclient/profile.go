@@ -0,0 +1,65 @@
+package ethclient
+
+import (
+	"github.com/divilla/ethproxy/config"
+	"sync/atomic"
+	"time"
+)
+
+// blockTimeWeight is weight of the last observed block interval in adaptive block time
+const blockTimeWeight = 0.2
+
+// profile applies chain profile: finality policy, block time driving latest block polling
+// and adaptive learning of block time from the poller. It's applied before the poller starts,
+// so profile fields are never written concurrently.
+func (c *EthereumHttpClient) profile(p config.Profile) {
+	c.finalityTags = p.FinalityTags
+	c.safeDistance = p.ReorgDepth
+	c.finalDistance = p.FinalDepth
+	c.adaptive = p.Adaptive
+	atomic.StoreInt64(&c.blockTime, int64(p.BlockTime))
+}
+
+// FinalityDistances returns distances from the latest block used when chain doesn't support finality tags
+func (c *EthereumHttpClient) FinalityDistances() (uint64, uint64) {
+	return c.safeDistance, c.finalDistance
+}
+
+// BlockTime returns profile block time, or block time learned by the poller in adaptive mode,
+// 0 if client has no profile
+func (c *EthereumHttpClient) BlockTime() time.Duration {
+	return time.Duration(atomic.LoadInt64(&c.blockTime))
+}
+
+// refreshInterval polls latest block 4 times per block time, not more often than config.MinBlockRefresh.
+// Client without profile polls every refreshLatest.
+func (c *EthereumHttpClient) refreshInterval() time.Duration {
+	bt := c.BlockTime()
+	if bt == 0 {
+		return c.refreshLatest
+	}
+	if bt/4 < config.MinBlockRefresh {
+		return config.MinBlockRefresh
+	}
+
+	return bt / 4
+}
+
+// observeBlockTime updates adaptive block time with average interval of blocks produced since the last head change
+func (c *EthereumHttpClient) observeBlockTime(prev, latest uint64, now time.Time) {
+	if !c.adaptive || latest <= prev {
+		return
+	}
+	if c.headChanged.IsZero() || prev == 0 {
+		c.headChanged = now
+		return
+	}
+
+	observed := float64(now.Sub(c.headChanged)) / float64(latest-prev)
+	c.headChanged = now
+	bt := float64(c.BlockTime())
+	if bt == 0 {
+		bt = observed
+	}
+	atomic.StoreInt64(&c.blockTime, int64(bt*(1-blockTimeWeight)+observed*blockTimeWeight))
+}
@@ -0,0 +1,129 @@
+package ethclient
+
+import (
+	"context"
+	"fmt"
+	"github.com/divilla/ethproxy/config"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+	"sync"
+	"testing"
+	"time"
+)
+
+// node answers eth_blockNumber with head & eth_getBlockByNumber with header of requested tag or with tagResponse
+// when it's set, requests are recorded
+type node struct {
+	head        uint64
+	tagResponse string
+	requests    []string
+	mx          sync.Mutex
+}
+
+func (n *node) Url(string) error {
+	return nil
+}
+
+func (n *node) Post(_ context.Context, body string) ([]byte, error) {
+	req := gjson.Parse(body)
+	n.mx.Lock()
+	n.requests = append(n.requests, req.Get("method").String()+"/"+req.Get("params.0").String())
+	n.mx.Unlock()
+
+	n.mx.Lock()
+	tagResponse := n.tagResponse
+	n.mx.Unlock()
+
+	result := fmt.Sprintf(`"result":"%s"`, UIntToHex(n.head))
+	if req.Get("method").String() == "eth_getBlockByNumber" {
+		result = fmt.Sprintf(`"result":{"number":"%s"}`, UIntToHex(n.head-10))
+		if tagResponse != "" {
+			result = tagResponse
+		}
+	}
+
+	return []byte(fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,%s}`, req.Get("id").Raw, result)), nil
+}
+
+func (n *node) respond(tagResponse string) {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+
+	n.tagResponse = tagResponse
+}
+
+func (n *node) recorded() []string {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+
+	return append([]string(nil), n.requests...)
+}
+
+func TestNew_Profile(t *testing.T) {
+	// profile is applied before the first poll, so finality tags aren't probed on chain without them
+	n := &node{head: 100}
+	c := New(n, echo.New().Logger, time.Hour, config.Profile{ReorgDepth: 5, FinalDepth: 50})
+	defer c.Done()
+
+	assert.Equal(t, []string{"eth_blockNumber/"}, n.recorded())
+	assert.Equal(t, uint64(100), c.LatestBlockNumber())
+	assert.Equal(t, uint64(0), c.FinalizedBlockNumber())
+	safe, final := c.FinalityDistances()
+	assert.Equal(t, uint64(5), safe)
+	assert.Equal(t, uint64(50), final)
+
+	n = &node{head: 100}
+	c = New(n, echo.New().Logger, time.Hour, config.Profile{FinalityTags: true})
+	defer c.Done()
+
+	assert.Equal(t, []string{"eth_blockNumber/", "eth_getBlockByNumber/safe", "eth_getBlockByNumber/finalized"}, n.recorded())
+	assert.Equal(t, uint64(90), c.SafeBlockNumber())
+	assert.Equal(t, uint64(90), c.FinalizedBlockNumber())
+}
+
+func TestEthereumHttpClient_Profile(t *testing.T) {
+	c := &EthereumHttpClient{refreshLatest: time.Second}
+	assert.Equal(t, time.Second, c.refreshInterval())
+
+	c.profile(config.Profile{BlockTime: 12 * time.Second, ReorgDepth: 64, FinalDepth: 128})
+	assert.Equal(t, 3*time.Second, c.refreshInterval())
+	safe, final := c.FinalityDistances()
+	assert.Equal(t, uint64(64), safe)
+	assert.Equal(t, uint64(128), final)
+
+	c.profile(config.Profile{BlockTime: 250 * time.Millisecond})
+	assert.Equal(t, config.MinBlockRefresh, c.refreshInterval())
+
+	// fixed profile ignores observed blocks
+	now := time.Now()
+	c.observeBlockTime(1, 2, now)
+	c.observeBlockTime(2, 3, now.Add(time.Hour))
+	assert.Equal(t, 250*time.Millisecond, c.BlockTime())
+}
+
+func TestEthereumHttpClient_ObserveBlockTime(t *testing.T) {
+	c := &EthereumHttpClient{}
+	c.profile(config.Profile{BlockTime: 12 * time.Second, Adaptive: true})
+
+	// the first head only starts the clock
+	now := time.Now()
+	c.observeBlockTime(0, 101, now)
+	assert.Equal(t, 12*time.Second, c.BlockTime())
+
+	// 2 blocks in 4s
+	now = now.Add(4 * time.Second)
+	c.observeBlockTime(101, 103, now)
+	assert.Equal(t, 10*time.Second, c.BlockTime())
+
+	// unchanged head isn't observed
+	c.observeBlockTime(103, 103, now.Add(time.Hour))
+	assert.Equal(t, 10*time.Second, c.BlockTime())
+
+	for i := uint64(0); i < 50; i++ {
+		now = now.Add(4 * time.Second)
+		c.observeBlockTime(103+2*i, 105+2*i, now)
+	}
+	assert.InDelta(t, float64(2*time.Second), float64(c.BlockTime()), float64(10*time.Millisecond))
+	assert.InDelta(t, float64(500*time.Millisecond), float64(c.refreshInterval()), float64(5*time.Millisecond))
+}
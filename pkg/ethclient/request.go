@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/tidwall/sjson"
+	"strings"
 )
 
 type jsonRPCRequest struct {
@@ -53,3 +54,13 @@ func (r *jsonRPCRequest) compareId(uid string) error {
 func (r *jsonRPCRequest) String() string {
 	return r.json
 }
+
+// batch joins requests into json RPC batch request
+func batch(reqs []*jsonRPCRequest) string {
+	jsons := make([]string, len(reqs))
+	for i, req := range reqs {
+		jsons[i] = req.json
+	}
+
+	return "[" + strings.Join(jsons, ",") + "]"
+}
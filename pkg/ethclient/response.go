@@ -5,10 +5,113 @@ import (
 	"errors"
 	"fmt"
 	"github.com/tidwall/gjson"
+	"sort"
+	"strings"
 )
 
 var RateLimitErr = errors.New("Rate limiting threshold exceeded, please wait before running more queries")
 
+// RPCError is error object returned by upstream in json RPC response
+type RPCError struct {
+	Raw string
+}
+
+func (e *RPCError) Error() string {
+	return e.Raw
+}
+
+// Message returns error message returned by upstream
+func (e *RPCError) Message() string {
+	return gjson.Get(e.Raw, "message").String()
+}
+
+// Code returns error code returned by upstream
+func (e *RPCError) Code() int64 {
+	return gjson.Get(e.Raw, "code").Int()
+}
+
+// Data returns error data returned by upstream, i.e. revert data of failed call, empty if it's missing
+func (e *RPCError) Data() string {
+	return gjson.Get(e.Raw, "data").String()
+}
+
+// IsMethodNotFound returns true if upstream doesn't support requested method, errors about missing blocks
+// or transactions like 'block does not exist' aren't matched
+func IsMethodNotFound(err error) bool {
+	var rpcErr *RPCError
+	if !errors.As(err, &rpcErr) {
+		return false
+	}
+	if rpcErr.Code() == -32601 {
+		return true
+	}
+
+	msg := strings.ToLower(rpcErr.Message())
+	return strings.Contains(msg, "method") && (strings.Contains(msg, "not found") || strings.Contains(msg, "not supported") ||
+		strings.Contains(msg, "does not exist") || strings.Contains(msg, "not available"))
+}
+
+// IsInvalidParams returns true if upstream rejected request params, i.e. block tag it doesn't know
+func IsInvalidParams(err error) bool {
+	var rpcErr *RPCError
+	return errors.As(err, &rpcErr) && rpcErr.Code() == -32602
+}
+
+// Normalize returns json with sorted object keys, without whitespace and in lower case, so equal responses
+// of different upstreams compare equal regardless of formatting, address checksums & order of batch responses
+func Normalize(json []byte) string {
+	res := gjson.ParseBytes(json)
+	if !res.IsArray() {
+		return normalize(res)
+	}
+
+	var items []string
+	res.ForEach(func(_, item gjson.Result) bool {
+		items = append(items, normalize(item))
+		return true
+	})
+	sort.Strings(items)
+
+	return "[" + strings.Join(items, ",") + "]"
+}
+
+func normalize(res gjson.Result) string {
+	return strings.ToLower(res.Get(`@pretty:{"sortKeys":true}|@ugly`).String())
+}
+
+// limitErrors are fragments of upstream errors returned when query range or result size is too large
+var limitErrors = []string{
+	"query returned more than",
+	"response size",
+	"too many",
+	"limit exceeded",
+	"exceeds",
+	"block range",
+	"range is too large",
+	"query timeout",
+}
+
+// IsLimitError returns true if upstream rejected request due to its range or result size,
+// only json RPC errors are matched, so transport errors & rate limits are never mistaken for range limits
+func IsLimitError(err error) bool {
+	var rpcErr *RPCError
+	if !errors.As(err, &rpcErr) {
+		return false
+	}
+
+	msg := strings.ToLower(rpcErr.Message())
+	if strings.Contains(msg, "rate limit") {
+		return false
+	}
+	for _, fragment := range limitErrors {
+		if strings.Contains(msg, fragment) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func parseResponse(json []byte, req *jsonRPCRequest) ([]byte, error) {
 	if bytes.Compare(json, []byte(RateLimitErr.Error())) == 0 {
 		return nil, RateLimitErr
@@ -16,7 +119,7 @@ func parseResponse(json []byte, req *jsonRPCRequest) ([]byte, error) {
 
 	errorProp := gjson.GetBytes(json, "error")
 	if errorProp.Exists() {
-		return nil, fmt.Errorf("json RPC response error: %w", errors.New(errorProp.Raw))
+		return nil, fmt.Errorf("json RPC response error: %w", &RPCError{Raw: errorProp.Raw})
 	}
 
 	err := req.compareId(gjson.GetBytes(json, "id").String())
@@ -31,3 +134,41 @@ func parseResponse(json []byte, req *jsonRPCRequest) ([]byte, error) {
 
 	return []byte(result.String()), nil
 }
+
+// parseBatchResponse parses batch response, results are returned in the order of requests
+func parseBatchResponse(json []byte, reqs []*jsonRPCRequest) ([][]byte, error) {
+	if bytes.Compare(json, []byte(RateLimitErr.Error())) == 0 {
+		return nil, RateLimitErr
+	}
+
+	batch := gjson.ParseBytes(json)
+	if !batch.IsArray() {
+		errorProp := batch.Get("error")
+		if errorProp.Exists() {
+			return nil, fmt.Errorf("json RPC batch response error: %w", errors.New(errorProp.Raw))
+		}
+		return nil, fmt.Errorf("json RPC batch response is not an array: '%s'", json)
+	}
+
+	responses := make(map[string][]byte, len(reqs))
+	batch.ForEach(func(key, value gjson.Result) bool {
+		responses[value.Get("id").String()] = []byte(value.Raw)
+		return true
+	})
+
+	results := make([][]byte, len(reqs))
+	for i, req := range reqs {
+		res, ok := responses[req.uuid]
+		if !ok {
+			return nil, fmt.Errorf("json RPC batch response is missing id '%s'", req.uuid)
+		}
+
+		result, err := parseResponse(res, req)
+		if err != nil {
+			return nil, err
+		}
+		results[i] = result
+	}
+
+	return results, nil
+}
@@ -0,0 +1,81 @@
+package ethclient
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestIsMethodNotFound(t *testing.T) {
+	for raw, expected := range map[string]bool{
+		`{"code":-32601,"message":"the method eth_getBlockReceipts does not exist/is not available"}`: true,
+		`{"code":-32000,"message":"Method not found"}`:                                                true,
+		`{"code":-32000,"message":"execution reverted"}`:                                              false,
+		`{"code":-32000,"message":"block does not exist"}`:                                            false,
+		`{"code":-32000,"message":"pruned history not supported"}`:                                    false,
+		`{"code":-32000,"message":"the method eth_getBlockReceipts is not supported"}`:                true,
+	} {
+		err := fmt.Errorf("json RPC response error: %w", &RPCError{Raw: raw})
+		assert.Equal(t, expected, IsMethodNotFound(err), raw)
+	}
+
+	assert.False(t, IsMethodNotFound(errors.New("method not found")))
+}
+
+func TestIsInvalidParams(t *testing.T) {
+	assert.True(t, IsInvalidParams(fmt.Errorf("json RPC response error: %w", &RPCError{Raw: `{"code":-32602,"message":"invalid argument 0"}`})))
+	assert.False(t, IsInvalidParams(fmt.Errorf("json RPC response error: %w", &RPCError{Raw: `{"code":-32000,"message":"header not found"}`})))
+	assert.False(t, IsInvalidParams(errors.New("-32602")))
+}
+
+func TestIsLimitError(t *testing.T) {
+	for raw, expected := range map[string]bool{
+		`{"code":-32005,"message":"query returned more than 10000 results"}`: true,
+		`{"code":-32602,"message":"Log response size exceeded."}`:            true,
+		`{"code":-32000,"message":"block range is too large"}`:               true,
+		`{"code":-32005,"message":"rate limit exceeded, too many requests"}`: false,
+		`{"code":-32000,"message":"header not found"}`:                       false,
+	} {
+		err := fmt.Errorf("json RPC response error: %w", &RPCError{Raw: raw})
+		assert.Equal(t, expected, IsLimitError(err), raw)
+	}
+
+	assert.False(t, IsLimitError(errors.New("http POST request failed with: response size exceeds buffer")))
+	assert.False(t, IsLimitError(nil))
+}
+
+func TestNormalize(t *testing.T) {
+	assert.Equal(t, Normalize([]byte(`{"id":"1","result":{"to":"0xAB","from":"0xcd"}}`)),
+		Normalize([]byte(`{"result": {"from":"0xCD", "to":"0xab"}, "id":"1"}`)))
+	assert.Equal(t, Normalize([]byte(`[{"id":"1","result":"0x1"},{"id":"2","result":"0x2"}]`)),
+		Normalize([]byte(`[{"id":"2","result":"0x2"},{"id":"1","result":"0x1"}]`)))
+	assert.NotEqual(t, Normalize([]byte(`{"id":"1","result":"0x1"}`)), Normalize([]byte(`{"id":"1","result":"0x2"}`)))
+}
+
+func TestParseBatchResponse(t *testing.T) {
+	reqs := []*jsonRPCRequest{request("getBlockByNumber"), request("getBlockByNumber"), request("getBlockByNumber")}
+	res := func(i int, result string) string {
+		return fmt.Sprintf(`{"jsonrpc":"2.0","id":"%s",%s}`, reqs[i].uuid, result)
+	}
+
+	// results are in the order of requests, null result is empty
+	results, err := parseBatchResponse([]byte("["+res(2, `"result":{"number":"0x2"}`)+","+res(0, `"result":{"number":"0x0"}`)+","+res(1, `"result":null`)+"]"), reqs)
+	if assert.NoError(t, err) && assert.Len(t, results, 3) {
+		assert.JSONEq(t, `{"number":"0x0"}`, string(results[0]))
+		assert.Empty(t, results[1])
+		assert.JSONEq(t, `{"number":"0x2"}`, string(results[2]))
+	}
+
+	_, err = parseBatchResponse([]byte("["+res(2, `"result":"0x2"`)+","+res(0, `"result":"0x0"`)+"]"), reqs)
+	assert.Contains(t, fmt.Sprint(err), fmt.Sprintf("json RPC batch response is missing id '%s'", reqs[1].uuid))
+
+	_, err = parseBatchResponse([]byte("["+res(0, `"result":"0x0"`)+","+res(1, `"error":{"code":-32000,"message":"header not found"}`)+","+res(2, `"result":"0x2"`)+"]"), reqs)
+	assert.Contains(t, fmt.Sprint(err), "header not found")
+
+	_, err = parseBatchResponse([]byte(`{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"batch too large"}}`), reqs)
+	assert.Contains(t, fmt.Sprint(err), "json RPC batch response error")
+
+	_, err = parseBatchResponse([]byte(RateLimitErr.Error()), reqs)
+	assert.True(t, errors.Is(err, RateLimitErr))
+}
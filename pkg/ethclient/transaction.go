@@ -0,0 +1,142 @@
+package ethclient
+
+import (
+	"encoding/hex"
+	"fmt"
+	"github.com/divilla/ethproxy/pkg/rlp"
+	"github.com/pkg/errors"
+	"strings"
+)
+
+// MaxRawTransactionSize limits raw transaction payload, it leaves room for blob transactions in network form
+const MaxRawTransactionSize = 1 << 20
+
+type (
+	// RawTransaction is signed transaction validated by shape, Hash is computed locally
+	RawTransaction struct {
+		Hash  string
+		Type  int
+		Nonce uint64
+	}
+
+	// txLayout describes RLP fields of transaction type, signature is always in last 3 fields
+	txLayout struct {
+		fields   int
+		nonce    int
+		to       int
+		lists    []int
+		creation bool
+	}
+)
+
+var txLayouts = map[int]txLayout{
+	0: {fields: 9, nonce: 0, to: 3, creation: true},
+	1: {fields: 11, nonce: 1, to: 4, lists: []int{7}, creation: true},
+	2: {fields: 12, nonce: 1, to: 5, lists: []int{8}, creation: true},
+	3: {fields: 14, nonce: 1, to: 5, lists: []int{8, 10}},
+	4: {fields: 13, nonce: 1, to: 5, lists: []int{8, 9}},
+}
+
+// ParseRawTransaction validates RLP shape of legacy or typed signed transaction and computes its hash.
+// Blob transactions are accepted in network form, hash is computed of transaction without blobs.
+func ParseRawTransaction(raw string) (RawTransaction, error) {
+	tx := RawTransaction{}
+	if !strings.HasPrefix(raw, "0x") {
+		return tx, errors.New("raw transaction must be 0x prefixed hex")
+	}
+
+	data, err := hex.DecodeString(raw[2:])
+	if err != nil {
+		return tx, errors.Errorf("raw transaction is not valid hex: %v", err)
+	}
+	if len(data) == 0 {
+		return tx, errors.New("raw transaction is empty")
+	}
+	if len(data) > MaxRawTransactionSize {
+		return tx, errors.Errorf("raw transaction size %d exceeds %d bytes", len(data), MaxRawTransactionSize)
+	}
+
+	payload := data
+	if data[0] < 0x80 {
+		tx.Type = int(data[0])
+		payload = data[1:]
+	}
+
+	layout, ok := txLayouts[tx.Type]
+	if !ok {
+		return tx, errors.Errorf("transaction type %d is not supported", tx.Type)
+	}
+
+	v, err := rlp.Decode(payload)
+	if err != nil {
+		return tx, errors.Errorf("raw transaction is not valid RLP: %v", err)
+	}
+	if !v.IsList {
+		return tx, errors.New("raw transaction is not RLP list")
+	}
+
+	signed := data
+	if tx.Type == 3 && len(v.List) == 4 && v.List[0].IsList {
+		v = v.List[0]
+		signed = append([]byte{3}, v.Raw...)
+	}
+
+	if err = layout.validate(v); err != nil {
+		return tx, errors.Errorf("type %d transaction: %v", tx.Type, err)
+	}
+
+	if tx.Nonce, err = bytesToUInt(v.List[layout.nonce].Bytes); err != nil {
+		return tx, errors.Errorf("type %d transaction nonce: %v", tx.Type, err)
+	}
+	tx.Hash = "0x" + hex.EncodeToString(Keccak256(signed))
+
+	return tx, nil
+}
+
+func (l txLayout) validate(v rlp.Value) error {
+	if len(v.List) != l.fields {
+		return errors.Errorf("has %d fields instead of %d", len(v.List), l.fields)
+	}
+
+	for i, item := range v.List {
+		if item.IsList != l.isList(i) {
+			return errors.Errorf("field %d has invalid kind", i)
+		}
+	}
+
+	to := len(v.List[l.to].Bytes)
+	if to != 20 && !(to == 0 && l.creation) {
+		return errors.Errorf("recipient has invalid length %d", to)
+	}
+
+	for _, i := range []int{l.fields - 2, l.fields - 1} {
+		if size := len(v.List[i].Bytes); size == 0 || size > 32 {
+			return errors.New("signature is not valid")
+		}
+	}
+
+	return nil
+}
+
+func (l txLayout) isList(i int) bool {
+	for _, j := range l.lists {
+		if i == j {
+			return true
+		}
+	}
+
+	return false
+}
+
+func bytesToUInt(b []byte) (uint64, error) {
+	if len(b) > 8 {
+		return 0, fmt.Errorf("value of %d bytes overflows uint64", len(b))
+	}
+
+	var i uint64
+	for _, c := range b {
+		i = i<<8 | uint64(c)
+	}
+
+	return i, nil
+}
@@ -0,0 +1,56 @@
+package ethclient
+
+import (
+	"bytes"
+	"encoding/hex"
+	"github.com/divilla/ethproxy/pkg/rlp"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// eip155 is signed transaction from EIP-155 example
+const eip155 = "0xf86c098504a817c800825208943535353535353535353535353535353535353535880de0b6b3a76400008025a028ef61340bd939bc2195fe537567866003e1a15d3c71ff63e1590620aa636276a067cbe9d8997f761aecb703304b3800ccf555c9f3dc64214b297fb1966a3b6d83"
+
+func TestParseRawTransaction(t *testing.T) {
+	tx, err := ParseRawTransaction(eip155)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 0, tx.Type)
+		assert.Equal(t, uint64(9), tx.Nonce)
+		assert.Equal(t, "0x33469b22e9f636356c4160a87eb19df52b7412e8eac32a4a55ffe88ea8350788", tx.Hash)
+	}
+
+	to := bytes.Repeat([]byte{0x35}, 20)
+	sig := bytes.Repeat([]byte{0x01}, 32)
+	dynamic, _ := rlp.Encode(rlp.List{uint64(1), uint64(7), uint64(1), uint64(2), uint64(21000), to, uint64(0), []byte{}, rlp.List{}, uint64(1), sig, sig})
+	tx, err = ParseRawTransaction("0x02" + hex.EncodeToString(dynamic))
+	if assert.NoError(t, err) {
+		assert.Equal(t, 2, tx.Type)
+		assert.Equal(t, uint64(7), tx.Nonce)
+		assert.Equal(t, "0x"+hex.EncodeToString(Keccak256([]byte{2}, dynamic)), tx.Hash)
+	}
+
+	blob, _ := rlp.Encode(rlp.List{uint64(1), uint64(3), uint64(1), uint64(2), uint64(21000), to, uint64(0), []byte{}, rlp.List{}, uint64(1), rlp.List{sig}, uint64(0), sig, sig})
+	network, _ := rlp.Encode(rlp.List{rlp.RawValue(blob), rlp.List{}, rlp.List{}, rlp.List{}})
+	tx, err = ParseRawTransaction("0x03" + hex.EncodeToString(network))
+	if assert.NoError(t, err) {
+		assert.Equal(t, 3, tx.Type)
+		assert.Equal(t, "0x"+hex.EncodeToString(Keccak256([]byte{3}, blob)), tx.Hash)
+	}
+
+	creation, _ := rlp.Encode(rlp.List{uint64(1), uint64(7), uint64(1), uint64(2), uint64(21000), []byte{}, uint64(0), []byte{}, rlp.List{}, rlp.List{}, uint64(1), sig, sig})
+	for _, invalid := range []string{
+		"",
+		"f86c",
+		"0x",
+		"0xzz",
+		"0x05c0",
+		eip155[:len(eip155)-2],
+		eip155 + "00",
+		"0x02" + hex.EncodeToString(dynamic[:len(dynamic)-1]),
+		"0x01" + hex.EncodeToString(dynamic),
+		"0x04" + hex.EncodeToString(creation),
+	} {
+		_, err = ParseRawTransaction(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
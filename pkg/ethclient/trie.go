@@ -0,0 +1,126 @@
+package ethclient
+
+import (
+	"github.com/divilla/ethproxy/pkg/rlp"
+	"sort"
+)
+
+type trieEntry struct {
+	key   []byte
+	value []byte
+}
+
+// TrieRoot computes root hash of Merkle Patricia trie holding given keys & values, keys must be unique
+func TrieRoot(keys, values [][]byte) ([]byte, error) {
+	entries := make([]trieEntry, len(keys))
+	for i, k := range keys {
+		entries[i] = trieEntry{key: nibbles(k), value: values[i]}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return string(entries[i].key) < string(entries[j].key)
+	})
+
+	if len(entries) == 0 {
+		return Keccak256([]byte{0x80}), nil
+	}
+
+	node, err := trieNode(entries, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return Keccak256(node), nil
+}
+
+// trieNode returns RLP encoding of node holding sorted entries, keys are compared from depth nibble
+func trieNode(entries []trieEntry, depth int) ([]byte, error) {
+	if len(entries) == 1 {
+		return rlp.Encode(rlp.List{hexPrefix(entries[0].key[depth:], true), entries[0].value})
+	}
+
+	prefix := commonPrefix(entries, depth)
+	if prefix > 0 {
+		child, err := trieNode(entries, depth+prefix)
+		if err != nil {
+			return nil, err
+		}
+		return rlp.Encode(rlp.List{hexPrefix(entries[0].key[depth:depth+prefix], false), reference(child)})
+	}
+
+	branch := make(rlp.List, 17)
+	for i := range branch {
+		branch[i] = []byte{}
+	}
+	for len(entries) > 0 {
+		if len(entries[0].key) == depth {
+			branch[16] = entries[0].value
+			entries = entries[1:]
+			continue
+		}
+
+		nibble := entries[0].key[depth]
+		end := 1
+		for end < len(entries) && entries[end].key[depth] == nibble {
+			end++
+		}
+
+		child, err := trieNode(entries[:end], depth+1)
+		if err != nil {
+			return nil, err
+		}
+		branch[nibble] = reference(child)
+		entries = entries[end:]
+	}
+
+	return rlp.Encode(branch)
+}
+
+// reference embeds child nodes shorter than 32 bytes, longer nodes are referenced by hash
+func reference(node []byte) interface{} {
+	if len(node) < 32 {
+		return rlp.RawValue(node)
+	}
+
+	return Keccak256(node)
+}
+
+func commonPrefix(entries []trieEntry, depth int) int {
+	first, last := entries[0].key[depth:], entries[len(entries)-1].key[depth:]
+	n := 0
+	for n < len(first) && n < len(last) && first[n] == last[n] {
+		n++
+	}
+
+	return n
+}
+
+// hexPrefix encodes nibbles with flag of odd length & leaf node
+func hexPrefix(nibbles []byte, leaf bool) []byte {
+	flag := byte(0)
+	if leaf {
+		flag = 2
+	}
+
+	var out []byte
+	if len(nibbles)%2 == 1 {
+		out = append(out, (flag+1)<<4|nibbles[0])
+		nibbles = nibbles[1:]
+	} else {
+		out = append(out, flag<<4)
+	}
+	for i := 0; i < len(nibbles); i += 2 {
+		out = append(out, nibbles[i]<<4|nibbles[i+1])
+	}
+
+	return out
+}
+
+func nibbles(key []byte) []byte {
+	n := make([]byte, len(key)*2)
+	for i, b := range key {
+		n[i*2] = b >> 4
+		n[i*2+1] = b & 0x0f
+	}
+
+	return n
+}
@@ -0,0 +1,332 @@
+package ethclient
+
+import (
+	"encoding/hex"
+	"fmt"
+	"github.com/divilla/ethproxy/pkg/rlp"
+	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
+	"math/big"
+	"strings"
+)
+
+const (
+	fieldHash = iota
+	fieldAddress
+	fieldBloom
+	fieldQuantity
+	fieldBytes
+	fieldNonce
+	fieldList
+)
+
+type (
+	// Verification is result of block verification that didn't fail
+	Verification int
+
+	// VerificationError is returned when block response doesn't match its hash or transactions root
+	VerificationError struct {
+		Block  string
+		Reason string
+	}
+
+	// field is json field of block or transaction in the order of its RLP encoding
+	field struct {
+		name string
+		kind int
+	}
+)
+
+const (
+	// Verified block matches its hash and, when it holds full transactions, its transactions root
+	Verified Verification = iota
+	// Unverifiable block can't be fully verified, it's pending block without hash, or block matching its hash
+	// with transactions of unsupported types, so its transactions root can't be recomputed
+	Unverifiable
+)
+
+func (v Verification) String() string {
+	if v == Unverifiable {
+		return "unverifiable"
+	}
+
+	return "verified"
+}
+
+func (e *VerificationError) Error() string {
+	return fmt.Sprintf("block '%s' failed verification: %s", e.Block, e.Reason)
+}
+
+// headerFields are fields of Frontier header, later forks append headerForkFields in order
+var headerFields = []field{
+	{"parentHash", fieldHash},
+	{"sha3Uncles", fieldHash},
+	{"miner", fieldAddress},
+	{"stateRoot", fieldHash},
+	{"transactionsRoot", fieldHash},
+	{"receiptsRoot", fieldHash},
+	{"logsBloom", fieldBloom},
+	{"difficulty", fieldQuantity},
+	{"number", fieldQuantity},
+	{"gasLimit", fieldQuantity},
+	{"gasUsed", fieldQuantity},
+	{"timestamp", fieldQuantity},
+	{"extraData", fieldBytes},
+	{"mixHash", fieldHash},
+	{"nonce", fieldNonce},
+}
+
+var headerForkFields = []field{
+	{"baseFeePerGas", fieldQuantity},     // London
+	{"withdrawalsRoot", fieldHash},       // Shanghai
+	{"blobGasUsed", fieldQuantity},       // Cancun
+	{"excessBlobGas", fieldQuantity},     // Cancun
+	{"parentBeaconBlockRoot", fieldHash}, // Cancun
+	{"requestsHash", fieldHash},          // Prague
+}
+
+// txFields are RLP fields of transaction types without signature, typed transactions are prefixed with type byte
+var txFields = map[int64][]field{
+	0: {{"nonce", fieldQuantity}, {"gasPrice", fieldQuantity}, {"gas", fieldQuantity}, {"to", fieldBytes},
+		{"value", fieldQuantity}, {"input", fieldBytes}},
+	1: {{"chainId", fieldQuantity}, {"nonce", fieldQuantity}, {"gasPrice", fieldQuantity}, {"gas", fieldQuantity},
+		{"to", fieldBytes}, {"value", fieldQuantity}, {"input", fieldBytes}, {"accessList", fieldList}},
+	2: {{"chainId", fieldQuantity}, {"nonce", fieldQuantity}, {"maxPriorityFeePerGas", fieldQuantity},
+		{"maxFeePerGas", fieldQuantity}, {"gas", fieldQuantity}, {"to", fieldBytes}, {"value", fieldQuantity},
+		{"input", fieldBytes}, {"accessList", fieldList}},
+	3: {{"chainId", fieldQuantity}, {"nonce", fieldQuantity}, {"maxPriorityFeePerGas", fieldQuantity},
+		{"maxFeePerGas", fieldQuantity}, {"gas", fieldQuantity}, {"to", fieldBytes}, {"value", fieldQuantity},
+		{"input", fieldBytes}, {"accessList", fieldList}, {"maxFeePerBlobGas", fieldQuantity},
+		{"blobVersionedHashes", fieldList}},
+	4: {{"chainId", fieldQuantity}, {"nonce", fieldQuantity}, {"maxPriorityFeePerGas", fieldQuantity},
+		{"maxFeePerGas", fieldQuantity}, {"gas", fieldQuantity}, {"to", fieldBytes}, {"value", fieldQuantity},
+		{"input", fieldBytes}, {"accessList", fieldList}, {"authorizationList", fieldList}},
+}
+
+// ErrUnsupportedTxType is returned for transaction types without known encoding, i.e. L2 deposit transactions
+var ErrUnsupportedTxType = errors.New("transaction type is not supported")
+
+var fixedSizes = map[int]int{
+	fieldHash:    32,
+	fieldAddress: 20,
+	fieldBloom:   256,
+	fieldNonce:   8,
+}
+
+// VerifyBlock recomputes block hash from header fields and transactions root from transaction objects.
+// Transactions root is verified only if block holds full transactions, blocks with transaction hashes are
+// verified by hash. Pending blocks without hash & blocks with transactions of unsupported types, like L2 deposits,
+// are Unverifiable.
+func VerifyBlock(block []byte) (Verification, error) {
+	b := gjson.ParseBytes(block)
+	hash := b.Get("hash").String()
+	if hash == "" {
+		return Unverifiable, nil
+	}
+
+	fail := func(format string, args ...interface{}) error {
+		return &VerificationError{Block: b.Get("number").String(), Reason: fmt.Sprintf(format, args...)}
+	}
+
+	computed, err := BlockHash(b)
+	if err != nil {
+		return 0, fail("%v", err)
+	}
+	if !strings.EqualFold(computed, hash) {
+		return 0, fail("computed hash '%s' doesn't match '%s'", computed, hash)
+	}
+
+	txs := b.Get("transactions").Array()
+	if len(txs) > 0 && !txs[0].IsObject() {
+		return Verified, nil
+	}
+
+	root, err := TransactionsRoot(txs)
+	if errors.Is(err, ErrUnsupportedTxType) {
+		return Unverifiable, nil
+	}
+	if err != nil {
+		return 0, fail("%v", err)
+	}
+	if !strings.EqualFold(root, b.Get("transactionsRoot").String()) {
+		return 0, fail("computed transactions root '%s' doesn't match '%s'", root, b.Get("transactionsRoot").String())
+	}
+
+	return Verified, nil
+}
+
+// BlockHash computes block hash as Keccak-256 of RLP encoded header, fork fields are appended while present
+func BlockHash(block gjson.Result) (string, error) {
+	header, err := encodeFields(block, headerFields)
+	if err != nil {
+		return "", err
+	}
+
+	missing := ""
+	for _, f := range headerForkFields {
+		v := block.Get(f.name)
+		if !v.Exists() || v.Type == gjson.Null {
+			missing = f.name
+			continue
+		}
+		if missing != "" {
+			return "", errors.Errorf("header field '%s' is present without '%s'", f.name, missing)
+		}
+
+		item, err := encodeField(v, f)
+		if err != nil {
+			return "", err
+		}
+		header = append(header, item)
+	}
+
+	enc, err := rlp.Encode(header)
+	if err != nil {
+		return "", err
+	}
+
+	return "0x" + hex.EncodeToString(Keccak256(enc)), nil
+}
+
+// TransactionsRoot computes root of trie keyed by RLP encoded transaction index with encoded transactions as values
+func TransactionsRoot(txs []gjson.Result) (string, error) {
+	keys := make([][]byte, len(txs))
+	values := make([][]byte, len(txs))
+	for i, tx := range txs {
+		key, err := rlp.Encode(uint64(i))
+		if err != nil {
+			return "", err
+		}
+
+		value, err := EncodeTransaction(tx)
+		if err != nil {
+			return "", errors.Wrapf(err, "transaction %d", i)
+		}
+
+		keys[i] = key
+		values[i] = value
+	}
+
+	root, err := TrieRoot(keys, values)
+	if err != nil {
+		return "", err
+	}
+
+	return "0x" + hex.EncodeToString(root), nil
+}
+
+// EncodeTransaction returns consensus encoding of signed transaction object, typed transactions are prefixed with type
+func EncodeTransaction(tx gjson.Result) ([]byte, error) {
+	txType := int64(0)
+	if t := tx.Get("type").String(); t != "" {
+		i, err := HexToUInt(t)
+		if err != nil {
+			return nil, errors.Errorf("transaction type '%s' is not valid hex", t)
+		}
+		txType = int64(i)
+	}
+
+	fields, ok := txFields[txType]
+	if !ok {
+		return nil, errors.Wrapf(ErrUnsupportedTxType, "transaction type '%d'", txType)
+	}
+
+	list, err := encodeFields(tx, fields)
+	if err != nil {
+		return nil, err
+	}
+
+	v := "v"
+	if txType > 0 && tx.Get("yParity").Exists() {
+		v = "yParity"
+	}
+	signature, err := encodeFields(tx, []field{{v, fieldQuantity}, {"r", fieldQuantity}, {"s", fieldQuantity}})
+	if err != nil {
+		return nil, err
+	}
+
+	enc, err := rlp.Encode(append(list, signature...))
+	if err != nil || txType == 0 {
+		return enc, err
+	}
+
+	return append([]byte{byte(txType)}, enc...), nil
+}
+
+func encodeFields(obj gjson.Result, fields []field) (rlp.List, error) {
+	list := make(rlp.List, len(fields))
+	for i, f := range fields {
+		item, err := encodeField(obj.Get(f.name), f)
+		if err != nil {
+			return nil, err
+		}
+		list[i] = item
+	}
+
+	return list, nil
+}
+
+func encodeField(v gjson.Result, f field) (interface{}, error) {
+	switch f.kind {
+	case fieldQuantity:
+		i, ok := new(big.Int).SetString(strings.TrimPrefix(v.String(), "0x"), 16)
+		if !ok {
+			return nil, errors.Errorf("field '%s' value '%s' is not valid hex quantity", f.name, v.String())
+		}
+		return i, nil
+	case fieldList:
+		return encodeList(v, f.name)
+	}
+
+	if v.Type == gjson.Null && f.kind == fieldBytes {
+		return []byte{}, nil
+	}
+
+	b, err := hex.DecodeString(strings.TrimPrefix(v.String(), "0x"))
+	if err != nil || !v.Exists() {
+		return nil, errors.Errorf("field '%s' value '%s' is not valid hex", f.name, v.String())
+	}
+	if size, ok := fixedSizes[f.kind]; ok && len(b) != size {
+		return nil, errors.Errorf("field '%s' has %d bytes instead of %d", f.name, len(b), size)
+	}
+
+	return b, nil
+}
+
+// encodeList encodes access list, authorization list & blob hashes
+func encodeList(v gjson.Result, name string) (rlp.List, error) {
+	list := rlp.List{}
+	for _, item := range v.Array() {
+		switch name {
+		case "accessList":
+			address, err := encodeField(item.Get("address"), field{"address", fieldAddress})
+			if err != nil {
+				return nil, err
+			}
+			keys := rlp.List{}
+			for _, k := range item.Get("storageKeys").Array() {
+				key, err := encodeField(k, field{"storageKeys", fieldHash})
+				if err != nil {
+					return nil, err
+				}
+				keys = append(keys, key)
+			}
+			list = append(list, rlp.List{address, keys})
+		case "authorizationList":
+			auth, err := encodeFields(item, []field{{"chainId", fieldQuantity}, {"address", fieldAddress},
+				{"nonce", fieldQuantity}, {"yParity", fieldQuantity}, {"r", fieldQuantity}, {"s", fieldQuantity}})
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, auth)
+		default:
+			h, err := encodeField(item, field{name, fieldHash})
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, h)
+		}
+	}
+
+	return list, nil
+}
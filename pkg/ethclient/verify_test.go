@@ -0,0 +1,141 @@
+package ethclient
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"github.com/divilla/ethproxy/pkg/rlp"
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	"strings"
+	"testing"
+)
+
+func TestTrieRoot(t *testing.T) {
+	root, err := TrieRoot(nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421", hex.EncodeToString(root))
+
+	root, err = TrieRoot(
+		[][]byte{[]byte("doe"), []byte("dog"), []byte("dogglesworth")},
+		[][]byte{[]byte("reindeer"), []byte("puppy"), []byte("cat")},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, "8aad789dff2f538bca5d8ea56e8abe10f4c7ba3a5dea95fea4cd6e7c3a1168d3", hex.EncodeToString(root))
+}
+
+var block1 = `{"parentHash":"0xd4e56740f876aef8c010b86a40d5f56745a118d0906a34e69aec8c0db1cb8fa3",
+"sha3Uncles":"0x1dcc4de8dec75d7aab85b567b6ccd41ad312451b948a7413f0a142fd40d49347",
+"miner":"0x05a56e2d52c817161883f50c441c3228cfe54d9f",
+"stateRoot":"0xd67e4d450343046425ae4271474353857ab860dbc0a1dde64b41b5cd3a532bf3",
+"transactionsRoot":"0x56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421",
+"receiptsRoot":"0x56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421",
+"logsBloom":"0x` + strings.Repeat("00", 256) + `",
+"difficulty":"0x3ff800000","number":"0x1","gasLimit":"0x1388","gasUsed":"0x0","timestamp":"0x55ba4224",
+"extraData":"0x476574682f76312e302e302f6c696e75782f676f312e342e32",
+"mixHash":"0x969b900de27b6ac6a67742365dd65f55a0526c41fd18e1b16f1a1215c2e66f59","nonce":"0x539bd4979fef1ec4",
+"hash":"0x88e96d4537bea4d9c05d12549907b32561d3bf31f45aae734cdc119f13406cb6","transactions":[]}`
+
+func TestVerifyBlock(t *testing.T) {
+	v, err := VerifyBlock([]byte(block1))
+	assert.NoError(t, err)
+	assert.Equal(t, Verified, v)
+
+	// pending block has no hash
+	v, err = VerifyBlock([]byte(`{"hash":null,"number":null}`))
+	assert.NoError(t, err)
+	assert.Equal(t, Unverifiable, v)
+
+	tampered, _ := sjson.Set(block1, "stateRoot", "0x"+strings.Repeat("11", 32))
+	var verr *VerificationError
+	_, err = VerifyBlock([]byte(tampered))
+	assert.True(t, errors.As(err, &verr))
+
+	// fork fields must be appended in order
+	shanghai, _ := sjson.Set(block1, "withdrawalsRoot", "0x"+strings.Repeat("11", 32))
+	_, err = VerifyBlock([]byte(shanghai))
+	assert.ErrorContains(t, err, "'withdrawalsRoot' is present without 'baseFeePerGas'")
+
+	// transactions must match transactions root
+	withTx, _ := sjson.SetRaw(block1, "transactions", `[`+eip155Tx+`]`)
+	_, err = VerifyBlock([]byte(withTx))
+	assert.ErrorContains(t, err, "transactions root")
+	hashes, _ := sjson.SetRaw(block1, "transactions", `["0x33469b22e9f636356c4160a87eb19df52b7412e8eac32a4a55ffe88ea8350788"]`)
+	v, err = VerifyBlock([]byte(hashes))
+	assert.NoError(t, err)
+	assert.Equal(t, Verified, v)
+
+	// transactions root of block with unsupported transaction type can't be verified
+	deposit, _ := sjson.SetRaw(block1, "transactions", `[`+eip155Tx+`,{"type":"0x7e","hash":"0x01"}]`)
+	v, err = VerifyBlock([]byte(deposit))
+	assert.NoError(t, err)
+	assert.Equal(t, Unverifiable, v)
+}
+
+func TestVerifyBlock_Transactions(t *testing.T) {
+	to := bytes.Repeat([]byte{0x35}, 20)
+	key := bytes.Repeat([]byte{0x02}, 32)
+	r, s := bytes.Repeat([]byte{0x11}, 32), bytes.Repeat([]byte{0x22}, 32)
+	accessList := rlp.List{rlp.List{to, rlp.List{key}}}
+	jsonAccessList := `[{"address":"0x` + hex.EncodeToString(to) + `","storageKeys":["0x` + hex.EncodeToString(key) + `"]}]`
+	common := `"chainId":"0x1","nonce":"0x7","maxPriorityFeePerGas":"0x3b9aca00","maxFeePerGas":"0x77359400","gas":"0x5208",
+"to":"0x` + hex.EncodeToString(to) + `","value":"0xde0b6b3a7640000","input":"0xa9059cbb","accessList":` + jsonAccessList + `,
+"yParity":"0x1","v":"0x1","r":"0x` + hex.EncodeToString(r) + `","s":"0x` + hex.EncodeToString(s) + `"`
+
+	// typed transactions are encoded independently in the order of EIP-1559 & EIP-4844 payloads,
+	// their hashes must match hashes of transaction objects encoded by EncodeTransaction
+	dynamic, err := rlp.Encode(rlp.List{uint64(1), uint64(7), uint64(1000000000), uint64(2000000000), uint64(21000), to,
+		uint64(1000000000000000000), []byte{0xa9, 0x05, 0x9c, 0xbb}, accessList, uint64(1), r, s})
+	assert.NoError(t, err)
+	blob, err := rlp.Encode(rlp.List{uint64(1), uint64(7), uint64(1000000000), uint64(2000000000), uint64(21000), to,
+		uint64(1000000000000000000), []byte{0xa9, 0x05, 0x9c, 0xbb}, accessList, uint64(3), rlp.List{key}, uint64(1), r, s})
+	assert.NoError(t, err)
+
+	txs := []string{
+		eip155Tx,
+		`{"type":"0x2",` + common + `}`,
+		`{"type":"0x3",` + common + `,"maxFeePerBlobGas":"0x3","blobVersionedHashes":["0x` + hex.EncodeToString(key) + `"]}`,
+	}
+	expected := []string{
+		"33469b22e9f636356c4160a87eb19df52b7412e8eac32a4a55ffe88ea8350788",
+		hex.EncodeToString(Keccak256([]byte{2}, dynamic)),
+		hex.EncodeToString(Keccak256([]byte{3}, blob)),
+	}
+	for i, tx := range txs {
+		enc, err := EncodeTransaction(gjson.Parse(tx))
+		if assert.NoError(t, err, i) {
+			assert.Equal(t, expected[i], hex.EncodeToString(Keccak256(enc)), i)
+		}
+	}
+
+	// block is verified with recomputed transactions root & hash
+	block, _ := sjson.SetRaw(block1, "transactions", "["+strings.Join(txs, ",")+"]")
+	root, err := TransactionsRoot(gjson.Get(block, "transactions").Array())
+	assert.NoError(t, err)
+	block, _ = sjson.Set(block, "transactionsRoot", root)
+	hash, err := BlockHash(gjson.Parse(block))
+	assert.NoError(t, err)
+	block, _ = sjson.Set(block, "hash", hash)
+
+	v, err := VerifyBlock([]byte(block))
+	assert.NoError(t, err)
+	assert.Equal(t, Verified, v)
+
+	reordered, _ := sjson.SetRaw(block, "transactions", "["+txs[1]+","+txs[0]+","+txs[2]+"]")
+	_, err = VerifyBlock([]byte(reordered))
+	assert.ErrorContains(t, err, "transactions root")
+}
+
+const eip155Tx = `{"nonce":"0x9","gasPrice":"0x4a817c800","gas":"0x5208","to":"0x3535353535353535353535353535353535353535",
+"value":"0xde0b6b3a7640000","input":"0x","v":"0x25","r":"0x28ef61340bd939bc2195fe537567866003e1a15d3c71ff63e1590620aa636276",
+"s":"0x67cbe9d8997f761aecb703304b3800ccf555c9f3dc64214b297fb1966a3b6d83"}`
+
+func TestEncodeTransaction(t *testing.T) {
+	enc, err := EncodeTransaction(gjson.Parse(eip155Tx))
+	assert.NoError(t, err)
+	assert.Equal(t, "33469b22e9f636356c4160a87eb19df52b7412e8eac32a4a55ffe88ea8350788", hex.EncodeToString(Keccak256(enc)))
+
+	_, err = EncodeTransaction(gjson.Parse(`{"type":"0x7e"}`))
+	assert.True(t, errors.Is(err, ErrUnsupportedTxType))
+}
@@ -2,14 +2,22 @@ package jsonclient
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"github.com/asaskevich/govalidator"
 	"github.com/divilla/ethproxy/config"
 	"github.com/divilla/ethproxy/interfaces"
+	"github.com/divilla/ethproxy/pkg/logging"
+	"github.com/divilla/ethproxy/pkg/tracing"
 	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"time"
 )
 
 type (
@@ -33,16 +41,22 @@ func (c *JsonHttpClient) Url(url string) error {
 	return nil
 }
 
-func (c *JsonHttpClient) Post(request string) ([]byte, error) {
-	var resp *http.Response
-	var err error
+// Post sends request, failed requests are retried config.FetchRetries times. Attempts are logged
+// by request logger from ctx, request cancellation isn't passed to upstream since responses are shared by coalesced calls.
+// Every attempt is traced as client span and its trace context is passed to upstream in traceparent header.
+func (c *JsonHttpClient) Post(ctx context.Context, request string) ([]byte, error) {
 	var body []byte
+	var err error
 
+	logger := logging.FromContext(ctx, c.logger)
 	for i := 0; i < config.FetchRetries; i++ {
-		resp, err = http.Post(c.url, "application/json", bytes.NewBuffer([]byte(request)))
+		start := time.Now()
+		var status int
+		body, status, err = c.attempt(ctx, request, i+1)
 		if err != nil {
-			c.logger.Errorf("unable to fetch '%s', with body '%s', retry '%d/%d', with error: '%e'", c.url, request, i+1, config.FetchRetries, err)
+			logger.Errorf("unable to fetch '%s', with body '%s', retry '%d/%d', with error: %v", c.url, request, i+1, config.FetchRetries, err)
 		} else {
+			logger.Debugf("upstream '%s' responded with status %d in %s to '%s'", c.url, status, time.Since(start), request)
 			break
 		}
 	}
@@ -50,17 +64,54 @@ func (c *JsonHttpClient) Post(request string) ([]byte, error) {
 		return nil, fmt.Errorf("http POST request to '%s', with body '%s', with '%d' retries, failed with: %w", c.url, request, config.FetchRetries, err)
 	}
 
+	return body, nil
+}
+
+// attempt posts request once and reads response body
+func (c *JsonHttpClient) attempt(ctx context.Context, request string, attempt int) ([]byte, int, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "jsonclient.Post",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPMethodKey.String(http.MethodPost),
+			semconv.HTTPURLKey.String(c.url),
+			attribute.Int("upstream.attempt", attempt),
+			attribute.String("rpc.method", method(request)),
+		))
+
+	req, err := http.NewRequest(http.MethodPost, c.url, bytes.NewBufferString(request))
+	if err != nil {
+		tracing.End(span, err)
+		return nil, 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	tracing.Inject(ctx, req.Header)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		tracing.End(span, err)
+		return nil, 0, err
+	}
+
 	defer func(Body io.ReadCloser) {
 		err = Body.Close()
 		if err != nil {
 			panic(err)
 		}
 	}(resp.Body)
+	span.SetAttributes(semconv.HTTPStatusCodeKey.Int(resp.StatusCode))
 
-	body, err = ioutil.ReadAll(resp.Body)
-	if err != nil {
-		panic(err)
+	body, err := ioutil.ReadAll(resp.Body)
+	tracing.End(span, err)
+
+	return body, resp.StatusCode, err
+}
+
+// method returns json RPC method of request, batch requests are traced as 'batch'
+func method(request string) string {
+	req := gjson.Parse(request)
+	if req.IsArray() {
+		return "batch"
 	}
 
-	return body, nil
+	return req.Get("method").String()
 }
@@ -0,0 +1,50 @@
+package jsonclient
+
+import (
+	"context"
+	"github.com/divilla/ethproxy/pkg/tracing"
+	"github.com/labstack/gommon/log"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPostTraced(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(sdktrace.NewTracerProvider())
+	_, err := tracing.Setup(tracing.Config{})
+	assert.NoError(t, err)
+
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`))
+	}))
+	defer server.Close()
+
+	c := New(log.New("test"))
+	assert.NoError(t, c.Url(server.URL))
+
+	ctx, parent := tracing.Start(context.Background(), "parent")
+	res, err := c.Post(ctx, `{"jsonrpc":"2.0","id":1,"method":"eth_chainId","params":[]}`)
+	parent.End()
+	assert.NoError(t, err)
+	assert.Equal(t, `{"jsonrpc":"2.0","id":1,"result":"0x1"}`, string(res))
+
+	spans := recorder.Ended()
+	if assert.Len(t, spans, 2) {
+		post := spans[0]
+		assert.Equal(t, "jsonclient.Post", post.Name())
+		assert.Equal(t, parent.SpanContext().SpanID(), post.Parent().SpanID())
+		assert.Contains(t, post.Attributes(), attribute.String("rpc.method", "eth_chainId"))
+		assert.Contains(t, post.Attributes(), attribute.Int("upstream.attempt", 1))
+		assert.Contains(t, post.Attributes(), attribute.Int("http.status_code", 200))
+		assert.Equal(t, "00-"+post.SpanContext().TraceID().String()+"-"+post.SpanContext().SpanID().String()+"-01", traceparent)
+	}
+}
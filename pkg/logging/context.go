@@ -0,0 +1,78 @@
+package logging
+
+import (
+	"context"
+	"github.com/divilla/ethproxy/interfaces"
+	"sync/atomic"
+)
+
+// Cache statuses reported by access log, partial requests had both cache hits & misses
+const (
+	CacheHit     = "hit"
+	CacheMiss    = "miss"
+	CachePartial = "partial"
+)
+
+type (
+	contextKey int
+
+	// Access collects request details reported by access log
+	Access struct {
+		hits   int32
+		misses int32
+	}
+)
+
+const (
+	loggerKey contextKey = iota
+	accessKey
+)
+
+// NewContext returns context carrying request logger
+func NewContext(ctx context.Context, logger interfaces.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey, logger)
+}
+
+// FromContext returns request logger, or fallback if context doesn't carry one
+func FromContext(ctx context.Context, fallback interfaces.Logger) interfaces.Logger {
+	if l, ok := ctx.Value(loggerKey).(interfaces.Logger); ok {
+		return l
+	}
+
+	return fallback
+}
+
+// WithAccess returns context collecting access details of request
+func WithAccess(ctx context.Context) (context.Context, *Access) {
+	a := &Access{}
+	return context.WithValue(ctx, accessKey, a), a
+}
+
+// Cached records cache lookup of request, it's no-op outside of request
+func Cached(ctx context.Context, hit bool) {
+	a, ok := ctx.Value(accessKey).(*Access)
+	if !ok {
+		return
+	}
+
+	if hit {
+		atomic.AddInt32(&a.hits, 1)
+	} else {
+		atomic.AddInt32(&a.misses, 1)
+	}
+}
+
+// CacheStatus returns hit, miss or partial, empty if request didn't look up cache
+func (a *Access) CacheStatus() string {
+	hits, misses := atomic.LoadInt32(&a.hits), atomic.LoadInt32(&a.misses)
+	switch {
+	case hits > 0 && misses > 0:
+		return CachePartial
+	case hits > 0:
+		return CacheHit
+	case misses > 0:
+		return CacheMiss
+	}
+
+	return ""
+}
@@ -0,0 +1,285 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/labstack/gommon/log"
+	"github.com/pkg/errors"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type (
+	// Logger writes json lines with time, level, message & fields. Loggers derived by With share output & level
+	// with their parent, so level can be changed at runtime for all of them. It implements echo.Logger.
+	Logger struct {
+		core   *core
+		prefix string
+		fields []field
+	}
+
+	core struct {
+		out   io.Writer
+		level uint32
+		mx    sync.Mutex
+	}
+
+	field struct {
+		key   string
+		value interface{}
+	}
+)
+
+var levels = map[log.Lvl]string{
+	log.DEBUG: "debug",
+	log.INFO:  "info",
+	log.WARN:  "warn",
+	log.ERROR: "error",
+	log.OFF:   "off",
+}
+
+// New creates logger writing to out messages with level or higher
+func New(out io.Writer, level log.Lvl) *Logger {
+	return &Logger{
+		core: &core{
+			out:   out,
+			level: uint32(level),
+		},
+	}
+}
+
+// ParseLevel parses level name: debug, info, warn, error or off
+func ParseLevel(name string) (log.Lvl, error) {
+	for l, n := range levels {
+		if n == strings.ToLower(name) {
+			return l, nil
+		}
+	}
+
+	return 0, errors.Errorf("'%s' is not valid log level, use one of: debug, info, warn, error, off", name)
+}
+
+// LevelName returns name of level
+func LevelName(level log.Lvl) string {
+	return levels[level]
+}
+
+// With returns logger adding field to every message
+func (l *Logger) With(key string, value interface{}) *Logger {
+	fields := make([]field, len(l.fields), len(l.fields)+1)
+	copy(fields, l.fields)
+
+	return &Logger{
+		core:   l.core,
+		prefix: l.prefix,
+		fields: append(fields, field{key: key, value: value}),
+	}
+}
+
+func (l *Logger) Output() io.Writer {
+	l.core.mx.Lock()
+	defer l.core.mx.Unlock()
+
+	return l.core.out
+}
+
+func (l *Logger) SetOutput(w io.Writer) {
+	l.core.mx.Lock()
+	defer l.core.mx.Unlock()
+
+	l.core.out = w
+}
+
+func (l *Logger) Prefix() string {
+	return l.prefix
+}
+
+func (l *Logger) SetPrefix(p string) {
+	l.prefix = p
+}
+
+func (l *Logger) Level() log.Lvl {
+	return log.Lvl(atomic.LoadUint32(&l.core.level))
+}
+
+func (l *Logger) SetLevel(v log.Lvl) {
+	atomic.StoreUint32(&l.core.level, uint32(v))
+}
+
+// SetHeader is no-op, message format is fixed
+func (l *Logger) SetHeader(string) {}
+
+func (l *Logger) Print(i ...interface{}) {
+	l.write("", fmt.Sprint(i...), nil)
+}
+
+func (l *Logger) Printf(format string, args ...interface{}) {
+	l.write("", sprintf(format, args...), nil)
+}
+
+func (l *Logger) Printj(j log.JSON) {
+	l.write("", "", j)
+}
+
+func (l *Logger) Debug(i ...interface{}) {
+	l.log(log.DEBUG, fmt.Sprint(i...), nil)
+}
+
+func (l *Logger) Debugf(format string, args ...interface{}) {
+	l.log(log.DEBUG, sprintf(format, args...), nil)
+}
+
+func (l *Logger) Debugj(j log.JSON) {
+	l.log(log.DEBUG, "", j)
+}
+
+func (l *Logger) Info(i ...interface{}) {
+	l.log(log.INFO, fmt.Sprint(i...), nil)
+}
+
+func (l *Logger) Infof(format string, args ...interface{}) {
+	l.log(log.INFO, sprintf(format, args...), nil)
+}
+
+func (l *Logger) Infoj(j log.JSON) {
+	l.log(log.INFO, "", j)
+}
+
+func (l *Logger) Warn(i ...interface{}) {
+	l.log(log.WARN, fmt.Sprint(i...), nil)
+}
+
+func (l *Logger) Warnf(format string, args ...interface{}) {
+	l.log(log.WARN, sprintf(format, args...), nil)
+}
+
+func (l *Logger) Warnj(j log.JSON) {
+	l.log(log.WARN, "", j)
+}
+
+func (l *Logger) Error(i ...interface{}) {
+	l.log(log.ERROR, fmt.Sprint(i...), nil)
+}
+
+func (l *Logger) Errorf(format string, args ...interface{}) {
+	l.log(log.ERROR, sprintf(format, args...), nil)
+}
+
+func (l *Logger) Errorj(j log.JSON) {
+	l.log(log.ERROR, "", j)
+}
+
+func (l *Logger) Fatal(i ...interface{}) {
+	l.write("fatal", fmt.Sprint(i...), nil)
+	os.Exit(1)
+}
+
+func (l *Logger) Fatalj(j log.JSON) {
+	l.write("fatal", "", j)
+	os.Exit(1)
+}
+
+func (l *Logger) Fatalf(format string, args ...interface{}) {
+	l.write("fatal", sprintf(format, args...), nil)
+	os.Exit(1)
+}
+
+func (l *Logger) Panic(i ...interface{}) {
+	m := fmt.Sprint(i...)
+	l.write("panic", m, nil)
+	panic(m)
+}
+
+func (l *Logger) Panicj(j log.JSON) {
+	l.write("panic", "", j)
+	panic(j)
+}
+
+func (l *Logger) Panicf(format string, args ...interface{}) {
+	m := sprintf(format, args...)
+	l.write("panic", m, nil)
+	panic(m)
+}
+
+func (l *Logger) log(level log.Lvl, message string, j log.JSON) {
+	if level < l.Level() {
+		return
+	}
+
+	l.write(levels[level], message, j)
+}
+
+// write encodes message as single json line, fields of j are added after logger fields
+// and its 'message' field is used as message
+func (l *Logger) write(level string, message string, j log.JSON) {
+	m, ok := j["message"].(string)
+	if ok && message == "" {
+		message = m
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteString(`{"time":`)
+	encode(buf, time.Now().UTC().Format(time.RFC3339Nano))
+	if level != "" {
+		buf.WriteString(`,"level":`)
+		encode(buf, level)
+	}
+	if l.prefix != "" {
+		buf.WriteString(`,"prefix":`)
+		encode(buf, l.prefix)
+	}
+	if message != "" {
+		buf.WriteString(`,"message":`)
+		encode(buf, message)
+	}
+	for _, f := range l.fields {
+		buf.WriteByte(',')
+		encode(buf, f.key)
+		buf.WriteByte(':')
+		encode(buf, f.value)
+	}
+	keys := make([]string, 0, len(j))
+	for k := range j {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if k == "message" && ok && message == m {
+			continue
+		}
+		buf.WriteByte(',')
+		encode(buf, k)
+		buf.WriteByte(':')
+		encode(buf, j[k])
+	}
+	buf.WriteString("}\n")
+
+	l.core.mx.Lock()
+	defer l.core.mx.Unlock()
+
+	_, _ = l.core.out.Write(buf.Bytes())
+}
+
+// encode writes value as json, values that can't be encoded are written as strings
+func encode(buf *bytes.Buffer, v interface{}) {
+	if err, ok := v.(error); ok {
+		v = err.Error()
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		b, _ = json.Marshal(fmt.Sprint(v))
+	}
+	buf.Write(b)
+}
+
+// sprintf formats message like fmt.Errorf, so errors wrapped with %w are formatted as with %v
+func sprintf(format string, args ...interface{}) string {
+	return fmt.Errorf(format, args...).Error()
+}
@@ -0,0 +1,82 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/labstack/gommon/log"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func lines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var res []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		m := map[string]interface{}{}
+		assert.NoError(t, json.Unmarshal([]byte(line), &m), line)
+		res = append(res, m)
+	}
+	buf.Reset()
+
+	return res
+}
+
+func TestLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	root := New(buf, log.INFO)
+	logger := root.With("request_id", "abc")
+
+	logger.Debugf("hidden")
+	logger.Errorf("failed: %w", fmt.Errorf("wrapped: %w", errors.New("cause")))
+	logger.Infoj(log.JSON{"status": 200, "message": "access"})
+
+	l := lines(t, buf)
+	if assert.Len(t, l, 2) {
+		assert.Equal(t, "error", l[0]["level"])
+		assert.Equal(t, "failed: wrapped: cause", l[0]["message"])
+		assert.Equal(t, "abc", l[0]["request_id"])
+		assert.NotEmpty(t, l[0]["time"])
+		assert.Equal(t, "access", l[1]["message"])
+		assert.Equal(t, float64(200), l[1]["status"])
+	}
+
+	// level is shared with derived loggers
+	root.SetLevel(log.DEBUG)
+	logger.Debug("shown")
+	assert.Len(t, lines(t, buf), 1)
+	assert.Equal(t, "debug", LevelName(logger.Level()))
+
+	root.SetLevel(log.OFF)
+	logger.Error("hidden")
+	assert.Empty(t, buf.String())
+
+	level, err := ParseLevel("WARN")
+	assert.NoError(t, err)
+	assert.Equal(t, log.WARN, level)
+	_, err = ParseLevel("verbose")
+	assert.Error(t, err)
+}
+
+func TestContext(t *testing.T) {
+	fallback := New(&bytes.Buffer{}, log.INFO)
+	request := fallback.With("request_id", "abc")
+
+	assert.Equal(t, fallback, FromContext(context.Background(), fallback))
+	ctx, access := WithAccess(NewContext(context.Background(), request))
+	assert.Equal(t, request, FromContext(ctx, fallback))
+
+	assert.Equal(t, "", access.CacheStatus())
+	Cached(ctx, true)
+	assert.Equal(t, CacheHit, access.CacheStatus())
+	Cached(ctx, false)
+	assert.Equal(t, CachePartial, access.CacheStatus())
+
+	// no-op outside of request
+	Cached(context.Background(), false)
+}
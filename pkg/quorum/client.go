@@ -0,0 +1,218 @@
+package quorum
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/divilla/ethproxy/config"
+	"github.com/divilla/ethproxy/interfaces"
+	"github.com/divilla/ethproxy/pkg/ethclient"
+	"github.com/divilla/ethproxy/pkg/logging"
+	"github.com/divilla/ethproxy/pkg/upstream"
+	"github.com/tidwall/gjson"
+	"sync"
+)
+
+type (
+	// Client sends requests with quorum rule to multiple upstreams and returns response only when enough of them agree,
+	// other requests are passed to wrapped client. It implements interfaces.HttpClient.
+	Client struct {
+		client    interfaces.HttpClient
+		upstreams func() []upstream.Upstream
+		rules     map[string]config.QuorumRule
+		logger    interfaces.Logger
+		metrics   map[string]*Metrics
+		mx        sync.Mutex
+	}
+
+	// Metrics counts quorum requests of single method, Disagreed requests had at least one differing
+	// or failed upstream, Failed requests didn't reach quorum
+	Metrics struct {
+		Requests  uint64 `json:"requests"`
+		Agreed    uint64 `json:"agreed"`
+		Disagreed uint64 `json:"disagreed"`
+		Failed    uint64 `json:"failed"`
+	}
+
+	// Error is returned when upstreams didn't reach quorum, Payloads hold response or error of every upstream
+	Error struct {
+		Method   string
+		Rule     config.QuorumRule
+		Payloads map[string]string
+	}
+
+	vote struct {
+		url  string
+		json []byte
+		key  string
+		err  error
+	}
+
+	result struct {
+		json []byte
+		err  error
+	}
+)
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("upstreams didn't reach quorum of %d/%d for '%s', %d answered", e.Rule.Agree, e.Rule.Upstreams, e.Method, len(e.Payloads))
+}
+
+// New creates client, upstreams returns upstreams to ask in order of preference
+func New(client interfaces.HttpClient, upstreams func() []upstream.Upstream, rules map[string]config.QuorumRule, logger interfaces.Logger) *Client {
+	return &Client{
+		client:    client,
+		upstreams: upstreams,
+		rules:     rules,
+		logger:    logger,
+		metrics:   make(map[string]*Metrics),
+	}
+}
+
+func (c *Client) Url(url string) error {
+	return c.client.Url(url)
+}
+
+func (c *Client) Post(ctx context.Context, body string) ([]byte, error) {
+	method, rule, ok := c.rule(body)
+	if !ok {
+		return c.client.Post(ctx, body)
+	}
+
+	ups := c.upstreams()
+	if len(ups) > rule.Upstreams {
+		ups = ups[:rule.Upstreams]
+	}
+	if len(ups) < rule.Agree {
+		c.count(method, func(m *Metrics) { m.Requests++; m.Failed++ })
+		return nil, &Error{Method: method, Rule: rule, Payloads: map[string]string{}}
+	}
+
+	votes := make(chan vote, len(ups))
+	for _, u := range ups {
+		go func(u upstream.Upstream) {
+			res, err := u.Client.Post(ctx, body)
+			if err == nil && !gjson.ValidBytes(res) {
+				err = fmt.Errorf("response is not valid json: '%s'", res)
+			}
+
+			v := vote{url: u.Url, json: res, err: err}
+			if err == nil {
+				v.key = ethclient.Normalize(res)
+			}
+			votes <- v
+		}(u)
+	}
+
+	res := make(chan result, 1)
+	go c.tally(ctx, method, rule, len(ups), votes, res)
+
+	r := <-res
+	return r.json, r.err
+}
+
+// Metrics returns quorum counters by method
+func (c *Client) Metrics() map[string]Metrics {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	metrics := make(map[string]Metrics, len(c.metrics))
+	for method, m := range c.metrics {
+		metrics[method] = *m
+	}
+
+	return metrics
+}
+
+// tally passes the first response agreed by rule.Agree upstreams to res, or error as soon as quorum can't be reached.
+// It waits for all upstreams to log disagreements with their payloads.
+func (c *Client) tally(ctx context.Context, method string, rule config.QuorumRule, n int, votes <-chan vote, res chan<- result) {
+	all := make([]vote, 0, n)
+	counts := make(map[string]int)
+	best, sent := 0, false
+	for i := 0; i < n; i++ {
+		v := <-votes
+		all = append(all, v)
+		if v.err == nil {
+			counts[v.key]++
+			if counts[v.key] > best {
+				best = counts[v.key]
+			}
+			if !sent && best >= rule.Agree {
+				res <- result{json: v.json}
+				sent = true
+			}
+		}
+		if !sent && best+n-i-1 < rule.Agree {
+			res <- result{err: &Error{Method: method, Rule: rule, Payloads: payloads(all)}}
+			sent = true
+		}
+	}
+
+	agreed := best >= rule.Agree
+	disagreed := len(counts) > 1 || len(counts) == 1 && best < n
+	c.count(method, func(m *Metrics) {
+		m.Requests++
+		if agreed {
+			m.Agreed++
+		} else {
+			m.Failed++
+		}
+		if disagreed {
+			m.Disagreed++
+		}
+	})
+
+	if disagreed || !agreed {
+		p, _ := json.Marshal(payloads(all))
+		logging.FromContext(ctx, c.logger).Errorf("quorum upstreams disagree on '%s', quorum %d/%d reached: %t, payloads: %s", method, rule.Agree, n, agreed, p)
+	}
+}
+
+// rule returns the strictest rule of request methods, batch requests need agreement on all results
+func (c *Client) rule(body string) (string, config.QuorumRule, bool) {
+	var method string
+	var rule config.QuorumRule
+	check := func(m string) {
+		if r, ok := c.rules[m]; ok && r.Agree > rule.Agree {
+			method, rule = m, r
+		}
+	}
+
+	req := gjson.Parse(body)
+	if req.IsArray() {
+		req.ForEach(func(_, r gjson.Result) bool {
+			check(r.Get("method").String())
+			return true
+		})
+	} else {
+		check(req.Get("method").String())
+	}
+
+	return method, rule, rule.Agree > 0
+}
+
+func (c *Client) count(method string, f func(m *Metrics)) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	m, ok := c.metrics[method]
+	if !ok {
+		m = &Metrics{}
+		c.metrics[method] = m
+	}
+	f(m)
+}
+
+func payloads(votes []vote) map[string]string {
+	p := make(map[string]string, len(votes))
+	for _, v := range votes {
+		if v.err != nil {
+			p[v.url] = v.err.Error()
+		} else {
+			p[v.url] = string(v.json)
+		}
+	}
+
+	return p
+}
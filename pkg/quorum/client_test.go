@@ -0,0 +1,76 @@
+package quorum
+
+import (
+	"context"
+	"errors"
+	"github.com/divilla/ethproxy/config"
+	"github.com/divilla/ethproxy/pkg/upstream"
+	"github.com/labstack/gommon/log"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+type fakeClient struct {
+	res string
+	err error
+}
+
+func (c *fakeClient) Url(string) error {
+	return nil
+}
+
+func (c *fakeClient) Post(context.Context, string) ([]byte, error) {
+	return []byte(c.res), c.err
+}
+
+func TestClient_Post(t *testing.T) {
+	logger := log.New("quorum")
+	logger.SetOutput(ioutil.Discard)
+
+	ups := []upstream.Upstream{
+		{Url: "a", Client: &fakeClient{res: `{"id":"1","result":"0xAB"}`}},
+		{Url: "b", Client: &fakeClient{err: errors.New("timeout")}},
+		{Url: "c", Client: &fakeClient{res: `{"result":"0xab", "id":"1"}`}},
+		{Url: "d", Client: &fakeClient{res: `{"id":"1","result":"0xac"}`}},
+	}
+	primary := &fakeClient{res: "primary"}
+	rules := map[string]config.QuorumRule{
+		"eth_getBalance": {Upstreams: 3, Agree: 2},
+		"eth_getCode":    {Upstreams: 4, Agree: 3},
+	}
+	c := New(primary, func() []upstream.Upstream { return ups }, rules, logger)
+
+	// methods without rule go to primary
+	res, err := c.Post(context.Background(), `{"method":"eth_call"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, "primary", string(res))
+
+	// normalized results of a & c agree
+	res, err = c.Post(context.Background(), `{"method":"eth_getBalance"}`)
+	assert.NoError(t, err)
+	assert.Contains(t, string(res), `"result"`)
+
+	// batch takes the strictest rule, only a & c agree
+	_, err = c.Post(context.Background(), `[{"method":"eth_getBalance"},{"method":"eth_getCode"}]`)
+	var qerr *Error
+	assert.True(t, errors.As(err, &qerr))
+	assert.Equal(t, "eth_getCode", qerr.Method)
+
+	// too few upstreams
+	ups = ups[:1]
+	_, err = c.Post(context.Background(), `{"method":"eth_getBalance"}`)
+	assert.Error(t, err)
+
+	// disagreements are counted after all upstreams answer
+	var m map[string]Metrics
+	for i := 0; i < 100; i++ {
+		if m = c.Metrics(); m["eth_getBalance"].Requests == 2 && m["eth_getCode"].Requests == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, Metrics{Requests: 2, Agreed: 1, Disagreed: 1, Failed: 1}, m["eth_getBalance"])
+	assert.Equal(t, Metrics{Requests: 1, Disagreed: 1, Failed: 1}, m["eth_getCode"])
+}
@@ -0,0 +1,114 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+type (
+	// Limiter holds token bucket of every client, bucket refills at rate tokens per second up to burst
+	Limiter struct {
+		rate    float64
+		burst   float64
+		idle    time.Duration
+		buckets map[string]*bucket
+		now     func() time.Time
+		done    chan struct{}
+		mx      sync.Mutex
+	}
+
+	bucket struct {
+		tokens float64
+		last   time.Time
+	}
+
+	// Result is state of client bucket after taking tokens
+	Result struct {
+		Allowed    bool
+		Limit      float64
+		Remaining  float64
+		RetryAfter time.Duration
+		Reset      time.Duration
+	}
+)
+
+// New creates limiter, buckets idle for given duration are removed
+func New(rate, burst float64, idle time.Duration) *Limiter {
+	l := &Limiter{
+		rate:    rate,
+		burst:   burst,
+		idle:    idle,
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+		done:    make(chan struct{}),
+	}
+
+	go func(l *Limiter) {
+		for {
+			select {
+			case <-l.done:
+				return
+			case <-time.After(l.idle):
+				l.removeIdle()
+			}
+		}
+	}(l)
+
+	return l
+}
+
+// Take takes cost tokens from client bucket, cost larger than burst is limited to burst.
+// If there aren't enough tokens nothing is taken and RetryAfter holds time until there will be.
+func (l *Limiter) Take(client string, cost float64) Result {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	now := l.now()
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[client] = b
+	}
+
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if cost > l.burst {
+		cost = l.burst
+	}
+
+	res := Result{Limit: l.burst}
+	if b.tokens >= cost {
+		b.tokens -= cost
+		res.Allowed = true
+	} else {
+		res.RetryAfter = l.duration(cost - b.tokens)
+	}
+
+	res.Remaining = b.tokens
+	res.Reset = l.duration(l.burst - b.tokens)
+	return res
+}
+
+func (l *Limiter) Done() {
+	l.done <- struct{}{}
+	close(l.done)
+}
+
+// removeIdle removes buckets that weren't used for idle duration, they are full anyway
+func (l *Limiter) removeIdle() {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	now := l.now()
+	for client, b := range l.buckets {
+		if now.Sub(b.last) >= l.idle {
+			delete(l.buckets, client)
+		}
+	}
+}
+
+func (l *Limiter) duration(tokens float64) time.Duration {
+	return time.Duration(tokens / l.rate * float64(time.Second))
+}
@@ -0,0 +1,37 @@
+package ratelimit
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestLimiter_Take(t *testing.T) {
+	now := time.Now()
+	l := New(10, 20, time.Minute)
+	defer l.Done()
+	l.now = func() time.Time { return now }
+
+	res := l.Take("a", 15)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 5.0, res.Remaining)
+	assert.Equal(t, 1500*time.Millisecond, res.Reset)
+
+	res = l.Take("a", 10)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 5.0, res.Remaining)
+	assert.Equal(t, 500*time.Millisecond, res.RetryAfter)
+
+	// other clients have their own buckets
+	assert.True(t, l.Take("b", 20).Allowed)
+
+	now = now.Add(500 * time.Millisecond)
+	assert.True(t, l.Take("a", 10).Allowed)
+
+	// cost above burst is limited to burst
+	now = now.Add(time.Hour)
+	assert.True(t, l.Take("a", 100).Allowed)
+
+	l.removeIdle()
+	assert.Len(t, l.buckets, 1)
+}
@@ -0,0 +1,187 @@
+package rlp
+
+import (
+	"encoding/binary"
+	"github.com/pkg/errors"
+	"math/big"
+)
+
+type (
+	// Value is decoded RLP item, either byte string or list of items, Raw holds its complete encoding
+	Value struct {
+		Bytes  []byte
+		List   []Value
+		IsList bool
+		Raw    []byte
+	}
+
+	// List is RLP list, it's encoded from its items
+	List []interface{}
+
+	// RawValue is already encoded RLP item, it's written as is
+	RawValue []byte
+)
+
+// Decode decodes single RLP item, it fails if input has trailing bytes
+func Decode(data []byte) (Value, error) {
+	v, rest, err := decode(data)
+	if err != nil {
+		return Value{}, err
+	}
+	if len(rest) > 0 {
+		return Value{}, errors.Errorf("rlp input has %d trailing bytes", len(rest))
+	}
+
+	return v, nil
+}
+
+func decode(data []byte) (Value, []byte, error) {
+	v, rest, err := decodePayload(data)
+	if err != nil {
+		return Value{}, nil, err
+	}
+	v.Raw = data[:len(data)-len(rest)]
+
+	return v, rest, nil
+}
+
+func decodePayload(data []byte) (Value, []byte, error) {
+	if len(data) == 0 {
+		return Value{}, nil, errors.New("rlp input is empty")
+	}
+
+	b := data[0]
+	switch {
+	case b < 0x80:
+		return Value{Bytes: data[:1]}, data[1:], nil
+	case b < 0xb8:
+		size := int(b - 0x80)
+		if len(data) < 1+size {
+			return Value{}, nil, errors.New("rlp string is longer than input")
+		}
+		if size == 1 && data[1] < 0x80 {
+			return Value{}, nil, errors.New("rlp single byte string is not canonical")
+		}
+		return Value{Bytes: data[1 : 1+size]}, data[1+size:], nil
+	case b < 0xc0:
+		offset, size, err := longSize(data, int(b-0xb7))
+		if err != nil {
+			return Value{}, nil, err
+		}
+		return Value{Bytes: data[offset : offset+size]}, data[offset+size:], nil
+	case b < 0xf8:
+		size := int(b - 0xc0)
+		if len(data) < 1+size {
+			return Value{}, nil, errors.New("rlp list is longer than input")
+		}
+		list, err := decodeList(data[1 : 1+size])
+		return list, data[1+size:], err
+	default:
+		offset, size, err := longSize(data, int(b-0xf7))
+		if err != nil {
+			return Value{}, nil, err
+		}
+		list, err := decodeList(data[offset : offset+size])
+		return list, data[offset+size:], err
+	}
+}
+
+// longSize returns payload offset & size of long string or list
+func longSize(data []byte, sizeLen int) (int, int, error) {
+	if len(data) < 1+sizeLen {
+		return 0, 0, errors.New("rlp size is longer than input")
+	}
+	if data[1] == 0 {
+		return 0, 0, errors.New("rlp size has leading zero")
+	}
+
+	buf := make([]byte, 8)
+	copy(buf[8-sizeLen:], data[1:1+sizeLen])
+	size := binary.BigEndian.Uint64(buf)
+	if size < 56 {
+		return 0, 0, errors.New("rlp long size is not canonical")
+	}
+	if size > uint64(len(data)-1-sizeLen) {
+		return 0, 0, errors.New("rlp payload is longer than input")
+	}
+
+	return 1 + sizeLen, int(size), nil
+}
+
+func decodeList(payload []byte) (Value, error) {
+	list := Value{IsList: true, List: []Value{}}
+	for len(payload) > 0 {
+		v, rest, err := decode(payload)
+		if err != nil {
+			return Value{}, err
+		}
+		list.List = append(list.List, v)
+		payload = rest
+	}
+
+	return list, nil
+}
+
+// Encode encodes []byte, string, uint64, *big.Int, bool, RawValue and List values
+func Encode(v interface{}) ([]byte, error) {
+	switch t := v.(type) {
+	case []byte:
+		return encodeBytes(t), nil
+	case string:
+		return encodeBytes([]byte(t)), nil
+	case uint64:
+		return encodeBytes(uintBytes(t)), nil
+	case *big.Int:
+		if t.Sign() < 0 {
+			return nil, errors.New("rlp can't encode negative integer")
+		}
+		return encodeBytes(t.Bytes()), nil
+	case bool:
+		if t {
+			return []byte{0x01}, nil
+		}
+		return []byte{0x80}, nil
+	case RawValue:
+		return t, nil
+	case List:
+		var payload []byte
+		for _, item := range t {
+			enc, err := Encode(item)
+			if err != nil {
+				return nil, err
+			}
+			payload = append(payload, enc...)
+		}
+		return append(header(0xc0, len(payload)), payload...), nil
+	}
+
+	return nil, errors.Errorf("rlp can't encode type %T", v)
+}
+
+func encodeBytes(b []byte) []byte {
+	if len(b) == 1 && b[0] < 0x80 {
+		return []byte{b[0]}
+	}
+
+	return append(header(0x80, len(b)), b...)
+}
+
+func header(offset byte, size int) []byte {
+	if size < 56 {
+		return []byte{offset + byte(size)}
+	}
+
+	sizeBytes := uintBytes(uint64(size))
+	return append([]byte{offset + 55 + byte(len(sizeBytes))}, sizeBytes...)
+}
+
+// uintBytes returns big endian bytes without leading zeros, zero is empty
+func uintBytes(i uint64) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, i)
+	for len(buf) > 0 && buf[0] == 0 {
+		buf = buf[1:]
+	}
+
+	return buf
+}
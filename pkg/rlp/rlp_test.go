@@ -0,0 +1,65 @@
+package rlp
+
+import (
+	"bytes"
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"testing"
+)
+
+func TestEncode(t *testing.T) {
+	cases := map[string]interface{}{
+		"80":                 "",
+		"00":                 []byte{0},
+		"83646f67":           "dog",
+		"c88363617483646f67": List{"cat", "dog"},
+		"c0":                 List{},
+		"80 ":                uint64(0),
+		"820400":             uint64(1024),
+		"8180":               big.NewInt(128),
+		"c7c0c1c0c3c0c1c0":   List{List{}, List{List{}}, List{List{}, List{List{}}}},
+	}
+	for expected, v := range cases {
+		enc, err := Encode(v)
+		if assert.NoError(t, err) {
+			assert.Equal(t, string(bytes.TrimSpace([]byte(expected))), hex.EncodeToString(enc))
+		}
+	}
+
+	long := bytes.Repeat([]byte{'a'}, 56)
+	enc, err := Encode(long)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0xb8, 56}, enc[:2])
+}
+
+func TestDecode(t *testing.T) {
+	enc, err := Encode(List{"cat", List{uint64(1024), ""}, bytes.Repeat([]byte{'a'}, 60)})
+	assert.NoError(t, err)
+
+	v, err := Decode(enc)
+	if assert.NoError(t, err) {
+		assert.True(t, v.IsList)
+		assert.Len(t, v.List, 3)
+		assert.Equal(t, []byte("cat"), v.List[0].Bytes)
+		assert.Equal(t, []byte{0x04, 0x00}, v.List[1].List[0].Bytes)
+		assert.Len(t, v.List[2].Bytes, 60)
+	}
+
+	for _, invalid := range []string{"", "83646f", "8100", "c8836361", "83646f6700", "b80100"} {
+		data, _ := hex.DecodeString(invalid)
+		_, err = Decode(data)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestDecode_Raw(t *testing.T) {
+	inner, _ := Encode(List{uint64(1), "dog"})
+	enc, _ := Encode(List{inner, List{uint64(1), "dog"}})
+
+	v, err := Decode(enc)
+	if assert.NoError(t, err) {
+		assert.Equal(t, enc, v.Raw)
+		assert.Equal(t, inner, v.List[1].Raw)
+	}
+}
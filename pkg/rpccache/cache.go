@@ -0,0 +1,154 @@
+package rpccache
+
+import (
+	"context"
+	"github.com/divilla/ethproxy/interfaces"
+	"github.com/divilla/ethproxy/pkg/logging"
+	"github.com/divilla/ethproxy/pkg/tracing"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"sync"
+	"time"
+)
+
+type (
+	// ResponseCache caches json RPC responses by string key, e.g. method with its params
+	ResponseCache struct {
+		logger        interfaces.Logger
+		items         map[string]*item
+		capacity      int
+		removeExpired time.Duration
+		rwm           sync.RWMutex
+		done          chan struct{}
+	}
+
+	item struct {
+		key     string
+		json    []byte
+		expires int64
+	}
+)
+
+// New creates new ResponseCache
+func New(logger interfaces.Logger, capacity int, removeExpired time.Duration) *ResponseCache {
+	c := &ResponseCache{
+		items:         make(map[string]*item),
+		removeExpired: removeExpired,
+		capacity:      capacity,
+		logger:        logger,
+		done:          make(chan struct{}),
+	}
+
+	//goroutine that deletes expired items from cache
+	go func(c *ResponseCache) {
+		for {
+			select {
+			case <-c.done:
+				return
+			case <-time.After(c.removeExpired):
+				c.clear()
+			}
+		}
+	}(c)
+
+	return c
+}
+
+// Get returns cached json, lookup is traced and recorded to request access log
+func (c *ResponseCache) Get(ctx context.Context, key string) ([]byte, error) {
+	_, span := tracing.Start(ctx, "rpccache.Get", attribute.String("cache.key", key))
+	defer span.End()
+
+	json, err := c.get(key)
+	logging.Cached(ctx, err == nil)
+	span.SetAttributes(attribute.Bool("cache.hit", err == nil))
+
+	return json, err
+}
+
+// Put caches json, existing item is replaced, it's traced
+func (c *ResponseCache) Put(ctx context.Context, key string, json []byte, ttl time.Duration) error {
+	_, span := tracing.Start(ctx, "rpccache.Put", attribute.String("cache.key", key))
+	err := c.put(key, json, ttl)
+	tracing.End(span, err)
+
+	return err
+}
+
+func (c *ResponseCache) get(key string) ([]byte, error) {
+	c.rwm.RLock()
+	defer c.rwm.RUnlock()
+
+	val, ok := c.items[key]
+	if !ok {
+		return nil, errors.New("response not found")
+	}
+	if val.expires < time.Now().UnixNano() {
+		return nil, errors.Errorf("response expired: %s", time.Unix(0, val.expires))
+	}
+
+	return val.json, nil
+}
+
+func (c *ResponseCache) put(key string, json []byte, ttl time.Duration) error {
+	if ttl <= 0 {
+		return errors.Errorf("response '%s' ttl must be positive", key)
+	}
+
+	c.rwm.Lock()
+	defer c.rwm.Unlock()
+
+	if _, ok := c.items[key]; !ok {
+		c.clearOne()
+	}
+	c.items[key] = &item{
+		key:     key,
+		json:    json,
+		expires: time.Now().Add(ttl).UnixNano(),
+	}
+
+	return nil
+}
+
+func (c *ResponseCache) FreeSpace() int {
+	c.rwm.RLock()
+	defer c.rwm.RUnlock()
+
+	return c.capacity - len(c.items)
+}
+
+// Done disposes object
+func (c *ResponseCache) Done() {
+	c.done <- struct{}{}
+	close(c.done)
+}
+
+func (c *ResponseCache) clear() {
+	c.rwm.Lock()
+	defer c.rwm.Unlock()
+
+	now := time.Now().UnixNano()
+	for key, it := range c.items {
+		if it.expires < now {
+			delete(c.items, key)
+		}
+	}
+}
+
+// clearOne removes item that expires first when cache is full
+func (c *ResponseCache) clearOne() {
+	if len(c.items) < c.capacity {
+		return
+	}
+
+	var firstItem *item
+	for _, it := range c.items {
+		if firstItem == nil || it.expires < firstItem.expires {
+			firstItem = it
+		}
+	}
+
+	if firstItem != nil {
+		delete(c.items, firstItem.key)
+	}
+}
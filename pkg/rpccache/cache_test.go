@@ -0,0 +1,95 @@
+package rpccache
+
+import (
+	"context"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func newCache(t *testing.T, capacity int, removeExpired time.Duration) *ResponseCache {
+	logger := echo.New().Logger
+	logger.SetOutput(ioutil.Discard)
+	c := New(logger, capacity, removeExpired)
+	t.Cleanup(c.Done)
+
+	return c
+}
+
+func TestResponseCache_Expiry(t *testing.T) {
+	c := newCache(t, 10, time.Hour)
+	ctx := context.Background()
+
+	assert.NoError(t, c.Put(ctx, "short", []byte(`"0x1"`), 20*time.Millisecond))
+	assert.NoError(t, c.Put(ctx, "long", []byte(`"0x2"`), time.Minute))
+	json, err := c.Get(ctx, "short")
+	if assert.NoError(t, err) {
+		assert.Equal(t, `"0x1"`, string(json))
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	_, err = c.Get(ctx, "short")
+	assert.ErrorContains(t, err, "response expired")
+	_, err = c.Get(ctx, "long")
+	assert.NoError(t, err)
+
+	// replaced item gets new ttl
+	assert.NoError(t, c.Put(ctx, "short", []byte(`"0x3"`), time.Minute))
+	json, err = c.Get(ctx, "short")
+	if assert.NoError(t, err) {
+		assert.Equal(t, `"0x3"`, string(json))
+	}
+
+	_, err = c.Get(ctx, "missing")
+	assert.ErrorContains(t, err, "response not found")
+	assert.Error(t, c.Put(ctx, "zero", []byte(`"0x4"`), 0))
+	assert.Equal(t, 8, c.FreeSpace())
+}
+
+func TestResponseCache_RemoveExpired(t *testing.T) {
+	c := newCache(t, 10, 10*time.Millisecond)
+	ctx := context.Background()
+
+	assert.NoError(t, c.Put(ctx, "short", []byte(`"0x1"`), time.Millisecond))
+	assert.NoError(t, c.Put(ctx, "long", []byte(`"0x2"`), time.Minute))
+	assert.Equal(t, 8, c.FreeSpace())
+
+	assert.Eventually(t, func() bool {
+		return c.FreeSpace() == 9
+	}, time.Second, 5*time.Millisecond)
+	_, err := c.Get(ctx, "long")
+	assert.NoError(t, err)
+}
+
+func TestResponseCache_Capacity(t *testing.T) {
+	c := newCache(t, 3, time.Hour)
+	ctx := context.Background()
+
+	assert.NoError(t, c.Put(ctx, "b", []byte(`"0x2"`), 2*time.Minute))
+	assert.NoError(t, c.Put(ctx, "a", []byte(`"0x1"`), time.Minute))
+	assert.NoError(t, c.Put(ctx, "c", []byte(`"0x3"`), 3*time.Minute))
+	assert.Equal(t, 0, c.FreeSpace())
+
+	// replacing existing item doesn't evict
+	assert.NoError(t, c.Put(ctx, "c", []byte(`"0x33"`), 3*time.Minute))
+	for _, key := range []string{"a", "b", "c"} {
+		_, err := c.Get(ctx, key)
+		assert.NoError(t, err, key)
+	}
+
+	// item that expires first is evicted when cache is full
+	assert.NoError(t, c.Put(ctx, "d", []byte(`"0x4"`), 4*time.Minute))
+	assert.Equal(t, 0, c.FreeSpace())
+	_, err := c.Get(ctx, "a")
+	assert.ErrorContains(t, err, "response not found")
+	for _, key := range []string{"b", "c", "d"} {
+		_, err = c.Get(ctx, key)
+		assert.NoError(t, err, key)
+	}
+
+	assert.NoError(t, c.Put(ctx, "e", []byte(`"0x5"`), time.Hour))
+	_, err = c.Get(ctx, "b")
+	assert.ErrorContains(t, err, "response not found")
+}
@@ -0,0 +1,222 @@
+package shadow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/divilla/ethproxy/interfaces"
+	"github.com/divilla/ethproxy/pkg/ethclient"
+	"github.com/divilla/ethproxy/pkg/logging"
+	"github.com/tidwall/gjson"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxDiffPaths limits number of differing json paths in single mismatch report
+const maxDiffPaths = 20
+
+type (
+	// Client mirrors percentage of requests to candidate upstream after primary responds and diffs the responses.
+	// Mirroring never delays primary response, mirrors above concurrency limit are skipped. It implements interfaces.HttpClient.
+	Client struct {
+		client    interfaces.HttpClient
+		candidate interfaces.HttpClient
+		percent   float64
+		report    string
+		chain     string
+		logger    interfaces.Logger
+		slots     chan struct{}
+		metrics   Metrics
+		mx        sync.Mutex
+	}
+
+	// Metrics counts mirrored requests, Failed requests got error from candidate,
+	// Skipped requests weren't mirrored because primary failed or all mirror slots were busy
+	Metrics struct {
+		Mirrored   uint64 `json:"mirrored"`
+		Matched    uint64 `json:"matched"`
+		Mismatched uint64 `json:"mismatched"`
+		Failed     uint64 `json:"failed"`
+		Skipped    uint64 `json:"skipped"`
+	}
+
+	// Mismatch is single line of report file
+	Mismatch struct {
+		Time      time.Time       `json:"time"`
+		Chain     string          `json:"chain"`
+		Method    string          `json:"method"`
+		Request   json.RawMessage `json:"request"`
+		Paths     []string        `json:"paths"`
+		Primary   json.RawMessage `json:"primary"`
+		Candidate json.RawMessage `json:"candidate"`
+	}
+)
+
+// New creates client mirroring percent of requests to candidate with at most concurrency mirrors in flight,
+// mismatches are appended to report file as json lines with name of chain, report file can be shared by chains
+func New(client, candidate interfaces.HttpClient, percent float64, concurrency int, report, chain string, logger interfaces.Logger) *Client {
+	return &Client{
+		client:    client,
+		candidate: candidate,
+		percent:   percent,
+		report:    report,
+		chain:     chain,
+		logger:    logger,
+		slots:     make(chan struct{}, concurrency),
+	}
+}
+
+func (c *Client) Url(url string) error {
+	return c.client.Url(url)
+}
+
+func (c *Client) Post(ctx context.Context, body string) ([]byte, error) {
+	res, err := c.client.Post(ctx, body)
+	if rand.Float64()*100 >= c.percent {
+		return res, err
+	}
+	if err != nil || !gjson.ValidBytes(res) {
+		c.count(func(m *Metrics) { m.Skipped++ })
+		return res, err
+	}
+
+	select {
+	case c.slots <- struct{}{}:
+		go func() {
+			defer func() { <-c.slots }()
+			c.mirror(ctx, body, res)
+		}()
+	default:
+		c.count(func(m *Metrics) { m.Skipped++ })
+	}
+
+	return res, err
+}
+
+// Metrics returns mirroring counters
+func (c *Client) Metrics() Metrics {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	return c.metrics
+}
+
+func (c *Client) mirror(ctx context.Context, body string, primary []byte) {
+	logger := logging.FromContext(ctx, c.logger)
+	candidate, err := c.candidate.Post(ctx, body)
+	if err == nil && !gjson.ValidBytes(candidate) {
+		err = fmt.Errorf("response is not valid json: '%s'", candidate)
+	}
+	if err != nil {
+		c.count(func(m *Metrics) { m.Mirrored++; m.Failed++ })
+		logger.Errorf("shadow candidate failed request '%s', with error: %v", body, err)
+		return
+	}
+
+	if ethclient.Normalize(primary) == ethclient.Normalize(candidate) {
+		c.count(func(m *Metrics) { m.Mirrored++; m.Matched++ })
+		return
+	}
+	var paths []string
+	diff(gjson.ParseBytes(primary), gjson.ParseBytes(candidate), "", &paths)
+	err = c.write(Mismatch{
+		Time:      time.Now().UTC(),
+		Chain:     c.chain,
+		Method:    method(body),
+		Request:   json.RawMessage(body),
+		Paths:     paths,
+		Primary:   primary,
+		Candidate: candidate,
+	})
+	c.count(func(m *Metrics) { m.Mirrored++; m.Mismatched++ })
+	if err != nil {
+		logger.Errorf("shadow failed to write mismatch to report '%s', with error: %v", c.report, err)
+	}
+}
+
+func (c *Client) write(m Mismatch) error {
+	line, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	f, err := os.OpenFile(c.report, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(append(line, '\n')); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+func (c *Client) count(f func(m *Metrics)) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	f(&c.metrics)
+}
+
+// diff appends json paths where normalized values differ, up to maxDiffPaths
+func diff(a, b gjson.Result, path string, paths *[]string) {
+	if len(*paths) >= maxDiffPaths {
+		return
+	}
+
+	switch {
+	case a.IsObject() && b.IsObject():
+		am, bm := a.Map(), b.Map()
+		keys := make([]string, 0, len(am)+len(bm))
+		for k := range am {
+			keys = append(keys, k)
+		}
+		for k := range bm {
+			if _, ok := am[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			diff(am[k], bm[k], join(path, k), paths)
+		}
+	case a.IsArray() && b.IsArray():
+		as, bs := a.Array(), b.Array()
+		if len(as) != len(bs) {
+			*paths = append(*paths, join(path, "#"))
+			return
+		}
+		for i := range as {
+			diff(as[i], bs[i], join(path, fmt.Sprint(i)), paths)
+		}
+	default:
+		if a.Exists() != b.Exists() || strings.ToLower(a.Raw) != strings.ToLower(b.Raw) {
+			*paths = append(*paths, path)
+		}
+	}
+}
+
+func method(body string) string {
+	req := gjson.Parse(body)
+	if req.IsArray() {
+		return req.Get("0.method").String()
+	}
+
+	return req.Get("method").String()
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+
+	return path + "." + key
+}
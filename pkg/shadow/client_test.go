@@ -0,0 +1,74 @@
+package shadow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/labstack/gommon/log"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeClient struct {
+	res map[string]string
+}
+
+func (c *fakeClient) Url(string) error {
+	return nil
+}
+
+func (c *fakeClient) Post(_ context.Context, body string) ([]byte, error) {
+	res, ok := c.res[body]
+	if !ok {
+		return nil, errors.New("failed")
+	}
+
+	return []byte(res), nil
+}
+
+func TestClient_Post(t *testing.T) {
+	logger := log.New("shadow")
+	logger.SetOutput(ioutil.Discard)
+	report := filepath.Join(t.TempDir(), "report.jsonl")
+
+	primary := &fakeClient{res: map[string]string{
+		`{"method":"eth_getBalance"}`: `{"id":"1","result":"0x1"}`,
+		`{"method":"eth_getBlock"}`:   `{"id":"1","result":{"hash":"0xAB","number":"0x1","transactions":[]}}`,
+		`{"method":"eth_call"}`:       `{"id":"1","result":"0x"}`,
+	}}
+	candidate := &fakeClient{res: map[string]string{
+		`{"method":"eth_getBalance"}`: `{"result":"0x1","id":"1"}`,
+		`{"method":"eth_getBlock"}`:   `{"id":"1","result":{"hash":"0xab","number":"0x2","transactions":["0x1"]}}`,
+	}}
+	c := New(primary, candidate, 100, 4, report, "mainnet", logger)
+
+	for _, body := range []string{`{"method":"eth_getBalance"}`, `{"method":"eth_getBlock"}`, `{"method":"eth_call"}`, `{"method":"missing"}`} {
+		res, err := c.Post(context.Background(), body)
+		assert.Equal(t, primary.res[body], string(res))
+		assert.Equal(t, body == `{"method":"missing"}`, err != nil)
+	}
+
+	var m Metrics
+	for i := 0; i < 100; i++ {
+		if m = c.Metrics(); m.Mirrored == 3 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, Metrics{Mirrored: 3, Matched: 1, Mismatched: 1, Failed: 1, Skipped: 1}, m)
+
+	data, err := ioutil.ReadFile(report)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	assert.Len(t, lines, 1)
+
+	var mismatch Mismatch
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &mismatch))
+	assert.Equal(t, "eth_getBlock", mismatch.Method)
+	assert.Equal(t, "mainnet", mismatch.Chain)
+	assert.Equal(t, []string{"result.number", "result.transactions.#"}, mismatch.Paths)
+}
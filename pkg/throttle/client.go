@@ -0,0 +1,107 @@
+package throttle
+
+import (
+	"context"
+	"github.com/divilla/ethproxy/interfaces"
+	"github.com/tidwall/gjson"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+type (
+	// Classifier returns priority of json RPC request body
+	Classifier func(body string) Priority
+
+	// Client passes requests to wrapped client through limiter, it implements interfaces.HttpClient
+	Client struct {
+		client   interfaces.HttpClient
+		limiter  *Limiter
+		classify atomic.Value
+	}
+)
+
+func NewClient(client interfaces.HttpClient, limiter *Limiter, classify Classifier) *Client {
+	c := &Client{
+		client:  client,
+		limiter: limiter,
+	}
+	c.Classify(classify)
+
+	return c
+}
+
+// Classify replaces request classifier, it's safe to call while client is in use
+func (c *Client) Classify(classify Classifier) {
+	c.classify.Store(classify)
+}
+
+func (c *Client) Url(url string) error {
+	return c.client.Url(url)
+}
+
+func (c *Client) Post(ctx context.Context, body string) ([]byte, error) {
+	p := c.classify.Load().(Classifier)(body)
+	if err := c.limiter.Acquire(p); err != nil {
+		return nil, err
+	}
+	defer c.limiter.Release()
+
+	return c.client.Post(ctx, body)
+}
+
+// ByHead returns classifier that treats head tracking & requests for blocks within distance of head as High priority,
+// batches of older blocks (historical backfills) as Low and everything else as Normal.
+// Head function returning 0 means head is not known yet.
+func ByHead(head func() uint64, distance uint64) Classifier {
+	recent := func(nr uint64) bool {
+		h := head()
+		return h > 0 && nr+distance >= h
+	}
+
+	return func(body string) Priority {
+		req := gjson.Parse(body)
+		if req.IsArray() {
+			p := High
+			req.ForEach(func(_, r gjson.Result) bool {
+				if nr, ok := blockNumber(r); !ok || !recent(nr) {
+					p = Low
+					return false
+				}
+				return true
+			})
+			return p
+		}
+
+		if req.Get("method").String() == "eth_blockNumber" {
+			return High
+		}
+		if nr, ok := blockNumber(req); ok && recent(nr) {
+			return High
+		}
+		if req.Get("method").String() == "eth_getBlockByNumber" && isTag(req.Get("params.0").String()) {
+			return High
+		}
+
+		return Normal
+	}
+}
+
+// blockNumber returns block number of eth_getBlockByNumber request
+func blockNumber(req gjson.Result) (uint64, bool) {
+	if req.Get("method").String() != "eth_getBlockByNumber" {
+		return 0, false
+	}
+
+	param := req.Get("params.0").String()
+	if !strings.HasPrefix(param, "0x") {
+		return 0, false
+	}
+
+	nr, err := strconv.ParseUint(param[2:], 16, 64)
+	return nr, err == nil
+}
+
+func isTag(param string) bool {
+	return param != "" && !strings.HasPrefix(param, "0x") && param != "earliest"
+}
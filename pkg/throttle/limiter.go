@@ -0,0 +1,219 @@
+package throttle
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	Low Priority = iota
+	Normal
+	High
+)
+
+var (
+	ErrQueueFull    = errors.New("upstream is overloaded, request queue is full")
+	ErrQueueTimeout = errors.New("upstream is overloaded, request timed out in queue")
+	ErrEvicted      = errors.New("upstream is overloaded, request was evicted from queue by higher priority request")
+)
+
+type (
+	// Priority orders queued requests, higher priority requests are started first
+	Priority int
+
+	// Limiter bounds number of concurrent upstream requests, requests above limit wait in bounded priority queue
+	Limiter struct {
+		concurrency int
+		queueSize   int
+		timeout     time.Duration
+		active      int
+		queued      int
+		queues      [High + 1][]*waiter
+		metrics     [High + 1]counters
+		mx          sync.Mutex
+	}
+
+	waiter struct {
+		ready   chan struct{}
+		err     error
+		granted bool
+	}
+
+	counters struct {
+		Started  uint64        `json:"started"`
+		Queued   uint64        `json:"queued"`
+		Rejected uint64        `json:"rejected"`
+		Expired  uint64        `json:"expired"`
+		Evicted  uint64        `json:"evicted"`
+		Waited   time.Duration `json:"-"`
+	}
+
+	// Metrics holds limiter state and counters by priority
+	Metrics struct {
+		Concurrency int                      `json:"concurrency"`
+		QueueSize   int                      `json:"queue_size"`
+		Active      int                      `json:"active"`
+		Queued      int                      `json:"queued"`
+		Priorities  map[string]PriorityStats `json:"priorities"`
+	}
+
+	// PriorityStats holds counters of single priority, AvgWaitMs is average queue time of started requests
+	PriorityStats struct {
+		counters
+		Waiting   int     `json:"waiting"`
+		AvgWaitMs float64 `json:"avg_wait_ms"`
+	}
+)
+
+func (p Priority) String() string {
+	switch p {
+	case Low:
+		return "low"
+	case High:
+		return "high"
+	default:
+		return "normal"
+	}
+}
+
+// IsOverloaded returns true if request was shed by limiter
+func IsOverloaded(err error) bool {
+	return errors.Is(err, ErrQueueFull) || errors.Is(err, ErrQueueTimeout) || errors.Is(err, ErrEvicted)
+}
+
+// New creates limiter with concurrency slots, queueSize waiting requests and timeout as longest queue time
+func New(concurrency, queueSize int, timeout time.Duration) *Limiter {
+	return &Limiter{
+		concurrency: concurrency,
+		queueSize:   queueSize,
+		timeout:     timeout,
+	}
+}
+
+// Acquire takes concurrency slot, waiting in queue if there is no free slot.
+// Full queue evicts the newest request of lower priority, if there is none request is rejected.
+// Every successful Acquire must be followed by Release.
+func (l *Limiter) Acquire(p Priority) error {
+	l.mx.Lock()
+	if l.active < l.concurrency && l.queued == 0 {
+		l.active++
+		l.metrics[p].Started++
+		l.mx.Unlock()
+		return nil
+	}
+
+	if l.queued >= l.queueSize && !l.evict(p) {
+		l.metrics[p].Rejected++
+		l.mx.Unlock()
+		return ErrQueueFull
+	}
+
+	w := &waiter{ready: make(chan struct{})}
+	l.queues[p] = append(l.queues[p], w)
+	l.queued++
+	l.metrics[p].Queued++
+	l.mx.Unlock()
+
+	start := time.Now()
+	timer := time.NewTimer(l.timeout)
+	defer timer.Stop()
+
+	select {
+	case <-w.ready:
+	case <-timer.C:
+	}
+
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	if w.granted {
+		l.metrics[p].Started++
+		l.metrics[p].Waited += time.Since(start)
+		return nil
+	}
+	if w.err != nil {
+		return w.err
+	}
+
+	l.remove(p, w)
+	l.metrics[p].Expired++
+	return ErrQueueTimeout
+}
+
+// Release frees concurrency slot, slot is passed to the oldest waiting request of the highest priority
+func (l *Limiter) Release() {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	for p := High; p >= Low; p-- {
+		if len(l.queues[p]) == 0 {
+			continue
+		}
+
+		w := l.queues[p][0]
+		l.queues[p] = l.queues[p][1:]
+		l.queued--
+		w.granted = true
+		close(w.ready)
+		return
+	}
+
+	l.active--
+}
+
+// Metrics returns current state and counters of limiter
+func (l *Limiter) Metrics() Metrics {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	m := Metrics{
+		Concurrency: l.concurrency,
+		QueueSize:   l.queueSize,
+		Active:      l.active,
+		Queued:      l.queued,
+		Priorities:  make(map[string]PriorityStats, len(l.metrics)),
+	}
+	for p, c := range l.metrics {
+		s := PriorityStats{
+			counters: c,
+			Waiting:  len(l.queues[p]),
+		}
+		if started := c.Queued - c.Expired - c.Evicted - uint64(s.Waiting); started > 0 {
+			s.AvgWaitMs = float64(c.Waited.Milliseconds()) / float64(started)
+		}
+		m.Priorities[Priority(p).String()] = s
+	}
+
+	return m
+}
+
+// evict removes the newest waiting request with priority lower than p
+func (l *Limiter) evict(p Priority) bool {
+	for lp := Low; lp < p; lp++ {
+		n := len(l.queues[lp])
+		if n == 0 {
+			continue
+		}
+
+		w := l.queues[lp][n-1]
+		l.queues[lp] = l.queues[lp][:n-1]
+		l.queued--
+		l.metrics[lp].Evicted++
+		w.err = ErrEvicted
+		close(w.ready)
+		return true
+	}
+
+	return false
+}
+
+func (l *Limiter) remove(p Priority, w *waiter) {
+	for i, qw := range l.queues[p] {
+		if qw == w {
+			l.queues[p] = append(l.queues[p][:i], l.queues[p][i+1:]...)
+			l.queued--
+			return
+		}
+	}
+}
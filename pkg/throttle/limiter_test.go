@@ -0,0 +1,81 @@
+package throttle
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestLimiter_Acquire(t *testing.T) {
+	l := New(1, 2, time.Second)
+	assert.NoError(t, l.Acquire(Normal))
+
+	low := make(chan error)
+	go func() {
+		low <- l.Acquire(Low)
+	}()
+	high := make(chan error)
+	go func() {
+		high <- l.Acquire(High)
+	}()
+	waitQueued(t, l, 2)
+
+	// full queue evicts lower priority request
+	normal := make(chan error)
+	go func() {
+		normal <- l.Acquire(Normal)
+	}()
+	assert.Equal(t, ErrEvicted, <-low)
+	waitQueued(t, l, 2)
+
+	// equal priority can't evict
+	assert.Equal(t, ErrQueueFull, l.Acquire(Normal))
+
+	// released slot goes to the highest priority
+	l.Release()
+	assert.NoError(t, <-high)
+	l.Release()
+	assert.NoError(t, <-normal)
+	l.Release()
+
+	m := l.Metrics()
+	assert.Equal(t, 0, m.Active)
+	assert.Equal(t, 0, m.Queued)
+	assert.Equal(t, uint64(1), m.Priorities["low"].Evicted)
+	assert.Equal(t, uint64(1), m.Priorities["normal"].Rejected)
+	assert.Equal(t, uint64(2), m.Priorities["normal"].Started)
+	assert.True(t, IsOverloaded(ErrEvicted))
+}
+
+func TestLimiter_Timeout(t *testing.T) {
+	l := New(1, 1, 10*time.Millisecond)
+	assert.NoError(t, l.Acquire(High))
+	assert.Equal(t, ErrQueueTimeout, l.Acquire(High))
+
+	m := l.Metrics()
+	assert.Equal(t, 0, m.Queued)
+	assert.Equal(t, uint64(1), m.Priorities["high"].Expired)
+}
+
+func TestByHead(t *testing.T) {
+	classify := ByHead(func() uint64 { return 1000 }, 10)
+
+	assert.Equal(t, High, classify(`{"jsonrpc":"2.0","method":"eth_blockNumber","params":[]}`))
+	assert.Equal(t, High, classify(`{"jsonrpc":"2.0","method":"eth_getBlockByNumber","params":["latest",true]}`))
+	assert.Equal(t, High, classify(`{"jsonrpc":"2.0","method":"eth_getBlockByNumber","params":["0x3e0",true]}`))
+	assert.Equal(t, Normal, classify(`{"jsonrpc":"2.0","method":"eth_getBlockByNumber","params":["0x10",true]}`))
+	assert.Equal(t, Normal, classify(`{"jsonrpc":"2.0","method":"eth_call","params":[{},"latest"]}`))
+	assert.Equal(t, High, classify(`[{"jsonrpc":"2.0","method":"eth_getBlockByNumber","params":["0x3e7",true]}]`))
+	assert.Equal(t, Low, classify(`[{"jsonrpc":"2.0","method":"eth_getBlockByNumber","params":["0x3e7",true]},`+
+		`{"jsonrpc":"2.0","method":"eth_getBlockByNumber","params":["0x10",true]}]`))
+}
+
+func waitQueued(t *testing.T, l *Limiter, n int) {
+	for i := 0; i < 100; i++ {
+		if l.Metrics().Queued == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("expected %d queued requests", n)
+}
@@ -0,0 +1,71 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSetup(t *testing.T) {
+	shutdown, err := Setup(Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+
+	_, err = Setup(Config{Exporter: "zipkin"})
+	assert.Error(t, err)
+
+	file := filepath.Join(t.TempDir(), "traces.jsonl")
+	shutdown, err = Setup(Config{Exporter: ExporterFile, File: file, SampleRatio: 1, Service: "test"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer otel.SetTracerProvider(sdktrace.NewTracerProvider())
+
+	_, span := Start(context.Background(), "test.span")
+	End(span, nil)
+	assert.NoError(t, shutdown(context.Background()))
+
+	b, err := ioutil.ReadFile(file)
+	assert.NoError(t, err)
+	m := map[string]interface{}{}
+	if assert.NoError(t, json.Unmarshal([]byte(strings.Split(string(b), "\n")[0]), &m)) {
+		assert.Equal(t, "test.span", m["Name"])
+	}
+}
+
+func TestPropagation(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(sdktrace.NewTracerProvider())
+	_, err := Setup(Config{})
+	assert.NoError(t, err)
+
+	in := http.Header{}
+	in.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx, span := Start(Extract(context.Background(), in), "parent")
+	_, child := Start(ctx, "child")
+	End(child, errors.New("failed"))
+
+	out := http.Header{}
+	Inject(ctx, out)
+	span.End()
+	assert.True(t, strings.HasPrefix(out.Get("traceparent"), "00-4bf92f3577b34da6a3ce929d0e0e4736-"))
+
+	spans := recorder.Ended()
+	if assert.Len(t, spans, 2) {
+		assert.Equal(t, "child", spans[0].Name())
+		assert.Equal(t, codes.Error, spans[0].Status().Code)
+		assert.Equal(t, spans[1].SpanContext().SpanID(), spans[0].Parent().SpanID())
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[1].SpanContext().TraceID().String())
+	}
+}
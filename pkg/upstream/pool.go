@@ -0,0 +1,174 @@
+package upstream
+
+import (
+	"context"
+	"github.com/divilla/ethproxy/interfaces"
+	"github.com/divilla/ethproxy/pkg/ethclient"
+	"github.com/pkg/errors"
+	"sync"
+	"time"
+)
+
+type (
+	// Upstream is json RPC node with its url
+	Upstream struct {
+		Url    string
+		Client interfaces.HttpClient
+	}
+
+	// Pool tracks health of upstreams by polling their latest block number, upstream joins pool
+	// only after it reports chain id of pool chain. It implements interfaces.HttpClient by sending requests
+	// to the first healthy upstream.
+	Pool struct {
+		upstreams []Upstream
+		// clients are returned by Healthy & used by Post, they are upstream clients unless they are wrapped
+		clients  []Upstream
+		chainID  uint64
+		verified map[string]bool
+		healthy  map[string]bool
+		logger   interfaces.Logger
+		interval time.Duration
+		done     chan struct{}
+		mx       sync.RWMutex
+	}
+)
+
+// New creates pool of upstreams serving chainID, zero chainID isn't verified
+func New(logger interfaces.Logger, interval time.Duration, chainID uint64, upstreams ...Upstream) *Pool {
+	p := &Pool{
+		upstreams: upstreams,
+		clients:   upstreams,
+		chainID:   chainID,
+		verified:  make(map[string]bool, len(upstreams)),
+		healthy:   make(map[string]bool, len(upstreams)),
+		logger:    logger,
+		interval:  interval,
+		done:      make(chan struct{}),
+	}
+
+	p.check()
+
+	go func(p *Pool) {
+		for {
+			select {
+			case <-p.done:
+				return
+			case <-time.After(p.interval):
+				p.check()
+			}
+		}
+	}(p)
+
+	return p
+}
+
+// Wrap wraps clients returned by Healthy & used by Post, i.e. to pass them through upstream concurrency limiter.
+// Health checks use unwrapped clients, so they are never delayed or shed.
+func (p *Pool) Wrap(wrap func(client interfaces.HttpClient) interfaces.HttpClient) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	clients := make([]Upstream, len(p.upstreams))
+	for i, u := range p.upstreams {
+		clients[i] = Upstream{Url: u.Url, Client: wrap(u.Client)}
+	}
+	p.clients = clients
+}
+
+// Healthy returns upstreams that answered the last health check, in configured order
+func (p *Pool) Healthy() []Upstream {
+	p.mx.RLock()
+	defer p.mx.RUnlock()
+
+	healthy := make([]Upstream, 0, len(p.clients))
+	for _, u := range p.clients {
+		if p.healthy[u.Url] {
+			healthy = append(healthy, u)
+		}
+	}
+
+	return healthy
+}
+
+// Url fails, pool upstreams are set by New
+func (p *Pool) Url(string) error {
+	return errors.New("pool upstream urls are set on creation")
+}
+
+// Post sends request to the first healthy upstream in configured order, upstreams whose chain id
+// isn't verified are never used
+func (p *Pool) Post(ctx context.Context, body string) ([]byte, error) {
+	healthy := p.Healthy()
+	if len(healthy) == 0 {
+		return nil, errors.New("pool has no healthy upstream")
+	}
+
+	return healthy[0].Client.Post(ctx, body)
+}
+
+func (p *Pool) Done() {
+	p.done <- struct{}{}
+	close(p.done)
+}
+
+// check polls all upstreams in parallel, upstream is healthy if it returns valid block number
+// and it has reported pool chain id
+func (p *Pool) check() {
+	results := make([]bool, len(p.upstreams))
+	verified := make([]bool, len(p.upstreams))
+	wg := sync.WaitGroup{}
+	p.mx.RLock()
+	for i, u := range p.upstreams {
+		verified[i] = p.chainID == 0 || p.verified[u.Url]
+	}
+	p.mx.RUnlock()
+
+	for i, u := range p.upstreams {
+		wg.Add(1)
+		go func(i int, u Upstream) {
+			defer wg.Done()
+
+			var err error
+			if !verified[i] {
+				err = p.verify(u)
+				verified[i] = err == nil
+			}
+			if err == nil {
+				var res []byte
+				res, err = ethclient.Call(context.Background(), u.Client, "blockNumber")
+				if err == nil {
+					_, err = ethclient.HexToUInt(string(res))
+				}
+			}
+			if err != nil {
+				p.logger.Errorf("upstream '%s' failed health check: %v", u.Url, err)
+			}
+			results[i] = err == nil
+		}(i, u)
+	}
+	wg.Wait()
+
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	for i, u := range p.upstreams {
+		if p.healthy[u.Url] != results[i] && results[i] {
+			p.logger.Infof("upstream '%s' is healthy", u.Url)
+		}
+		p.healthy[u.Url] = results[i]
+		p.verified[u.Url] = verified[i]
+	}
+}
+
+// verify checks that upstream serves pool chain
+func (p *Pool) verify(u Upstream) error {
+	id, err := ethclient.ChainID(context.Background(), u.Client)
+	if err != nil {
+		return errors.Wrap(err, "failed to return chain id")
+	}
+	if id != p.chainID {
+		return errors.Errorf("serves chain id '%d' instead of '%d'", id, p.chainID)
+	}
+
+	return nil
+}
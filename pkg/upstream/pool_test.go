@@ -0,0 +1,119 @@
+package upstream
+
+import (
+	"context"
+	"github.com/divilla/ethproxy/interfaces"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type node struct {
+	down  int32
+	posts int32
+	chain string
+}
+
+func (n *node) Url(url string) error {
+	return nil
+}
+
+func (n *node) Post(_ context.Context, body string) ([]byte, error) {
+	if atomic.LoadInt32(&n.down) == 1 {
+		return nil, errors.New("connection refused")
+	}
+
+	result := "0x10"
+	if gjson.Get(body, "method").String() == "eth_gasPrice" {
+		atomic.AddInt32(&n.posts, 1)
+	}
+	if gjson.Get(body, "method").String() == "eth_chainId" {
+		result = n.chain
+		if result == "" {
+			result = "0x1"
+		}
+	}
+
+	return []byte(`{"jsonrpc":"2.0","id":"` + gjson.Get(body, "id").String() + `","result":"` + result + `"}`), nil
+}
+
+func TestPool_Healthy(t *testing.T) {
+	a, b := &node{}, &node{down: 1}
+	p := New(echo.New().Logger, 10*time.Millisecond, 1, Upstream{Url: "a", Client: a}, Upstream{Url: "b", Client: b})
+	defer p.Done()
+
+	if assert.Len(t, p.Healthy(), 1) {
+		assert.Equal(t, "a", p.Healthy()[0].Url)
+	}
+
+	atomic.StoreInt32(&b.down, 0)
+	atomic.StoreInt32(&a.down, 1)
+	assert.Eventually(t, func() bool {
+		healthy := p.Healthy()
+		return len(healthy) == 1 && healthy[0].Url == "b"
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestPool_ChainID(t *testing.T) {
+	a, b, c := &node{}, &node{down: 1}, &node{chain: "0x5"}
+	p := New(echo.New().Logger, 10*time.Millisecond, 1, Upstream{Url: "a", Client: a}, Upstream{Url: "b", Client: b}, Upstream{Url: "c", Client: c})
+	defer p.Done()
+
+	if assert.Len(t, p.Healthy(), 1) {
+		assert.Equal(t, "a", p.Healthy()[0].Url)
+	}
+
+	// unreachable upstream joins pool once it reports chain id, upstream of other chain never does
+	atomic.StoreInt32(&b.down, 0)
+	assert.Eventually(t, func() bool {
+		return len(p.Healthy()) == 2
+	}, time.Second, 5*time.Millisecond)
+	time.Sleep(30 * time.Millisecond)
+	for _, u := range p.Healthy() {
+		assert.NotEqual(t, "c", u.Url)
+	}
+}
+
+type wrapped struct {
+	interfaces.HttpClient
+}
+
+func TestPool_Wrap(t *testing.T) {
+	a, b := &node{}, &node{}
+	p := New(echo.New().Logger, 10*time.Millisecond, 1, Upstream{Url: "a", Client: a}, Upstream{Url: "b", Client: b})
+	defer p.Done()
+
+	p.Wrap(func(client interfaces.HttpClient) interfaces.HttpClient {
+		return wrapped{client}
+	})
+	ups := p.Healthy()
+	if assert.Len(t, ups, 2) {
+		assert.Equal(t, "a", ups[0].Url)
+		assert.Equal(t, wrapped{a}, ups[0].Client)
+		assert.Equal(t, wrapped{b}, ups[1].Client)
+	}
+}
+
+func TestPool_Post(t *testing.T) {
+	a, b, c := &node{down: 1}, &node{chain: "0x5"}, &node{}
+	p := New(echo.New().Logger, 10*time.Millisecond, 1, Upstream{Url: "a", Client: a}, Upstream{Url: "b", Client: b}, Upstream{Url: "c", Client: c})
+	defer p.Done()
+
+	// unreachable & other chain upstreams aren't used, even when they are configured first
+	_, err := p.Post(context.Background(), `{"jsonrpc":"2.0","id":"1","method":"eth_gasPrice","params":[]}`)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&a.posts))
+	assert.Equal(t, int32(0), atomic.LoadInt32(&b.posts))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&c.posts))
+
+	atomic.StoreInt32(&c.down, 1)
+	assert.Eventually(t, func() bool {
+		return len(p.Healthy()) == 0
+	}, time.Second, 5*time.Millisecond)
+	_, err = p.Post(context.Background(), `{"jsonrpc":"2.0","id":"1","method":"eth_gasPrice","params":[]}`)
+	assert.Error(t, err)
+}